@@ -0,0 +1,141 @@
+package dtraderhq
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// authSuccessMessage 旧版服务器认证成功时 data.message 的内容，仅在没有结构化状态字段时使用
+const authSuccessMessage = "认证成功"
+
+// authReply 判断消息是否为认证应答及认证结果
+//
+// 只有 expected 为 true 时 type 为 auth 或 success 的消息才是认证应答：type 为 auth 时要求有未应答的认证请求，
+// type 为 success 时还要按 expectsAuthReply 与其他未应答请求区分；没有等待中的认证请求时这些消息被忽略。
+// 结果优先按 error、authenticated / success / ok、status / result / state、code 等结构化字段判断，
+// 都没有时才比较 data.message 和标明的请求类型，仍无法判断的认证应答按失败处理，避免 WaitAuthenticated 一直等待。
+func authReply(msg *Message, expected bool) (isReply, ok bool, reason string) {
+	if !expected || msg.Type != MessageTypeAuth && msg.Type != MessageTypeSuccess {
+		return false, false, ""
+	}
+
+	data, _ := msg.Data.(map[string]interface{})
+	message, _ := data["message"].(string)
+	if message == "" {
+		message, _ = msg.Data.(string)
+	}
+	if msg.Error != "" {
+		return true, false, msg.Error
+	}
+	if status, known := authStatus(data); known {
+		return true, status, message
+	}
+	if message == authSuccessMessage || replyOp(data) == MessageTypeAuth {
+		return true, true, message
+	}
+	return true, false, fmt.Sprintf("unrecognized auth reply %q", message)
+}
+
+// authStatus 读取认证应答中的结构化状态字段，没有可识别的字段时 known 为 false
+func authStatus(data map[string]interface{}) (ok, known bool) {
+	for _, key := range []string{"authenticated", "success", "ok"} {
+		if v, exists := data[key].(bool); exists {
+			return v, true
+		}
+	}
+	for _, key := range []string{"status", "result", "state"} {
+		v, _ := data[key].(string)
+		switch strings.ToLower(v) {
+		case "success", "succeeded", "ok", "authenticated", "authorized":
+			return true, true
+		case "fail", "failed", "failure", "error", "denied", "unauthorized", "forbidden", "invalid", "expired":
+			return false, true
+		}
+	}
+	if code, exists := data["code"].(float64); exists {
+		return code == 0 || code == 200, true
+	}
+	return false, false
+}
+
+// expectsAuthReply 判断没有标明 type 为 auth 的应答是否属于未应答的认证请求：
+// 应答标明了请求类型时按类型判断，否则只有认证请求是最早的未应答请求时才是
+func (c *Client) expectsAuthReply(op string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.authReq == nil {
+		return false
+	}
+	if op != "" {
+		return op == MessageTypeAuth
+	}
+	c.expirePendingLocked(time.Now())
+	return c.pending[0] == c.authReq
+}
+
+// isAuthError 判断错误消息是否为认证请求的应答：认证完成前不会发送其他请求，错误都属于认证；
+// 重新认证时错误不能指向某只股票，并按 expectsAuthReply 与其他未应答请求区分
+func (c *Client) isAuthError(serverErr *ServerError) bool {
+	c.mu.RLock()
+	pending, authenticated := c.authReq != nil, c.isAuthenticated
+	c.mu.RUnlock()
+	if !pending {
+		return false
+	}
+	if !authenticated {
+		return true
+	}
+	if stockCode, _ := serverErr.Context["stock_code"].(string); stockCode != "" {
+		return false
+	}
+	return c.expectsAuthReply(replyOp(serverErr.Context))
+}
+
+// handleAuthReply 处理认证应答，返回消息是否为认证应答
+func (c *Client) handleAuthReply(msg *Message, done chan struct{}) bool {
+	var expected bool
+	switch msg.Type {
+	case MessageTypeAuth:
+		c.mu.RLock()
+		expected = c.authReq != nil
+		c.mu.RUnlock()
+	case MessageTypeSuccess:
+		data, _ := msg.Data.(map[string]interface{})
+		expected = c.expectsAuthReply(replyOp(data))
+	}
+
+	isReply, ok, reason := authReply(msg, expected)
+	if !isReply {
+		return false
+	}
+	if ok {
+		c.setAuthenticated()
+		return true
+	}
+	if reason == "" {
+		reason = "rejected by server"
+	}
+	c.authFailed(fmt.Errorf("%w: %s", ErrAuthFailed, reason), done)
+	return true
+}
+
+// authFailed 记录认证失败并唤醒 WaitAuthenticated；已认证的连接上重新认证失败时只报告错误
+func (c *Client) authFailed(err error, done chan struct{}) {
+	c.mu.Lock()
+	c.finishAuthLocked(err)
+	if !c.isAuthenticated && c.authErr == nil {
+		c.authErr = err
+		if c.authChan != nil {
+			close(c.authChan)
+		}
+	}
+	c.loggerLocked().Warn("authentication failed", slog.Any("error", err))
+	c.mu.Unlock()
+
+	select {
+	case c.errorChan <- err:
+	case <-done:
+	}
+}
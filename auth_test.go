@@ -0,0 +1,193 @@
+package dtraderhq
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestAuthReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      Message
+		expected bool
+		isReply  bool
+		ok       bool
+		reason   string
+	}{
+		{"auth error", Message{Type: MessageTypeAuth, Error: "invalid token"}, true, true, false, "invalid token"},
+		{"auth bool", Message{Type: MessageTypeAuth, Data: map[string]any{"authenticated": true}}, true, true, true, ""},
+		{"auth bool false", Message{Type: MessageTypeAuth, Data: map[string]any{"success": false, "message": "expired"}}, true, true, false, "expired"},
+		{"auth status", Message{Type: MessageTypeAuth, Data: map[string]any{"status": "OK"}}, true, true, true, ""},
+		{"auth status failed", Message{Type: MessageTypeAuth, Data: map[string]any{"result": "denied"}}, true, true, false, ""},
+		{"auth code 0", Message{Type: MessageTypeAuth, Data: map[string]any{"code": 0.0}}, true, true, true, ""},
+		{"auth code 401", Message{Type: MessageTypeAuth, Data: map[string]any{"code": 401.0}}, true, true, false, ""},
+		{"auth legacy", Message{Type: MessageTypeAuth, Data: map[string]any{"message": "认证成功"}}, true, true, true, "认证成功"},
+		{"auth string data", Message{Type: MessageTypeAuth, Data: "认证成功"}, true, true, true, "认证成功"},
+		{"auth unrecognized", Message{Type: MessageTypeAuth, Data: map[string]any{"message": "hello"}}, true, true, false, "unrecognized"},
+		{"auth not expected", Message{Type: MessageTypeAuth, Data: map[string]any{"authenticated": true}}, false, false, false, ""},
+		{"success not expected", Message{Type: MessageTypeSuccess, Data: map[string]any{"code": 0.0}}, false, false, false, ""},
+		{"success code", Message{Type: MessageTypeSuccess, Data: map[string]any{"code": 0.0}}, true, true, true, ""},
+		{"success rejected", Message{Type: MessageTypeSuccess, Data: map[string]any{"ok": false}}, true, true, false, ""},
+		{"success legacy", Message{Type: MessageTypeSuccess, Data: map[string]any{"message": "认证成功"}}, true, true, true, "认证成功"},
+		{"success op auth", Message{Type: MessageTypeSuccess, Data: map[string]any{"op": "auth"}}, true, true, true, ""},
+		{"success without status", Message{Type: MessageTypeSuccess, Data: map[string]any{"message": "ok"}}, true, true, false, "unrecognized"},
+		{"other type", Message{Type: MessageTypeSubscribe, Data: map[string]any{"code": 0.0}}, true, false, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isReply, ok, reason := authReply(&tt.msg, tt.expected)
+			if isReply != tt.isReply || ok != tt.ok || !strings.Contains(reason, tt.reason) {
+				t.Fatalf("authReply = %v, %v, %q", isReply, ok, reason)
+			}
+		})
+	}
+}
+
+// reauthClient 返回已认证的客户端，服务器不应答之后的订阅和认证请求，由测试按需写入应答
+func reauthClient(t *testing.T) (*testServer, *Client) {
+	t.Helper()
+	srv := newTestServer(t)
+	c := connectTestClient(t, srv, WithAckTimeout(3*time.Second))
+	srv.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		return msg["type"] == MessageTypeAuth || msg["type"] == MessageTypeSubscribe
+	})
+	return srv, c
+}
+
+// 重新认证时，先于认证请求发出的订阅的成功应答不应被当作认证应答
+func TestReauthSuccessCorrelation(t *testing.T) {
+	srv, c := reauthClient(t)
+
+	subscribed := make(chan error, 1)
+	go func() { subscribed <- c.Subscribe("SZ000001", []int{4}) }()
+	waitFor(t, "subscribe sent", func() bool { return len(srv.messages(MessageTypeSubscribe)) == 1 })
+	result, err := c.authenticate("new-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "auth sent", func() bool { return len(srv.messages(MessageTypeAuth)) == 2 })
+
+	// 订阅请求更早时，未标明请求类型的应答不属于认证请求，也不会交给订阅请求
+	srv.broadcast(map[string]any{"type": MessageTypeSuccess, "data": map[string]any{"code": 0}})
+	time.Sleep(50 * time.Millisecond)
+	if !authPending(c) {
+		t.Fatal("unlabelled ack taken as the auth reply")
+	}
+	select {
+	case err := <-subscribed:
+		t.Fatalf("unlabelled ack resolved Subscribe: %v", err)
+	default:
+	}
+
+	// 标明请求类型的应答交给该类请求
+	srv.broadcast(map[string]any{"type": MessageTypeSuccess, "data": map[string]any{"op": MessageTypeSubscribe, "code": 0}})
+	if err := <-subscribed; err != nil {
+		t.Fatalf("Subscribe = %v", err)
+	}
+	if !authPending(c) {
+		t.Fatal("subscribe ack taken as the auth reply")
+	}
+
+	// 认证请求成为最早的未应答请求后，未标明请求类型的应答属于认证请求
+	srv.broadcast(map[string]any{"type": MessageTypeSuccess, "data": map[string]any{"code": 0}})
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("auth result = %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("auth reply not received")
+	}
+}
+
+// 重新认证时，指向某只股票的错误属于订阅请求，其他错误在认证请求最早时属于认证请求
+func TestReauthErrorCorrelation(t *testing.T) {
+	srv, c := reauthClient(t)
+
+	subscribed := make(chan error, 1)
+	go func() { subscribed <- c.Subscribe("SZ000001", []int{4}) }()
+	waitFor(t, "subscribe sent", func() bool { return len(srv.messages(MessageTypeSubscribe)) == 1 })
+	result, err := c.authenticate("new-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "auth sent", func() bool { return len(srv.messages(MessageTypeAuth)) == 2 })
+
+	srv.broadcast(map[string]any{"type": MessageTypeError, "error": "invalid stock code", "data": map[string]any{"stock_code": "SZ000001"}})
+	var reqErr *RequestError
+	if err := <-subscribed; !errors.As(err, &reqErr) || reqErr.Op != MessageTypeSubscribe {
+		t.Fatalf("Subscribe = %v", err)
+	}
+	if !authPending(c) {
+		t.Fatal("subscription error taken as the auth reply")
+	}
+
+	srv.broadcast(map[string]any{"type": MessageTypeError, "error": "token revoked"})
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrAuthFailed) || !strings.Contains(err.Error(), "token revoked") {
+			t.Fatalf("auth result = %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("auth error not received")
+	}
+	if !c.IsAuthenticated() {
+		t.Fatal("failed re-authentication dropped the authenticated state")
+	}
+}
+
+// 没有等待中的认证请求时，type 为 auth 的消息不改变认证状态
+func TestUnsolicitedAuthFrame(t *testing.T) {
+	srv := newTestServer(t)
+	c := NewClient(srv.url())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	c.handleMessage([]byte(`{"type":"auth","data":{"authenticated":true}}`), make(chan struct{}))
+	if c.IsAuthenticated() {
+		t.Fatal("unsolicited auth frame authenticated the client")
+	}
+
+	c = connectTestClient(t, srv)
+	c.handleMessage([]byte(`{"type":"auth","data":{"authenticated":false}}`), make(chan struct{}))
+	if !c.IsAuthenticated() {
+		t.Fatal("unsolicited auth frame dropped the authenticated state")
+	}
+	select {
+	case err := <-c.ErrorChannel():
+		t.Fatalf("unsolicited auth frame reported: %v", err)
+	default:
+	}
+}
+
+// 认证请求的应答无法识别时按失败处理，WaitAuthenticated 不等到超时
+func TestUnrecognizedAuthReply(t *testing.T) {
+	srv := newTestServer(t)
+	srv.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		if msg["type"] != MessageTypeAuth {
+			return false
+		}
+		srv.write(conn, map[string]any{"type": MessageTypeSuccess, "data": map[string]any{"message": "ok"}})
+		return true
+	})
+	c := NewClient(srv.url())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.Authenticate("token"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := c.WaitAuthenticated(ctx); !errors.Is(err, ErrAuthFailed) || !strings.Contains(err.Error(), "unrecognized") {
+		t.Fatalf("WaitAuthenticated = %v", err)
+	}
+}
@@ -0,0 +1,345 @@
+package dtraderhq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Market 交易市场
+type Market string
+
+// A股市场
+const (
+	MarketSH Market = "SH" // 上海证券交易所
+	MarketSZ Market = "SZ" // 深圳证券交易所
+	MarketBJ Market = "BJ" // 北京证券交易所
+)
+
+// AllMarkets 全部A股市场
+var AllMarkets = []Market{MarketSH, MarketSZ, MarketBJ}
+
+// MarketOf 根据股票代码判断所属市场，支持 "SZ002240" 和 "002240" 两种写法
+func MarketOf(stockCode string) Market {
+	code := strings.ToUpper(stockCode)
+	for _, m := range AllMarkets {
+		if strings.HasPrefix(code, string(m)) {
+			return m
+		}
+	}
+	if code == "" {
+		return ""
+	}
+	switch code[0] {
+	case '6', '9':
+		return MarketSH
+	case '0', '2', '3':
+		return MarketSZ
+	case '4', '8':
+		return MarketBJ
+	}
+	return ""
+}
+
+// Phase 交易阶段
+type Phase int
+
+// 交易阶段
+const (
+	PhaseClosed         Phase = iota // 非交易时间
+	PhaseOpeningAuction              // 开盘集合竞价 9:15-9:25
+	PhasePreOpen                     // 集合竞价撮合后等待连续竞价 9:25-9:30
+	PhaseContinuous                  // 连续竞价 9:30-11:30, 13:00-14:57
+	PhaseLunchBreak                  // 午间休市 11:30-13:00
+	PhaseClosingAuction              // 收盘集合竞价 14:57-15:00
+)
+
+// String 阶段名称
+func (p Phase) String() string {
+	switch p {
+	case PhaseOpeningAuction:
+		return "opening_auction"
+	case PhasePreOpen:
+		return "pre_open"
+	case PhaseContinuous:
+		return "continuous"
+	case PhaseLunchBreak:
+		return "lunch_break"
+	case PhaseClosingAuction:
+		return "closing_auction"
+	default:
+		return "closed"
+	}
+}
+
+// IsTrading 该阶段是否会产生行情数据
+func (p Phase) IsTrading() bool {
+	return p != PhaseClosed && p != PhaseLunchBreak
+}
+
+// Session 交易日内的一个时段，Start/End 为距当日零点的时长
+type Session struct {
+	Phase Phase
+	Start time.Duration
+	End   time.Duration
+}
+
+func clockOffset(h, m int) time.Duration {
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+}
+
+// DefaultSessions 沪深北三市现行的交易时段
+var DefaultSessions = []Session{
+	{Phase: PhaseOpeningAuction, Start: clockOffset(9, 15), End: clockOffset(9, 25)},
+	{Phase: PhasePreOpen, Start: clockOffset(9, 25), End: clockOffset(9, 30)},
+	{Phase: PhaseContinuous, Start: clockOffset(9, 30), End: clockOffset(11, 30)},
+	{Phase: PhaseLunchBreak, Start: clockOffset(11, 30), End: clockOffset(13, 0)},
+	{Phase: PhaseContinuous, Start: clockOffset(13, 0), End: clockOffset(14, 57)},
+	{Phase: PhaseClosingAuction, Start: clockOffset(14, 57), End: clockOffset(15, 0)},
+}
+
+// Calendar A股交易日历
+//
+// 周六、周日固定休市，节假日从休市文件加载，可按市场单独设置。
+// 所有时间均按交易所时区（Asia/Shanghai）计算。
+type Calendar struct {
+	holidays map[Market]map[string]string // market -> "2006-01-02" -> 说明
+	sessions map[Market][]Session
+}
+
+// NewCalendar 创建只包含周末休市规则的日历
+func NewCalendar() *Calendar {
+	c := &Calendar{
+		holidays: make(map[Market]map[string]string),
+		sessions: make(map[Market][]Session),
+	}
+	for _, m := range AllMarkets {
+		c.holidays[m] = make(map[string]string)
+		c.sessions[m] = DefaultSessions
+	}
+	return c
+}
+
+// LoadCalendar 从本地休市文件加载日历
+func LoadCalendar(path string) (*Calendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open holiday file: %w", err)
+	}
+	defer f.Close()
+	return ParseCalendar(f)
+}
+
+// ParseCalendar 解析休市文件
+//
+// 每行一条记录，# 之后为注释：
+//
+//	2025-01-01 元旦
+//	2025-01-28~2025-02-04 春节
+//	2025-06-30 BJ 仅北交所休市
+//
+// 日期后可选的市场列表（如 "SH,SZ"）限定适用市场，省略时适用于全部市场。
+func ParseCalendar(r io.Reader) (*Calendar, error) {
+	c := NewCalendar()
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		from, to, err := parseDateRange(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		markets := AllMarkets
+		rest := fields[1:]
+		if len(rest) > 0 {
+			if ms, ok := parseMarketList(rest[0]); ok {
+				markets = ms
+				rest = rest[1:]
+			}
+		}
+
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			c.AddHoliday(d, strings.Join(rest, " "), markets...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read holiday file: %w", err)
+	}
+	return c, nil
+}
+
+func parseDateRange(s string) (time.Time, time.Time, error) {
+	parts := strings.SplitN(s, "~", 2)
+	from, err := time.ParseInLocation("2006-01-02", parts[0], ShanghaiLocation)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q", parts[0])
+	}
+	if len(parts) == 1 {
+		return from, from, nil
+	}
+	to, err := time.ParseInLocation("2006-01-02", parts[1], ShanghaiLocation)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q", parts[1])
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid date range %q", s)
+	}
+	return from, to, nil
+}
+
+func parseMarketList(s string) ([]Market, bool) {
+	var markets []Market
+	for _, part := range strings.Split(s, ",") {
+		m, err := ParseMarket(part)
+		if err != nil {
+			return nil, false
+		}
+		markets = append(markets, m)
+	}
+	return markets, true
+}
+
+// ParseMarket 解析市场名称：SH、SZ 或 BJ，不区分大小写
+func ParseMarket(s string) (Market, error) {
+	m := Market(strings.ToUpper(s))
+	if m != MarketSH && m != MarketSZ && m != MarketBJ {
+		return "", fmt.Errorf("unknown market %q", s)
+	}
+	return m, nil
+}
+
+// AddHoliday 添加休市日，未指定市场时适用于全部市场
+func (c *Calendar) AddHoliday(day time.Time, name string, markets ...Market) {
+	if len(markets) == 0 {
+		markets = AllMarkets
+	}
+	key := day.In(ShanghaiLocation).Format("2006-01-02")
+	for _, m := range markets {
+		if c.holidays[m] == nil {
+			c.holidays[m] = make(map[string]string)
+		}
+		c.holidays[m][key] = name
+	}
+}
+
+// SetSessions 设置某个市场的交易时段
+func (c *Calendar) SetSessions(m Market, sessions []Session) {
+	c.sessions[m] = sessions
+}
+
+// Sessions 返回某个市场的交易时段
+func (c *Calendar) Sessions(m Market) []Session {
+	if s, ok := c.sessions[m]; ok {
+		return s
+	}
+	return DefaultSessions
+}
+
+// IsTradingDay 判断 t 所在自然日是否为交易日
+func (c *Calendar) IsTradingDay(m Market, t time.Time) bool {
+	t = t.In(ShanghaiLocation)
+	if wd := t.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	_, holiday := c.holidays[m][t.Format("2006-01-02")]
+	return !holiday
+}
+
+// HolidayName 返回休市说明，非节假日返回空字符串
+func (c *Calendar) HolidayName(m Market, t time.Time) string {
+	return c.holidays[m][t.In(ShanghaiLocation).Format("2006-01-02")]
+}
+
+// PhaseAt 返回 t 时刻的交易阶段
+func (c *Calendar) PhaseAt(m Market, t time.Time) Phase {
+	t = t.In(ShanghaiLocation)
+	if !c.IsTradingDay(m, t) {
+		return PhaseClosed
+	}
+	offset := t.Sub(startOfDay(t))
+	for _, s := range c.Sessions(m) {
+		if offset >= s.Start && offset < s.End {
+			return s.Phase
+		}
+	}
+	return PhaseClosed
+}
+
+// NextTradingDay 返回 t 所在日之后（不含当天）的下一个交易日零点
+func (c *Calendar) NextTradingDay(m Market, t time.Time) time.Time {
+	day := startOfDay(t.In(ShanghaiLocation))
+	for i := 0; i < 366; i++ {
+		day = day.AddDate(0, 0, 1)
+		if c.IsTradingDay(m, day) {
+			return day
+		}
+	}
+	return time.Time{}
+}
+
+// PrevTradingDay 返回 t 所在日之前（不含当天）的上一个交易日零点
+func (c *Calendar) PrevTradingDay(m Market, t time.Time) time.Time {
+	day := startOfDay(t.In(ShanghaiLocation))
+	for i := 0; i < 366; i++ {
+		day = day.AddDate(0, 0, -1)
+		if c.IsTradingDay(m, day) {
+			return day
+		}
+	}
+	return time.Time{}
+}
+
+// OpenTime 返回交易日 day 的第一个时段开始时间（开盘集合竞价）
+func (c *Calendar) OpenTime(m Market, day time.Time) time.Time {
+	sessions := c.Sessions(m)
+	return startOfDay(day.In(ShanghaiLocation)).Add(sessions[0].Start)
+}
+
+// CloseTime 返回交易日 day 的最后一个时段结束时间
+func (c *Calendar) CloseTime(m Market, day time.Time) time.Time {
+	sessions := c.Sessions(m)
+	return startOfDay(day.In(ShanghaiLocation)).Add(sessions[len(sessions)-1].End)
+}
+
+// NextSession 返回 t 之后（含 t 所在且尚未收盘的交易日）最近一个交易日的开盘与收盘时间
+func (c *Calendar) NextSession(m Market, t time.Time) (openAt, closeAt time.Time) {
+	t = t.In(ShanghaiLocation)
+	day := startOfDay(t)
+	if !c.IsTradingDay(m, day) || !t.Before(c.CloseTime(m, day)) {
+		day = c.NextTradingDay(m, day)
+	}
+	return c.OpenTime(m, day), c.CloseTime(m, day)
+}
+
+// TradingDates 返回按市场 m 的交易日判断的 TradingDateSource：交易日返回当天，非交易日返回上一个交易日
+func (c *Calendar) TradingDates(m Market) TradingDateSource {
+	return marketDates{calendar: c, market: m}
+}
+
+// marketDates 某个市场的交易日来源
+type marketDates struct {
+	calendar *Calendar
+	market   Market
+}
+
+// TradingDate 实现 TradingDateSource
+func (d marketDates) TradingDate(ref time.Time) time.Time {
+	day := startOfDay(ref.In(ShanghaiLocation))
+	if d.calendar.IsTradingDay(d.market, day) {
+		return day
+	}
+	return d.calendar.PrevTradingDay(d.market, day)
+}
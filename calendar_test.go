@@ -0,0 +1,149 @@
+package dtraderhq
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func cst(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, ShanghaiLocation)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseCalendar(t *testing.T) {
+	cal, err := ParseCalendar(strings.NewReader(`
+# 注释
+2025-01-01 元旦
+2025-01-28~2025-01-30 春节   # 行尾注释
+2025-06-30 BJ 仅北交所休市
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		market  Market
+		day     string
+		trading bool
+	}{
+		{MarketSH, "2025-01-01 10:00", false},
+		{MarketSH, "2025-01-29 10:00", false},
+		{MarketSH, "2025-01-31 10:00", true},
+		{MarketSH, "2025-02-01 10:00", false}, // 周六
+		{MarketSH, "2025-06-30 10:00", true},
+		{MarketBJ, "2025-06-30 10:00", false},
+	}
+	for _, tt := range tests {
+		if got := cal.IsTradingDay(tt.market, cst(tt.day)); got != tt.trading {
+			t.Errorf("IsTradingDay(%s, %s) = %v, want %v", tt.market, tt.day, got, tt.trading)
+		}
+	}
+	if name := cal.HolidayName(MarketSZ, cst("2025-01-29 00:00")); name != "春节" {
+		t.Errorf("HolidayName = %q", name)
+	}
+
+	for _, bad := range []string{"2025-13-01", "2025-01-05~2025-01-01"} {
+		if _, err := ParseCalendar(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseCalendar(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestLoadCalendarExample(t *testing.T) {
+	cal, err := LoadCalendar("examples/holidays_2025.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cal.IsTradingDay(MarketSH, cst("2025-10-08 10:00")) || !cal.IsTradingDay(MarketSH, cst("2025-10-09 10:00")) {
+		t.Fatal("national day holiday not loaded")
+	}
+}
+
+func TestPhaseAt(t *testing.T) {
+	cal := NewCalendar()
+	tests := map[string]Phase{
+		"2025-06-27 09:14": PhaseClosed,
+		"2025-06-27 09:15": PhaseOpeningAuction,
+		"2025-06-27 09:27": PhasePreOpen,
+		"2025-06-27 10:00": PhaseContinuous,
+		"2025-06-27 12:00": PhaseLunchBreak,
+		"2025-06-27 14:58": PhaseClosingAuction,
+		"2025-06-27 15:00": PhaseClosed,
+		"2025-06-28 10:00": PhaseClosed, // 周六
+	}
+	for at, want := range tests {
+		if got := cal.PhaseAt(MarketSZ, cst(at)); got != want {
+			t.Errorf("PhaseAt(%s) = %v, want %v", at, got, want)
+		}
+	}
+}
+
+func TestNextSession(t *testing.T) {
+	cal := NewCalendar()
+	cal.AddHoliday(cst("2025-06-30 00:00"), "test")
+
+	tests := []struct {
+		at, open, close string
+	}{
+		{"2025-06-27 08:00", "2025-06-27 09:15", "2025-06-27 15:00"},
+		{"2025-06-27 14:59", "2025-06-27 09:15", "2025-06-27 15:00"}, // 尚未收盘
+		{"2025-06-27 15:00", "2025-07-01 09:15", "2025-07-01 15:00"}, // 跳过周末和休市日
+	}
+	for _, tt := range tests {
+		openAt, closeAt := cal.NextSession(MarketSH, cst(tt.at))
+		if !openAt.Equal(cst(tt.open)) || !closeAt.Equal(cst(tt.close)) {
+			t.Errorf("NextSession(%s) = %s - %s", tt.at, openAt, closeAt)
+		}
+	}
+}
+
+func TestMarketOf(t *testing.T) {
+	tests := map[string]Market{
+		"SZ002240": MarketSZ,
+		"sh600000": MarketSH,
+		"600000":   MarketSH,
+		"300750":   MarketSZ,
+		"830799":   MarketBJ,
+		"":         "",
+	}
+	for code, want := range tests {
+		if got := MarketOf(code); got != want {
+			t.Errorf("MarketOf(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestCalendarTradingDates(t *testing.T) {
+	cal := NewCalendar()
+	cal.AddHoliday(cst("2025-06-27 00:00"), "test", MarketSZ)
+	tests := []struct {
+		market Market
+		ref    string
+		want   string
+	}{
+		{MarketSH, "2025-06-28 10:00", "2025-06-27 00:00"},
+		{MarketSH, "2025-06-27 10:00", "2025-06-27 00:00"},
+		{MarketSZ, "2025-06-27 10:00", "2025-06-26 00:00"},
+		{MarketSZ, "2025-06-28 10:00", "2025-06-26 00:00"},
+	}
+	for _, tt := range tests {
+		if got := cal.TradingDates(tt.market).TradingDate(cst(tt.ref)); !got.Equal(cst(tt.want)) {
+			t.Errorf("%s TradingDate(%s) = %s, want %s", tt.market, tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestParseMarket(t *testing.T) {
+	for s, want := range map[string]Market{"SH": MarketSH, "sz": MarketSZ, "Bj": MarketBJ} {
+		if got, err := ParseMarket(s); err != nil || got != want {
+			t.Errorf("ParseMarket(%q) = %q, %v", s, got, err)
+		}
+	}
+	if _, err := ParseMarket("HK"); err == nil {
+		t.Error("unknown market accepted")
+	}
+}
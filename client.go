@@ -1,11 +1,15 @@
 package dtraderhq
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/url"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -26,6 +30,9 @@ const (
 	MessageTypePong             = "pong"
 )
 
+// MaxBatchSize 批量订阅、批量取消订阅和重置订阅单次允许的最大股票数
+const MaxBatchSize = 100
+
 // Message WebSocket消息结构
 type Message struct {
 	Type      string      `json:"type"`
@@ -71,6 +78,9 @@ type MarketData struct {
 	DataType  int         `json:"data_type"`
 	Data      interface{} `json:"data"`
 	Timestamp int64       `json:"timestamp"`
+
+	// ReceivedAt 客户端收到该帧的时间，不参与序列化
+	ReceivedAt time.Time `json:"-"`
 }
 
 // BatchSubscribeResult 批量订阅结果
@@ -97,6 +107,133 @@ type ResetResult struct {
 	ErrorList      []map[string]interface{} `json:"error_list"`
 }
 
+// BackpressurePolicy 数据通道已满时的处理策略
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock 阻塞读取，直到消费者取走数据（默认）
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureDropNewest 丢弃新到达的数据帧
+	BackpressureDropNewest
+	// BackpressureDropOldest 丢弃通道中最早的数据帧，为新数据腾出空间
+	BackpressureDropOldest
+)
+
+// KeepaliveMode 心跳方式
+type KeepaliveMode int
+
+const (
+	// KeepaliveJSON 发送 {"type":"ping"} 消息，等待 {"type":"pong"}（默认）
+	KeepaliveJSON KeepaliveMode = iota
+	// KeepaliveControl 发送 WebSocket 协议层的 ping 控制帧，等待 pong 控制帧
+	KeepaliveControl
+	// KeepaliveBoth 同时发送两种 ping，收到任一种 pong 即可
+	KeepaliveBoth
+)
+
+// String 返回心跳方式名称
+func (m KeepaliveMode) String() string {
+	switch m {
+	case KeepaliveControl:
+		return "control"
+	case KeepaliveBoth:
+		return "both"
+	default:
+		return "json"
+	}
+}
+
+// ParseKeepaliveMode 解析心跳方式名称：json、control 或 both
+func ParseKeepaliveMode(s string) (KeepaliveMode, error) {
+	switch s {
+	case "", "json":
+		return KeepaliveJSON, nil
+	case "control":
+		return KeepaliveControl, nil
+	case "both":
+		return KeepaliveBoth, nil
+	}
+	return 0, fmt.Errorf("unknown keepalive mode %q", s)
+}
+
+// Option 客户端配置项
+type Option func(*Client)
+
+// WithDataBuffer 设置数据通道容量（默认100）
+func WithDataBuffer(size int) Option {
+	return func(c *Client) {
+		c.dataChan = make(chan *MarketData, size)
+	}
+}
+
+// WithErrorBuffer 设置错误通道容量（默认10）
+func WithErrorBuffer(size int) Option {
+	return func(c *Client) {
+		c.errorChan = make(chan error, size)
+	}
+}
+
+// WithPingInterval 设置心跳间隔（默认30秒）
+func WithPingInterval(d time.Duration) Option {
+	return func(c *Client) {
+		c.pingInterval = d
+	}
+}
+
+// WithPongTimeout 设置等待 pong 的超时，超时视为连接已失效并断开（默认10秒）；0 表示不检查 pong
+func WithPongTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.pongTimeout = d
+	}
+}
+
+// WithReadTimeout 设置读取超时，超过该时间没有收到任何消息即断开连接；
+// 默认为心跳间隔加 pong 超时，不检查 pong 时默认不设置
+func WithReadTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.readTimeout = d
+	}
+}
+
+// WithKeepalive 设置心跳方式（默认 KeepaliveJSON），应与服务器支持的方式一致。
+// 无论哪种方式，服务器发来的 ping 控制帧都会以 pong 控制帧应答
+func WithKeepalive(mode KeepaliveMode) Option {
+	return func(c *Client) {
+		c.keepalive = mode
+	}
+}
+
+// WithAutoReconnect 连接意外断开后自动重连、认证并恢复订阅，调用 Close 后不再重连
+func WithAutoReconnect() Option {
+	return func(c *Client) {
+		c.autoReconnect = true
+	}
+}
+
+// WithSilenceWatchdog 将收到的数据帧交给 w 统计各股票的数据中断情况，见 SilenceWatchdog
+func WithSilenceWatchdog(w *SilenceWatchdog) Option {
+	return func(c *Client) {
+		c.watchdog = w
+	}
+}
+
+// WithBackpressure 设置数据通道已满时的处理策略
+func WithBackpressure(policy BackpressurePolicy) Option {
+	return func(c *Client) {
+		c.backpressure = policy
+	}
+}
+
+// WithBatchSize 设置 SubscribeAll 和 Resubscribe 每批发送的股票数，n 超出 1 到 MaxBatchSize 时使用 MaxBatchSize
+func WithBatchSize(n int) Option {
+	return func(c *Client) {
+		c.batchSize = n
+	}
+}
+
+// controlWriteTimeout 发送控制帧的超时
+const controlWriteTimeout = 5 * time.Second
+
 // Client DTraderHQ WebSocket客户端
 type Client struct {
 	url             string
@@ -106,20 +243,59 @@ type Client struct {
 	isAuthenticated bool
 	dataChan        chan *MarketData
 	errorChan       chan error
-	closeChan       chan struct{}
-	pingTicker      *time.Ticker
+	closeChan       chan struct{}    // 当前连接断开（主动关闭或读取失败）时关闭
+	authChan        chan struct{}    // 认证成功后关闭
+	writeMu         sync.Mutex       // WebSocket 连接不支持并发写
 	subscriptions   map[string][]int // stockCode -> dataTypes
+	clock           *ExchangeClock
+	backpressure    BackpressurePolicy
+	droppedFrames   atomic.Uint64
+	decodeErrors    atomic.Uint64
+	decodeDropped   atomic.Uint64
+	deadLetter      *deadLetter
+	frameTap        func(Frame)
+	schema          *SchemaChecker
+	schemaMode      SchemaMode
+	authReq         *pendingRequest // 已发送、尚未收到应答的认证请求，同时按发送顺序登记在 pending 中
+	authErr         error           // 当前连接认证失败的原因
+	tokens          TokenProvider
+	tokenRefresh    time.Duration
+	pending         []*pendingRequest // 已发送、尚未收到应答的订阅类请求
+	ackTimeout      time.Duration
+	batchSize       int
+	pongWaiters     []chan struct{} // 等待下一个 pong 的 Ping 调用
+	lastRTT         atomic.Int64    // 最近一次 Ping 的往返时间（纳秒）
+	lastDataAt      atomic.Int64    // 最近一次收到数据帧的时间（Unix 纳秒）
+	keepalive       KeepaliveMode
+	pingInterval    time.Duration
+	pongTimeout     time.Duration
+	readTimeout     time.Duration
+	autoReconnect   bool
+	token           string // 最近一次认证使用的 token，用于自动重连
+	userClosed      bool   // 调用过 Close，不再自动重连
+	watchdog        *SilenceWatchdog
+	log             *slog.Logger
+	metrics         *Metrics
+	hasConnected    bool // 已成功连接过，之后的连接计为重连
 }
 
 // NewClient 创建新的DTraderHQ客户端
-func NewClient(serverURL string) *Client {
-	return &Client{
+func NewClient(serverURL string, opts ...Option) *Client {
+	c := &Client{
 		url:           serverURL,
 		dataChan:      make(chan *MarketData, 100),
 		errorChan:     make(chan error, 10),
 		closeChan:     make(chan struct{}),
 		subscriptions: make(map[string][]int),
+		clock:         NewExchangeClock(),
+		pingInterval:  30 * time.Second,
+		pongTimeout:   10 * time.Second,
+		log:           slog.New(discardHandler{}),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 // Connect 连接到服务器
@@ -128,7 +304,7 @@ func (c *Client) Connect() error {
 	defer c.mu.Unlock()
 
 	if c.isConnected {
-		return errors.New("already connected")
+		return ErrAlreadyConnected
 	}
 
 	u, err := url.Parse(c.url)
@@ -138,15 +314,33 @@ func (c *Client) Connect() error {
 
 	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
 	if err != nil {
+		c.loggerLocked().Warn("connect failed", slog.Any("error", err))
 		return fmt.Errorf("failed to connect: %w", err)
 	}
+	c.loggerLocked().Info("connected", slog.String("keepalive", c.keepalive.String()))
 
+	if c.hasConnected {
+		c.metrics.observeReconnect()
+	}
+	c.metrics.register(c)
+	c.hasConnected = true
 	c.conn = conn
 	c.isConnected = true
+	c.isAuthenticated = false
+	c.authReq = nil
+	c.authErr = nil
+	c.userClosed = false
+	c.closeChan = make(chan struct{})
+	c.authChan = make(chan struct{})
+	c.pongWaiters = nil
+	c.pending = nil
+
+	readTimeout := c.effectiveReadTimeout()
+	c.setControlHandlers(conn, readTimeout)
 
 	// 启动消息处理goroutine
-	go c.readMessages()
-	go c.pingLoop()
+	go c.readMessages(conn, c.closeChan, readTimeout)
+	go c.pingLoop(conn, c.closeChan)
 
 	return nil
 }
@@ -156,24 +350,121 @@ func (c *Client) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.isConnected {
+		c.loggerLocked().Info("connection closed")
+	}
+	c.userClosed = true
+	c.closeLocked()
+	c.metrics.unregister(c)
+	return nil
+}
+
+// closeLocked 关闭当前连接，调用方需持有写锁
+func (c *Client) closeLocked() {
 	if !c.isConnected {
-		return nil
+		return
 	}
 
 	c.isConnected = false
 	c.isAuthenticated = false
 
-	if c.pingTicker != nil {
-		c.pingTicker.Stop()
-	}
-
 	close(c.closeChan)
 
 	if c.conn != nil {
 		c.conn.Close()
 	}
+}
 
-	return nil
+// connectionLost 读取失败时关闭对应的连接，连接已被替换时不做处理
+func (c *Client) connectionLost(conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != conn || !c.isConnected {
+		return
+	}
+	reconnect := c.autoReconnect && !c.userClosed && (c.token != "" || c.tokens != nil)
+	c.loggerLocked().Warn("connection lost", slog.Bool("auto_reconnect", reconnect))
+	c.closeLocked()
+	if reconnect {
+		go c.autoReconnectLoop()
+	}
+}
+
+// autoReconnectLoop 按指数退避自动重连，直到成功或调用 Close
+func (c *Client) autoReconnectLoop() {
+	backoff := time.Second
+	for {
+		c.mu.RLock()
+		stop := c.userClosed || c.isConnected
+		token := c.token
+		if c.tokens != nil {
+			// 每次重连都从 TokenProvider 获取最新的 token
+			token = ""
+		}
+		c.mu.RUnlock()
+		if stop {
+			return
+		}
+
+		c.logger().Info("reconnecting")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := c.Reconnect(ctx, token)
+		cancel()
+		if err == nil {
+			c.logger().Info("reconnected", slog.Int("subscriptions", len(c.GetSubscriptions())))
+			return
+		}
+		// 连接已建立但认证或恢复订阅失败时关闭，下次重试重新连接
+		c.mu.Lock()
+		c.closeLocked()
+		c.mu.Unlock()
+		c.logger().Warn("reconnect failed", slog.Any("error", err), slog.Duration("backoff", backoff))
+		select {
+		case c.errorChan <- fmt.Errorf("auto reconnect: %w", err):
+		default:
+		}
+
+		time.Sleep(backoff)
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// effectiveReadTimeout 返回读取超时，未设置时为心跳间隔加 pong 超时，两者都未设置时为 0
+func (c *Client) effectiveReadTimeout() time.Duration {
+	if c.readTimeout > 0 || c.pongTimeout <= 0 {
+		return c.readTimeout
+	}
+	return c.pingInterval + c.pongTimeout
+}
+
+// Done 返回当前连接的断开通知通道，连接主动关闭或读取失败时关闭
+//
+// 每次 Connect 都会产生新的通道，断开后需重新调用 Done 获取。
+func (c *Client) Done() <-chan struct{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.closeChan
+}
+
+// Reconnect 关闭当前连接后重新连接、认证并恢复本地记录的全部订阅
+func (c *Client) Reconnect(ctx context.Context, token string) error {
+	c.mu.Lock()
+	c.closeLocked()
+	c.mu.Unlock()
+
+	if err := c.Connect(); err != nil {
+		return err
+	}
+	if err := c.Authenticate(token); err != nil {
+		return err
+	}
+	if err := c.WaitAuthenticated(ctx); err != nil {
+		return err
+	}
+	return c.Resubscribe()
 }
 
 // IsConnected 检查连接状态
@@ -190,34 +481,123 @@ func (c *Client) IsAuthenticated() bool {
 	return c.isAuthenticated
 }
 
-// Authenticate 进行认证
+// currentToken 返回最近一次认证使用的 token
+func (c *Client) currentToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// setAuthenticated 标记认证成功并唤醒等待者
+func (c *Client) setAuthenticated() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finishAuthLocked(nil)
+	if c.schema != nil {
+		// 每次认证成功（含重新认证）开始新的会话，字段差异重新报告
+		c.schema.Reset()
+	}
+	if c.isAuthenticated {
+		return
+	}
+	c.loggerLocked().Info("authenticated")
+	c.isAuthenticated = true
+	if c.authChan != nil {
+		close(c.authChan)
+	}
+	go c.tokenLoop(c.closeChan, c.token)
+}
+
+// WaitAuthenticated 等待认证完成，ctx 取消或连接关闭时返回错误
+func (c *Client) WaitAuthenticated(ctx context.Context) error {
+	c.mu.RLock()
+	authChan := c.authChan
+	closeChan := c.closeChan
+	c.mu.RUnlock()
+
+	if authChan == nil {
+		return ErrNotConnected
+	}
+
+	select {
+	case <-authChan:
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		return c.authErr
+	case <-closeChan:
+		return ErrConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Authenticate 进行认证，token 为空且设置了 TokenProvider 时从中获取
 func (c *Client) Authenticate(token string) error {
+	_, err := c.authenticate(token)
+	return err
+}
+
+// authenticate 发送认证请求，返回的通道在收到这次请求的应答时写入认证结果
+func (c *Client) authenticate(token string) (<-chan error, error) {
 	if !c.IsConnected() {
-		return errors.New("not connected")
+		return nil, ErrNotConnected
 	}
 
+	if token == "" && c.tokens != nil {
+		var err error
+		if token, err = c.fetchToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	req := &pendingRequest{op: MessageTypeAuth, sentAt: time.Now(), result: make(chan error, 1)}
+	c.mu.Lock()
+	c.token = token
+	if c.authReq != nil {
+		// 上一次认证请求由这次取代
+		c.removePendingLocked(c.authReq)
+	}
+	c.authReq = req
+	c.pending = append(c.pending, req)
+	if c.authErr != nil {
+		// 上次认证失败，重新等待这次的应答
+		c.authErr = nil
+		c.authChan = make(chan struct{})
+	}
+	c.mu.Unlock()
+
 	authMsg := AuthMessage{Token: token}
+	c.logger().Debug("authenticating", slog.Any("auth", authMsg))
 	msg := Message{
 		Type:      MessageTypeAuth,
 		Data:      authMsg,
 		Timestamp: time.Now().Unix(),
 	}
 
-	return c.sendMessage(msg)
+	return req.result, c.sendMessage(msg)
+}
+
+// finishAuthLocked 将认证结果交给最近一次认证请求，调用方需持有写锁
+func (c *Client) finishAuthLocked(err error) {
+	if c.authReq != nil {
+		c.removePendingLocked(c.authReq)
+		c.authReq.result <- err
+		c.authReq = nil
+	}
 }
 
 // BatchSubscribe 批量订阅股票数据
 func (c *Client) BatchSubscribe(subscriptions []SubscribeMessage) error {
 	if !c.IsAuthenticated() {
-		return errors.New("not authenticated")
+		return ErrNotAuthenticated
 	}
 
 	if len(subscriptions) == 0 {
-		return errors.New("subscriptions list is empty")
+		return ErrEmptyBatch
 	}
 
-	if len(subscriptions) > 100 {
-		return errors.New("subscriptions count exceeds limit (max 100)")
+	if len(subscriptions) > MaxBatchSize {
+		return fmt.Errorf("%w: %d subscriptions", ErrBatchTooLarge, len(subscriptions))
 	}
 
 	batchMsg := BatchSubscribeMessage{
@@ -229,28 +609,65 @@ func (c *Client) BatchSubscribe(subscriptions []SubscribeMessage) error {
 		Timestamp: time.Now().Unix(),
 	}
 
-	// 更新本地订阅记录
+	// 更新本地订阅记录，请求失败时恢复
 	c.mu.Lock()
-	for _, sub := range subscriptions {
-		c.subscriptions[sub.StockCode] = sub.DataTypes
-	}
+	undo := c.updateSubscriptionsLocked(subscriptions, nil)
 	c.mu.Unlock()
 
-	return c.sendMessage(msg)
+	return c.sendRequest(msg, subscriptionCodes(subscriptions), undo)
+}
+
+// SubscribeAll 订阅任意数量的股票，按 WithBatchSize 设置的数量（默认 MaxBatchSize）分批发送
+func (c *Client) SubscribeAll(subscriptions []SubscribeMessage) error {
+	size := c.subscribeBatchSize()
+	for start := 0; start < len(subscriptions); start += size {
+		end := start + size
+		if end > len(subscriptions) {
+			end = len(subscriptions)
+		}
+		if err := c.BatchSubscribe(subscriptions[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) subscribeBatchSize() int {
+	if c.batchSize <= 0 || c.batchSize > MaxBatchSize {
+		return MaxBatchSize
+	}
+	return c.batchSize
+}
+
+// Resubscribe 按本地记录重新发送全部订阅，用于重连后恢复
+func (c *Client) Resubscribe() error {
+	subs := c.GetSubscriptions()
+	if len(subs) == 0 {
+		return nil
+	}
+
+	subscriptions := make([]SubscribeMessage, 0, len(subs))
+	for stockCode, dataTypes := range subs {
+		subscriptions = append(subscriptions, SubscribeMessage{StockCode: stockCode, DataTypes: dataTypes})
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].StockCode < subscriptions[j].StockCode
+	})
+	return c.SubscribeAll(subscriptions)
 }
 
 // BatchUnsubscribe 批量取消订阅
 func (c *Client) BatchUnsubscribe(stockCodes []string) error {
 	if !c.IsAuthenticated() {
-		return errors.New("not authenticated")
+		return ErrNotAuthenticated
 	}
 
 	if len(stockCodes) == 0 {
-		return errors.New("stock codes list is empty")
+		return ErrEmptyBatch
 	}
 
-	if len(stockCodes) > 100 {
-		return errors.New("stock codes count exceeds limit (max 100)")
+	if len(stockCodes) > MaxBatchSize {
+		return fmt.Errorf("%w: %d stock codes", ErrBatchTooLarge, len(stockCodes))
 	}
 
 	batchMsg := BatchUnsubscribeMessage{
@@ -262,24 +679,22 @@ func (c *Client) BatchUnsubscribe(stockCodes []string) error {
 		Timestamp: time.Now().Unix(),
 	}
 
-	// 更新本地订阅记录
+	// 更新本地订阅记录，请求失败时恢复
 	c.mu.Lock()
-	for _, stockCode := range stockCodes {
-		delete(c.subscriptions, stockCode)
-	}
+	undo := c.updateSubscriptionsLocked(nil, stockCodes)
 	c.mu.Unlock()
 
-	return c.sendMessage(msg)
+	return c.sendRequest(msg, stockCodes, undo)
 }
 
 // ResetSubscriptions 重置订阅（取消所有当前订阅并设置新的订阅）
 func (c *Client) ResetSubscriptions(subscriptions []SubscribeMessage) error {
 	if !c.IsAuthenticated() {
-		return errors.New("not authenticated")
+		return ErrNotAuthenticated
 	}
 
-	if len(subscriptions) > 100 {
-		return errors.New("subscriptions count exceeds limit (max 100)")
+	if len(subscriptions) > MaxBatchSize {
+		return fmt.Errorf("%w: %d subscriptions", ErrBatchTooLarge, len(subscriptions))
 	}
 
 	resetMsg := ResetMessage{
@@ -291,21 +706,22 @@ func (c *Client) ResetSubscriptions(subscriptions []SubscribeMessage) error {
 		Timestamp: time.Now().Unix(),
 	}
 
-	// 重置本地订阅记录
+	// 重置本地订阅记录，请求失败时恢复
 	c.mu.Lock()
-	c.subscriptions = make(map[string][]int)
-	for _, sub := range subscriptions {
-		c.subscriptions[sub.StockCode] = sub.DataTypes
+	current := make([]string, 0, len(c.subscriptions))
+	for stockCode := range c.subscriptions {
+		current = append(current, stockCode)
 	}
+	undo := c.updateSubscriptionsLocked(subscriptions, current)
 	c.mu.Unlock()
 
-	return c.sendMessage(msg)
+	return c.sendRequest(msg, subscriptionCodes(subscriptions), undo)
 }
 
 // Subscribe 订阅股票数据
 func (c *Client) Subscribe(stockCode string, dataTypes []int) error {
 	if !c.IsAuthenticated() {
-		return errors.New("not authenticated")
+		return ErrNotAuthenticated
 	}
 
 	subMsg := SubscribeMessage{
@@ -319,16 +735,16 @@ func (c *Client) Subscribe(stockCode string, dataTypes []int) error {
 	}
 
 	c.mu.Lock()
-	c.subscriptions[stockCode] = dataTypes
+	undo := c.updateSubscriptionsLocked([]SubscribeMessage{subMsg}, nil)
 	c.mu.Unlock()
 
-	return c.sendMessage(msg)
+	return c.sendRequest(msg, []string{stockCode}, undo)
 }
 
 // Unsubscribe 取消订阅
 func (c *Client) Unsubscribe(stockCode string) error {
 	if !c.IsAuthenticated() {
-		return errors.New("not authenticated")
+		return ErrNotAuthenticated
 	}
 
 	unsubMsg := UnsubscribeMessage{StockCode: stockCode}
@@ -339,10 +755,10 @@ func (c *Client) Unsubscribe(stockCode string) error {
 	}
 
 	c.mu.Lock()
-	delete(c.subscriptions, stockCode)
+	undo := c.updateSubscriptionsLocked(nil, []string{stockCode})
 	c.mu.Unlock()
 
-	return c.sendMessage(msg)
+	return c.sendRequest(msg, []string{stockCode}, undo)
 }
 
 // DataChannel 获取数据通道
@@ -355,6 +771,25 @@ func (c *Client) ErrorChannel() <-chan error {
 	return c.errorChan
 }
 
+// Clock 获取用于转换交易所时间的时钟
+func (c *Client) Clock() *ExchangeClock {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.clock
+}
+
+// SetTradingDateSource 设置逐笔委托时间所用的交易日来源（默认为自然日）
+func (c *Client) SetTradingDateSource(src TradingDateSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = &ExchangeClock{Location: c.clock.location(), Dates: src}
+}
+
+// Timing 计算数据帧的接收时间与交易所时间
+func (c *Client) Timing(md *MarketData) (FrameTiming, error) {
+	return c.Clock().Timing(md)
+}
+
 // GetSubscriptions 获取当前订阅列表
 func (c *Client) GetSubscriptions() map[string][]int {
 	c.mu.RLock()
@@ -374,95 +809,152 @@ func (c *Client) sendMessage(msg Message) error {
 	c.mu.RUnlock()
 
 	if conn == nil {
-		return errors.New("connection is nil")
+		return ErrNotConnected
 	}
 
-	return conn.WriteJSON(msg)
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	err = conn.WriteMessage(websocket.TextMessage, data)
+	c.writeMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if c.frameTap != nil {
+		if auth, ok := msg.Data.(AuthMessage); ok {
+			msg.Data = AuthMessage{Token: redactToken(auth.Token)}
+			data, _ = json.Marshal(msg)
+		}
+		c.tap(FrameOutbound, websocket.TextMessage, data)
+	}
+	return nil
 }
 
-// readMessages 读取消息
-func (c *Client) readMessages() {
+// readMessages 读取消息，读取失败时关闭该连接
+func (c *Client) readMessages(conn *websocket.Conn, done chan struct{}, readTimeout time.Duration) {
+	defer c.connectionLost(conn)
+
 	for {
 		select {
-		case <-c.closeChan:
+		case <-done:
 			return
 		default:
-			c.mu.RLock()
-			conn := c.conn
-			c.mu.RUnlock()
-
-			if conn == nil {
-				return
+			if readTimeout > 0 {
+				conn.SetReadDeadline(time.Now().Add(readTimeout))
 			}
-
-			var msg Message
-			err := conn.ReadJSON(&msg)
+			messageType, raw, err := conn.ReadMessage()
 			if err != nil {
+				// 连接已被主动关闭时不报告读取错误
+				select {
+				case <-done:
+					return
+				default:
+				}
+				c.logger().Warn("read failed", slog.Any("error", err))
 				select {
 				case c.errorChan <- fmt.Errorf("read message error: %w", err):
-				case <-c.closeChan:
+				case <-done:
 				}
 				return
 			}
 
-			c.handleMessage(&msg)
+			c.metrics.observeRead(int64(len(raw)))
+			c.tap(FrameInbound, messageType, raw)
+			c.handleMessage(raw, done)
 		}
 	}
 }
 
-// handleMessage 处理接收到的消息
-func (c *Client) handleMessage(msg *Message) {
+// rawMessage 消息外层结构，data 字段按消息类型再解析
+type rawMessage struct {
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Timestamp int64           `json:"timestamp"`
+}
+
+// handleMessage 处理接收到的消息，无法解析的消息以 *DecodeError 报告
+func (c *Client) handleMessage(raw []byte, done chan struct{}) {
+	receivedAt := time.Now()
+
+	var env rawMessage
+	if err := json.Unmarshal(raw, &env); err != nil {
+		c.decodeFailed(&DecodeError{Err: err}, raw, receivedAt)
+		return
+	}
+	msg := &Message{Type: env.Type, Error: env.Error, Timestamp: env.Timestamp}
+	if env.Type != MessageTypeData && len(env.Data) > 0 {
+		// 外层已解析成功，data 必为合法 JSON
+		json.Unmarshal(env.Data, &msg.Data)
+	}
+
 	switch msg.Type {
-	case MessageTypeSuccess:
-		// 处理成功消息，包括认证成功
-		if dataMap, ok := msg.Data.(map[string]interface{}); ok {
-			if message, exists := dataMap["message"]; exists {
-				if messageStr, ok := message.(string); ok {
-					if messageStr == "认证成功" {
-						c.mu.Lock()
-						c.isAuthenticated = true
-						c.mu.Unlock()
-					}
-				}
+	case MessageTypeSuccess, MessageTypeAuth:
+		// 处理认证应答，其他成功消息只作为其标明的请求类型的应答
+		if !c.handleAuthReply(msg, done) && msg.Type == MessageTypeSuccess {
+			data, _ := msg.Data.(map[string]interface{})
+			if op := replyOp(data); op != "" {
+				c.resolveReply(op, nil)
 			}
 		}
 
-	case MessageTypeAuth:
-		// 处理认证响应
-		if dataMap, ok := msg.Data.(map[string]interface{}); ok {
-			if message, exists := dataMap["message"]; exists {
-				if messageStr, ok := message.(string); ok {
-					if messageStr == "认证成功" {
-						c.mu.Lock()
-						c.isAuthenticated = true
-						c.mu.Unlock()
-					}
-				}
+	case MessageTypeSubscribe, MessageTypeBatchSubscribe, MessageTypeUnsubscribe, MessageTypeBatchUnsubscribe, MessageTypeReset:
+		// 处理订阅相关的响应消息，结果中逐只股票的失败以 *SubscriptionError 报告
+		op := "subscribe"
+		switch msg.Type {
+		case MessageTypeUnsubscribe, MessageTypeBatchUnsubscribe:
+			op = "unsubscribe"
+		case MessageTypeReset:
+			op = "reset"
+		}
+		errs := subscriptionErrors(op, msg.Data)
+		c.logSubscriptionReply(op, msg, errs)
+		if c.resolveReply(msg.Type, errs) {
+			return
+		}
+		for _, err := range errs {
+			select {
+			case c.errorChan <- err:
+			case <-done:
+				return
 			}
 		}
 
-	case MessageTypeSubscribe, MessageTypeBatchSubscribe, MessageTypeUnsubscribe, MessageTypeBatchUnsubscribe, MessageTypeReset:
-		// 处理订阅相关的响应消息
-		// 这些消息通常包含操作结果，可以根据需要进行处理
-		// 目前只是静默处理，实际应用中可以添加回调或事件通知
-
 	case MessageTypeData:
 		// 处理市场数据
-		if dataBytes, err := json.Marshal(msg.Data); err == nil {
-			var marketData MarketData
-			if err := json.Unmarshal(dataBytes, &marketData); err == nil {
-				select {
-				case c.dataChan <- &marketData:
-				case <-c.closeChan:
-				}
-			}
+		var marketData MarketData
+		if err := json.Unmarshal(env.Data, &marketData); err != nil {
+			c.decodeFailed(&DecodeError{MessageType: msg.Type, Err: err}, raw, receivedAt)
+			return
+		}
+		marketData.ReceivedAt = receivedAt
+		c.lastDataAt.Store(receivedAt.UnixNano())
+		if c.metrics != nil {
+			c.metrics.observeFrame(&marketData, c.Clock())
+		}
+		if c.schema != nil && !c.checkSchema(&marketData, done) {
+			return
 		}
+		if c.watchdog != nil {
+			c.watchdog.Observe(&marketData)
+		}
+		c.deliver(&marketData, done)
 
 	case MessageTypeError:
-		select {
-		case c.errorChan <- errors.New(msg.Error):
-		case <-c.closeChan:
+		serverErr := newServerError(msg)
+		if c.isAuthError(serverErr) {
+			c.logger().Warn("server error", slog.String("code", serverErr.Code), slog.String("message", serverErr.Message))
+			c.authFailed(fmt.Errorf("%w: %w", ErrAuthFailed, serverErr), done)
+			return
 		}
+		c.routeServerError(serverErr, done)
+
+	case MessageTypePong:
+		c.pongReceived()
 
 	case MessageTypePing:
 		// 响应ping
@@ -471,29 +963,259 @@ func (c *Client) handleMessage(msg *Message) {
 			Timestamp: time.Now().Unix(),
 		}
 		c.sendMessage(pongMsg)
+
+	default:
+		c.decodeFailed(&DecodeError{MessageType: msg.Type, Err: ErrUnknownMessageType}, raw, receivedAt)
 	}
 }
 
-// pingLoop 心跳循环
-func (c *Client) pingLoop() {
-	c.pingTicker = time.NewTicker(30 * time.Second)
-	defer c.pingTicker.Stop()
+// decodeFailed 记录无法解析的消息：计数、写入死信并发送到错误通道，通道已满时丢弃报告，不阻塞读取
+func (c *Client) decodeFailed(decodeErr *DecodeError, raw []byte, receivedAt time.Time) {
+	decodeErr.Size = len(raw)
+	decodeErr.Raw = truncatePayload(raw)
+
+	c.decodeErrors.Add(1)
+	c.metrics.observeDecodeError()
+	c.logger().Warn("decode message failed",
+		slog.String("type", decodeErr.MessageType),
+		slog.Int("size", decodeErr.Size),
+		slog.Any("error", decodeErr.Err))
+	if c.deadLetter != nil {
+		if err := c.deadLetter.write(c.URL(), decodeErr, raw, receivedAt); err != nil {
+			c.logger().Warn("write dead letter failed", slog.Any("error", err))
+		}
+	}
+
+	select {
+	case c.errorChan <- decodeErr:
+	default:
+		c.logger().Warn("decode error report dropped",
+			slog.String("type", decodeErr.MessageType),
+			slog.Uint64("dropped_total", c.decodeDropped.Add(1)))
+	}
+}
+
+// DecodeErrors 返回无法解析的消息数量
+func (c *Client) DecodeErrors() uint64 {
+	return c.decodeErrors.Load()
+}
+
+// DroppedDecodeErrors 返回因错误通道已满而未发送到 ErrorChannel 的解析错误数量，这些消息仍计入 DecodeErrors
+func (c *Client) DroppedDecodeErrors() uint64 {
+	return c.decodeDropped.Load()
+}
+
+// deliver 按背压策略将数据帧放入数据通道
+func (c *Client) deliver(md *MarketData, done chan struct{}) {
+	switch c.backpressure {
+	case BackpressureDropNewest:
+		select {
+		case c.dataChan <- md:
+		default:
+			c.droppedFrames.Add(1)
+			c.frameDropped(md)
+		}
+
+	case BackpressureDropOldest:
+		for {
+			select {
+			case c.dataChan <- md:
+				return
+			default:
+			}
+			select {
+			case old := <-c.dataChan:
+				c.droppedFrames.Add(1)
+				c.frameDropped(old)
+			default:
+			}
+		}
+
+	default:
+		select {
+		case c.dataChan <- md:
+		case <-done:
+		}
+	}
+}
+
+// frameDropped 记录因数据通道已满而丢弃的数据帧
+func (c *Client) frameDropped(md *MarketData) {
+	c.metrics.observeDropped(md)
+	c.logger().Warn("data frame dropped",
+		slog.String("stock", md.StockCode),
+		slog.Int("data_type", md.DataType),
+		slog.Uint64("dropped_total", c.droppedFrames.Load()))
+}
+
+// DroppedFrames 返回因数据通道已满而丢弃的数据帧数量
+func (c *Client) DroppedFrames() uint64 {
+	return c.droppedFrames.Load()
+}
+
+// Ping 发送 ping 并等待服务器的 pong，返回往返时间
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	ch := make(chan struct{})
+	c.mu.Lock()
+	if !c.isConnected {
+		c.mu.Unlock()
+		return 0, ErrNotConnected
+	}
+	c.pongWaiters = append(c.pongWaiters, ch)
+	closeChan := c.closeChan
+	c.mu.Unlock()
+
+	start := time.Now()
+	if err := c.sendPing(); err != nil {
+		return 0, fmt.Errorf("ping error: %w", err)
+	}
+
+	select {
+	case <-ch:
+		rtt := time.Since(start)
+		c.lastRTT.Store(int64(rtt))
+		return rtt, nil
+	case <-closeChan:
+		return 0, ErrConnectionClosed
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// sendPing 按心跳方式发送 JSON ping 和/或 ping 控制帧
+func (c *Client) sendPing() error {
+	c.mu.RLock()
+	mode := c.keepalive
+	c.mu.RUnlock()
+
+	if mode != KeepaliveControl {
+		err := c.sendMessage(Message{
+			Type:      MessageTypePing,
+			Timestamp: time.Now().Unix(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if mode != KeepaliveJSON {
+		c.mu.RLock()
+		conn := c.conn
+		c.mu.RUnlock()
+		if conn == nil {
+			return ErrNotConnected
+		}
+		// WriteControl 可以与其他写操作并发调用
+		if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(controlWriteTimeout)); err != nil {
+			return err
+		}
+		c.tap(FrameOutbound, websocket.PingMessage, nil)
+	}
+	return nil
+}
+
+// pongReceived 收到 JSON pong 或 pong 控制帧时唤醒等待的 Ping
+func (c *Client) pongReceived() {
+	c.mu.Lock()
+	waiters := c.pongWaiters
+	c.pongWaiters = nil
+	c.mu.Unlock()
+	for _, ch := range waiters {
+		close(ch)
+	}
+}
+
+// setControlHandlers 设置 ping/pong 控制帧的处理：收到任何控制帧都延长读取期限，
+// ping 控制帧以 pong 控制帧应答，pong 控制帧用于心跳检测
+func (c *Client) setControlHandlers(conn *websocket.Conn, readTimeout time.Duration) {
+	extend := func() {
+		if readTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(readTimeout))
+		}
+	}
+
+	conn.SetPingHandler(func(appData string) error {
+		extend()
+		c.tap(FrameInbound, websocket.PingMessage, []byte(appData))
+		err := conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(controlWriteTimeout))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		if err == nil {
+			c.tap(FrameOutbound, websocket.PongMessage, []byte(appData))
+		}
+		return err
+	})
+	conn.SetPongHandler(func(appData string) error {
+		extend()
+		c.tap(FrameInbound, websocket.PongMessage, []byte(appData))
+		c.pongReceived()
+		return nil
+	})
+}
+
+// LastRTT 返回最近一次 Ping 的往返时间，尚未成功 Ping 时为 0
+func (c *Client) LastRTT() time.Duration {
+	return time.Duration(c.lastRTT.Load())
+}
+
+// LastDataAt 返回最近一次收到数据帧的时间，尚未收到时为零值
+func (c *Client) LastDataAt() time.Time {
+	if ns := c.lastDataAt.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+// setURL 更换服务器地址和心跳方式，下次 Connect 时生效
+func (c *Client) setURL(serverURL string, keepalive KeepaliveMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.url = serverURL
+	c.keepalive = keepalive
+}
+
+// URL 返回当前使用的服务器地址
+func (c *Client) URL() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.url
+}
+
+// pingLoop 心跳循环，pong 超时时断开该连接
+func (c *Client) pingLoop(conn *websocket.Conn, done chan struct{}) {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
-		case <-c.pingTicker.C:
-			pingMsg := Message{
-				Type:      MessageTypePing,
-				Timestamp: time.Now().Unix(),
+		case <-ticker.C:
+			var err error
+			if c.pongTimeout > 0 {
+				ctx, cancel := context.WithTimeout(context.Background(), c.pongTimeout)
+				_, err = c.Ping(ctx)
+				cancel()
+				if errors.Is(err, context.DeadlineExceeded) {
+					err = fmt.Errorf("%w after %s", ErrPongTimeout, c.pongTimeout)
+				}
+			} else {
+				err = c.sendPing()
 			}
-			if err := c.sendMessage(pingMsg); err != nil {
+			if err != nil {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				c.logger().Warn("keepalive failed", slog.Any("error", err))
 				select {
 				case c.errorChan <- fmt.Errorf("ping error: %w", err):
-				case <-c.closeChan:
+				case <-done:
 				}
+				c.connectionLost(conn)
 				return
 			}
-		case <-c.closeChan:
+		case <-done:
 			return
 		}
 	}
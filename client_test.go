@@ -0,0 +1,209 @@
+package dtraderhq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSubscribeAllBatchSize(t *testing.T) {
+	subs := make([]SubscribeMessage, 5)
+	for i := range subs {
+		subs[i] = SubscribeMessage{StockCode: fmt.Sprintf("SZ00000%d", i), DataTypes: []int{DataTypeTransaction}}
+	}
+
+	tests := []struct {
+		size    int
+		batches int
+	}{
+		{0, 1}, // 默认 MaxBatchSize
+		{2, 3},
+		{MaxBatchSize + 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.size), func(t *testing.T) {
+			srv := newTestServer(t)
+			c := connectTestClient(t, srv, WithBatchSize(tt.size))
+			if err := c.SubscribeAll(subs); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, "batch_subscribe", func() bool { return len(srv.messages(MessageTypeBatchSubscribe)) == tt.batches })
+			if got := len(c.GetSubscriptions()); got != len(subs) {
+				t.Fatalf("subscriptions = %d, want %d", got, len(subs))
+			}
+		})
+	}
+}
+
+// 默认检查 pong，WithPongTimeout(0) 时服务器不回复 pong 也保持连接
+func TestPongTimeout(t *testing.T) {
+	if c := NewClient("ws://127.0.0.1:1"); c.pongTimeout != 10*time.Second || c.effectiveReadTimeout() != 40*time.Second {
+		t.Fatalf("default pong timeout = %s, read timeout = %s", c.pongTimeout, c.effectiveReadTimeout())
+	}
+	if c := NewClient("ws://127.0.0.1:1", WithPongTimeout(0)); c.effectiveReadTimeout() != 0 {
+		t.Fatalf("read timeout without pong check = %s", c.effectiveReadTimeout())
+	}
+
+	srv := newTestServer(t)
+	srv.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		return msg["type"] == MessageTypePing
+	})
+	c := connectTestClient(t, srv, WithPingInterval(10*time.Millisecond), WithPongTimeout(0))
+	time.Sleep(100 * time.Millisecond)
+	if !c.IsConnected() || len(srv.messages(MessageTypePing)) == 0 {
+		t.Fatalf("connected = %v, pings = %d", c.IsConnected(), len(srv.messages(MessageTypePing)))
+	}
+
+	// 读取超时单独设置，只由 pong 超时断开
+	c = connectTestClient(t, srv, WithPingInterval(10*time.Millisecond), WithPongTimeout(30*time.Millisecond), WithReadTimeout(time.Hour))
+	select {
+	case err := <-c.ErrorChannel():
+		if !errors.Is(err, ErrPongTimeout) {
+			t.Fatalf("error = %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("pong timeout not reported")
+	}
+	waitFor(t, "disconnect", func() bool { return !c.IsConnected() })
+}
+
+func TestReadTimeoutDisconnects(t *testing.T) {
+	srv := newTestServer(t)
+	c := connectTestClient(t, srv, WithPingInterval(time.Hour), WithReadTimeout(50*time.Millisecond))
+	select {
+	case <-c.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("idle connection not closed")
+	}
+}
+
+func TestAutoReconnectRestoresSubscriptions(t *testing.T) {
+	srv := newTestServer(t)
+	c := connectTestClient(t, srv, WithAutoReconnect())
+	if err := c.Subscribe("SZ000001", []int{4}); err != nil {
+		t.Fatal(err)
+	}
+	done := c.Done()
+
+	srv.dropConnections()
+	<-done
+	waitFor(t, "resubscribe", func() bool { return len(srv.messages(MessageTypeBatchSubscribe)) == 1 })
+	if !c.IsAuthenticated() || len(srv.messages(MessageTypeAuth)) != 2 {
+		t.Fatalf("authenticated = %v, auth requests = %d", c.IsAuthenticated(), len(srv.messages(MessageTypeAuth)))
+	}
+
+	// Close 之后不再重连
+	c.Close()
+	srv.dropConnections()
+	time.Sleep(50 * time.Millisecond)
+	if c.IsConnected() {
+		t.Fatal("reconnected after Close")
+	}
+}
+
+func TestParseKeepaliveMode(t *testing.T) {
+	for _, mode := range []KeepaliveMode{KeepaliveJSON, KeepaliveControl, KeepaliveBoth} {
+		if got, err := ParseKeepaliveMode(mode.String()); err != nil || got != mode {
+			t.Errorf("ParseKeepaliveMode(%q) = %v, %v", mode, got, err)
+		}
+	}
+	if got, err := ParseKeepaliveMode(""); err != nil || got != KeepaliveJSON {
+		t.Errorf("ParseKeepaliveMode(\"\") = %v, %v", got, err)
+	}
+	if _, err := ParseKeepaliveMode("tcp"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+// keepaliveServer 只以收到 ping 的方式回复 pong，统计两种 ping 的数量
+type keepaliveServer struct {
+	*httptest.Server
+	jsonPings, controlPings, clientPongs atomic.Int32
+	pingClient                           chan struct{}
+}
+
+func newKeepaliveServer(t *testing.T) *keepaliveServer {
+	s := &keepaliveServer{pingClient: make(chan struct{}, 1)}
+	upgrader := websocket.Upgrader{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetPingHandler(func(data string) error {
+			s.controlPings.Add(1)
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		conn.SetPongHandler(func(string) error {
+			s.clientPongs.Add(1)
+			return nil
+		})
+		go func() {
+			for range s.pingClient {
+				conn.WriteControl(websocket.PingMessage, []byte("server"), time.Now().Add(time.Second))
+			}
+		}()
+		for {
+			_, b, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg map[string]any
+			json.Unmarshal(b, &msg)
+			if msg["type"] == MessageTypePing {
+				s.jsonPings.Add(1)
+				conn.WriteJSON(map[string]any{"type": MessageTypePong})
+			}
+		}
+	}))
+	t.Cleanup(func() {
+		close(s.pingClient)
+		s.Close()
+	})
+	return s
+}
+
+func TestKeepaliveModes(t *testing.T) {
+	tests := []struct {
+		mode          KeepaliveMode
+		json, control int32
+	}{
+		{KeepaliveJSON, 1, 0},
+		{KeepaliveControl, 0, 1},
+		{KeepaliveBoth, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			srv := newKeepaliveServer(t)
+			c := NewClient("ws"+strings.TrimPrefix(srv.URL, "http"), WithKeepalive(tt.mode), WithPingInterval(time.Hour))
+			if err := c.Connect(); err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			rtt, err := c.Ping(ctx)
+			if err != nil || rtt <= 0 || c.LastRTT() != rtt {
+				t.Fatalf("Ping = %s, %v", rtt, err)
+			}
+			waitFor(t, "pings", func() bool {
+				return srv.jsonPings.Load() == tt.json && srv.controlPings.Load() == tt.control
+			})
+
+			// 服务器的 ping 控制帧总以 pong 控制帧应答
+			srv.pingClient <- struct{}{}
+			waitFor(t, "pong from client", func() bool { return srv.clientPongs.Load() == 1 })
+		})
+	}
+}
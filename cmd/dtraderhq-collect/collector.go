@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+	"github.com/DTrader-store/level2-client-go/store"
+)
+
+// counts 单只股票、单个数据类型的统计
+type counts struct {
+	frames  int64
+	records int64
+}
+
+// collector 消费客户端数据并写入记录器
+type collector struct {
+	recorder      store.Sink
+	flushInterval time.Duration
+	watcher       *dtraderhq.SymbolWatcher // 非空时监视股票列表文件并热更新订阅
+
+	mu    sync.Mutex
+	stats map[string]map[int]*counts // stockCode -> dataType -> counts
+}
+
+func newCollector(recorder store.Sink) *collector {
+	return &collector{
+		recorder:      recorder,
+		flushInterval: 5 * time.Second,
+		stats:         make(map[string]map[int]*counts),
+	}
+}
+
+// run 消费数据直到 ctx 取消，连接断开时自动重连并恢复订阅
+func (c *collector) run(ctx context.Context, client *dtraderhq.Client) {
+	flush := time.NewTicker(c.flushInterval)
+	defer flush.Stop()
+
+	if c.watcher != nil {
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go c.watcher.Run(watchCtx, client)
+	}
+
+	done := client.Done()
+	for {
+		select {
+		case data := <-client.DataChannel():
+			c.write(data)
+
+		case err := <-client.ErrorChannel():
+			log.Printf("客户端错误: %v", err)
+
+		case <-done:
+			if ctx.Err() != nil {
+				return
+			}
+			log.Println("连接已断开，开始重连...")
+			if err := c.reconnect(ctx, client); err != nil {
+				return
+			}
+			log.Println("重连成功，订阅已恢复")
+			done = client.Done()
+
+		case <-flush.C:
+			if err := c.recorder.Flush(); err != nil {
+				log.Printf("刷新文件失败: %v", err)
+			}
+
+		case <-ctx.Done():
+			c.drain(client)
+			return
+		}
+	}
+}
+
+// reconnect 按指数退避重连，直到成功或 ctx 取消
+func (c *collector) reconnect(ctx context.Context, client *dtraderhq.Client) error {
+	backoff := time.Second
+	for {
+		attemptCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		// 客户端设置了 TokenProvider，重连时重新获取 token
+		err := client.Reconnect(attemptCtx, "")
+		cancel()
+		if err == nil {
+			return nil
+		}
+		log.Printf("重连失败: %v，%s 后重试", err, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// logSubscriptionChange 记录股票列表热更新的结果
+func logSubscriptionChange(diff dtraderhq.SubscriptionDiff, err error) {
+	if err != nil {
+		log.Printf("更新订阅失败: %v", err)
+		return
+	}
+	log.Printf("订阅已更新: 新增 %d，取消 %d，修改 %d（%s）", len(diff.Added), len(diff.Removed), len(diff.Changed), diff)
+}
+
+// drain 退出前写完通道中已缓冲的数据
+func (c *collector) drain(client *dtraderhq.Client) {
+	for {
+		select {
+		case data := <-client.DataChannel():
+			c.write(data)
+		default:
+			return
+		}
+	}
+}
+
+func (c *collector) write(data *dtraderhq.MarketData) {
+	if err := c.recorder.Write(data); err != nil {
+		log.Printf("写入数据失败 %s/%d: %v", data.StockCode, data.DataType, err)
+		return
+	}
+
+	records := 1
+	if frame, err := dtraderhq.DecodeFrame(data); err == nil {
+		if n := len(frame.Transactions) + len(frame.BigOrders) + len(frame.Orders); n > 0 {
+			records = n
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	byType := c.stats[data.StockCode]
+	if byType == nil {
+		byType = make(map[int]*counts)
+		c.stats[data.StockCode] = byType
+	}
+	cnt := byType[data.DataType]
+	if cnt == nil {
+		cnt = &counts{}
+		byType[data.DataType] = cnt
+	}
+	cnt.frames++
+	cnt.records += int64(records)
+}
+
+// printSummary 输出每只股票、每个数据类型的帧数和记录数
+func (c *collector) printSummary(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	codes := make([]string, 0, len(c.stats))
+	for code := range c.stats {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	fmt.Fprintln(w, "采集汇总：")
+	fmt.Fprintf(w, "%-10s %-12s %10s %10s\n", "股票", "数据类型", "帧数", "记录数")
+	var totalFrames, totalRecords int64
+	for _, code := range codes {
+		types := make([]int, 0, len(c.stats[code]))
+		for dt := range c.stats[code] {
+			types = append(types, dt)
+		}
+		sort.Ints(types)
+		for _, dt := range types {
+			cnt := c.stats[code][dt]
+			fmt.Fprintf(w, "%-10s %-12s %10d %10d\n", code, store.DataTypeName(dt), cnt.frames, cnt.records)
+			totalFrames += cnt.frames
+			totalRecords += cnt.records
+		}
+	}
+	fmt.Fprintf(w, "%-10s %-12s %10d %10d\n", "合计", "", totalFrames, totalRecords)
+}
@@ -0,0 +1,308 @@
+// dtraderhq-collect 订阅行情并按股票/数据类型/日期落盘
+//
+//	export DTRADERHQ_TOKEN=...
+//	dtraderhq-collect -url ws://127.0.0.1:8080/ws -symbols symbols.txt -types 4,8,14 -out ./stock_data
+//
+// 也可以用 -config 指定 YAML/TOML 配置文件，其中的服务器、认证、订阅和落盘设置取代对应参数：
+//
+//	dtraderhq-collect -config collector.yaml -until 15:05
+//
+// 运行时长由 -duration 或 -until 控制；指定 -calendar 时按 -market 所在市场的交易日历在每个交易日开盘前连接、收盘后断开。
+// 收到 SIGINT/SIGTERM 时写完已缓冲的数据、关闭文件并输出每只股票的记录数汇总。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+	"github.com/DTrader-store/level2-client-go/config"
+	"github.com/DTrader-store/level2-client-go/store"
+)
+
+func main() {
+	serverURL := flag.String("url", "ws://127.0.0.1:8080/ws", "行情服务器地址")
+	symbolsFile := flag.String("symbols", "symbols.txt", "股票列表文件，每行一个代码，可在代码后指定数据类型")
+	types := flag.String("types", "4,8,14", "默认订阅的数据类型，逗号分隔")
+	outDir := flag.String("out", "./stock_data", "输出目录")
+	format := flag.String("format", "jsonl", "存储格式：jsonl 或 binary")
+	duration := flag.Duration("duration", 0, "运行时长，0 表示不限")
+	until := flag.String("until", "", "运行到当天的指定时刻（交易所时间，HH:MM）")
+	calendarFile := flag.String("calendar", "", "休市文件，指定后按交易日历自动连接和断开")
+	marketName := flag.String("market", "SH", "按哪个市场的交易日历运行：SH、SZ 或 BJ")
+	tokenEnv := flag.String("token-env", "DTRADERHQ_TOKEN", "读取认证 token 的环境变量")
+	tokenFile := flag.String("token-file", "", "读取认证 token 的文件，优先于 -token-env")
+	watch := flag.Bool("watch", false, "监视股票列表文件，修改后不断开连接直接更新订阅")
+	configFile := flag.String("config", "", "YAML/TOML 配置文件，指定后忽略 -url、-symbols、-types、-out、-format 和 token 参数")
+	logLevel := flag.String("log-level", "", "输出客户端日志的级别：debug、info、warn 或 error，为空时不输出")
+	deadLetterFile := flag.String("dead-letter", "", "将无法解析的消息追加写入该文件，每行一个 JSON 对象")
+	traceFile := flag.String("trace", "", "将收发的原始帧追加写入该文件（token 已脱敏），用于排查协议问题")
+	schemaMode := flag.String("schema", "", "检查数据字段是否与定义一致：lenient 只报告，strict 报告并丢弃不一致的数据帧，为空时不检查")
+	metricsAddr := flag.String("metrics-addr", "", "在该地址的 /metrics 上以 Prometheus 文本格式导出客户端指标，如 :9100")
+	flag.Parse()
+
+	var clientOpts []dtraderhq.Option
+	if *logLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+			log.Fatalf("invalid -log-level: %v", err)
+		}
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+		clientOpts = append(clientOpts, dtraderhq.WithLogger(logger))
+	}
+	if *schemaMode != "" {
+		mode, err := dtraderhq.ParseSchemaMode(*schemaMode)
+		if err != nil {
+			log.Fatalf("invalid -schema: %v", err)
+		}
+		clientOpts = append(clientOpts, dtraderhq.WithSchemaCheck(mode))
+	}
+	if *deadLetterFile != "" {
+		f, err := os.OpenFile(*deadLetterFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		clientOpts = append(clientOpts, dtraderhq.WithDeadLetter(f))
+	}
+	if *traceFile != "" {
+		f, err := os.OpenFile(*traceFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		clientOpts = append(clientOpts, dtraderhq.WithFrameTap(dtraderhq.NewTraceWriter(f).Tap))
+	}
+	var metricsErr <-chan error
+	if *metricsAddr != "" {
+		metrics := dtraderhq.NewMetrics()
+		clientOpts = append(clientOpts, dtraderhq.WithMetrics(metrics))
+		metricsErr = serveMetrics(*metricsAddr, metrics)
+		log.Printf("指标导出地址 http://%s/metrics", *metricsAddr)
+	}
+
+	var (
+		provider      dtraderhq.TokenProvider
+		subscriptions []dtraderhq.SubscribeMessage
+		recorder      store.Sink
+		newClient     func() *dtraderhq.Client
+		watcher       *dtraderhq.SymbolWatcher
+		market        dtraderhq.Market
+		err           error
+	)
+	if market, err = dtraderhq.ParseMarket(*marketName); err != nil {
+		log.Fatalf("invalid -market: %v", err)
+	}
+	if *configFile != "" {
+		cfg, err := config.Load(*configFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		provider = cfg.TokenProvider()
+		if subscriptions, err = cfg.SubscriptionList(); err != nil {
+			log.Fatal(err)
+		}
+		if recorder = cfg.NewSink(); recorder == nil {
+			log.Fatalf("%s: no sinks configured", *configFile)
+		}
+		newClient = func() *dtraderhq.Client { return cfg.NewClient(clientOpts...) }
+		if *watch {
+			if cfg.SymbolsFile() == "" {
+				log.Fatalf("%s: -watch requires subscriptions.symbols_file", *configFile)
+			}
+			watcher = &dtraderhq.SymbolWatcher{Path: cfg.SymbolsFile(), Load: cfg.SubscriptionList}
+		}
+		log.Printf("已加载配置 %s", *configFile)
+	} else {
+		// 重连和 token 即将过期时重新读取，便于外部程序轮换 token
+		provider = dtraderhq.EnvToken(*tokenEnv)
+		if *tokenFile != "" {
+			provider = dtraderhq.FileToken(*tokenFile)
+		}
+		clientOpts = append(clientOpts, dtraderhq.WithTokenProvider(provider))
+
+		defaultTypes, err := dtraderhq.ParseDataTypes(*types)
+		if err != nil {
+			log.Fatalf("invalid -types: %v", err)
+		}
+		if subscriptions, err = dtraderhq.LoadSymbolList(*symbolsFile, defaultTypes); err != nil {
+			log.Fatal(err)
+		}
+
+		storeFormat := store.Format(*format)
+		if storeFormat != store.FormatJSONL && storeFormat != store.FormatBinary {
+			log.Fatalf("unsupported -format %q", *format)
+		}
+		recorder = store.NewRecorder(*outDir, storeFormat)
+		newClient = func() *dtraderhq.Client { return dtraderhq.NewClient(*serverURL, clientOpts...) }
+		if *watch {
+			watcher = &dtraderhq.SymbolWatcher{Path: *symbolsFile, DefaultTypes: defaultTypes}
+		}
+		log.Printf("数据保存到 %s（%s）", *outDir, storeFormat)
+	}
+	if len(subscriptions) == 0 {
+		log.Fatal("股票列表为空")
+	}
+	// 启动时先获取一次 token，尽早发现凭据配置错误；连接时再由 TokenProvider 重新获取
+	if _, err := provider.Token(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	deadline, err := runDeadline(*duration, *until)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	// 指标服务出错时与收到信号一样正常停止：写完缓冲的数据、关闭文件并输出汇总
+	ctx, cancel := stopOnError(ctx, metricsErr)
+	defer cancel(nil)
+
+	coll := newCollector(recorder)
+	if watcher != nil {
+		watcher.OnChange = logSubscriptionChange
+		coll.watcher = watcher
+		log.Printf("监视股票列表 %s", watcher.Path)
+	}
+
+	log.Printf("采集 %d 只股票", len(subscriptions))
+
+	if *calendarFile != "" {
+		err = runCalendar(ctx, *calendarFile, market, newClient, subscriptions, coll)
+	} else {
+		err = runOnce(ctx, newClient(), subscriptions, coll)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("采集异常结束: %v", err)
+	}
+
+	if err := recorder.Close(); err != nil {
+		log.Printf("关闭文件失败: %v", err)
+	}
+	coll.printSummary(os.Stdout)
+
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) && !errors.Is(cause, context.DeadlineExceeded) {
+		log.Printf("采集提前停止: %v", cause)
+	}
+}
+
+// serveMetrics 在后台启动指标服务，监听或服务出错时把错误发送到返回的通道
+func serveMetrics(addr string, metrics http.Handler) <-chan error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- fmt.Errorf("metrics server: %w", http.ListenAndServe(addr, mux))
+	}()
+	return errc
+}
+
+// stopOnError 返回的 ctx 在 errc 收到错误时以该错误取消，errc 为 nil 时只随父 ctx 结束
+func stopOnError(ctx context.Context, errc <-chan error) (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case err := <-errc:
+			cancel(err)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// runOnce 立即连接并采集，直到 ctx 结束，token 由客户端的 TokenProvider 提供
+func runOnce(ctx context.Context, client *dtraderhq.Client, subscriptions []dtraderhq.SubscribeMessage, coll *collector) error {
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("连接失败: %w", err)
+	}
+	defer client.Close()
+
+	if err := client.Authenticate(""); err != nil {
+		return fmt.Errorf("认证失败: %w", err)
+	}
+	authCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := client.WaitAuthenticated(authCtx); err != nil {
+		return fmt.Errorf("认证未完成: %w", err)
+	}
+
+	if err := client.SubscribeAll(subscriptions); err != nil {
+		return fmt.Errorf("订阅失败: %w", err)
+	}
+	log.Println("订阅请求已发送，开始采集，按 Ctrl+C 停止")
+
+	coll.run(ctx, client)
+	return ctx.Err()
+}
+
+// runCalendar 按交易日历在每个交易日自动连接、采集和断开，每个交易日从 TokenProvider 重新获取 token
+func runCalendar(ctx context.Context, calendarFile string, market dtraderhq.Market, newClient func() *dtraderhq.Client, subscriptions []dtraderhq.SubscribeMessage, coll *collector) error {
+	cal, err := dtraderhq.LoadCalendar(calendarFile)
+	if err != nil {
+		return err
+	}
+
+	runner := &dtraderhq.SessionRunner{
+		Calendar: cal,
+		Market:   market,
+		NewClient: func() *dtraderhq.Client {
+			client := newClient()
+			client.SetTradingDateSource(cal.TradingDates(market))
+			return client
+		},
+		Subscriptions: subscriptions,
+		Handle: func(ctx context.Context, client *dtraderhq.Client) {
+			log.Println("交易时段开始，已连接并订阅")
+			coll.run(ctx, client)
+		},
+		Finalize: func(day time.Time) error {
+			log.Printf("%s 交易时段结束", day.Format("2006-01-02"))
+			return coll.recorder.Flush()
+		},
+		OnError: func(day time.Time, err error) {
+			log.Printf("%s 交易日采集失败，等待下一个交易日: %v", day.Format("2006-01-02"), err)
+		},
+	}
+	return runner.Run(ctx)
+}
+
+// runDeadline 根据 -duration 和 -until 计算结束时间，两者都指定时取较早者
+func runDeadline(duration time.Duration, until string) (time.Time, error) {
+	var deadline time.Time
+	if duration > 0 {
+		deadline = time.Now().Add(duration)
+	}
+
+	if until != "" {
+		clock, err := time.ParseInLocation("15:04", until, dtraderhq.ShanghaiLocation)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid -until %q: %w", until, err)
+		}
+		now := time.Now().In(dtraderhq.ShanghaiLocation)
+		end := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, dtraderhq.ShanghaiLocation)
+		if !end.After(now) {
+			return time.Time{}, fmt.Errorf("-until %s has already passed", until)
+		}
+		if deadline.IsZero() || end.Before(deadline) {
+			deadline = end
+		}
+	}
+	return deadline, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServeMetricsReportsListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	errc := serveMetrics(ln.Addr().String(), http.NotFoundHandler())
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatal("nil error for address in use")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("listen error not reported")
+	}
+}
+
+func TestStopOnError(t *testing.T) {
+	errc := make(chan error, 1)
+	ctx, cancel := stopOnError(context.Background(), errc)
+	defer cancel(nil)
+
+	listenErr := errors.New("address already in use")
+	errc <- listenErr
+	select {
+	case <-ctx.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("ctx not cancelled")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, listenErr) {
+		t.Fatalf("cause = %v", cause)
+	}
+
+	// 未启用指标服务时只随父 ctx 结束
+	parent, stop := context.WithCancel(context.Background())
+	ctx, cancel = stopOnError(parent, nil)
+	defer cancel(nil)
+	if ctx.Err() != nil {
+		t.Fatal("ctx cancelled without error")
+	}
+	stop()
+	<-ctx.Done()
+	if !errors.Is(context.Cause(ctx), context.Canceled) {
+		t.Fatalf("cause = %v", context.Cause(ctx))
+	}
+}
+
+func TestRunDeadline(t *testing.T) {
+	if d, err := runDeadline(0, ""); err != nil || !d.IsZero() {
+		t.Fatalf("runDeadline(0, \"\") = %v, %v", d, err)
+	}
+	if d, err := runDeadline(time.Hour, ""); err != nil || time.Until(d) < 59*time.Minute {
+		t.Fatalf("runDeadline(1h) = %v, %v", d, err)
+	}
+	if _, err := runDeadline(0, "25:00"); err == nil {
+		t.Fatal("invalid -until accepted")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+	"github.com/DTrader-store/level2-client-go/store"
+)
+
+const sampleDir = "../../examples/stock_collector/stock_data"
+
+// loadTable 将样例文件转换为表
+func loadTable(t *testing.T, name string) *table {
+	t.Helper()
+	info, ok := store.ParseFileName(filepath.Join(sampleDir, name))
+	if !ok {
+		t.Fatalf("bad sample name %s", name)
+	}
+	tbl, err := newTableFor(info.DataType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(info.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	clock := dtraderhq.NewExchangeClock()
+	r := store.NewJSONLReader(f)
+	for r.Next() {
+		if n, err := tbl.appendFrame(clock, r.Frame()); n > 0 {
+			t.Fatalf("%s: skipped %d records: %v", name, n, err)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return tbl
+}
+
+func TestAppendFrameSkipsBadOrders(t *testing.T) {
+	tbl, err := newTableFor(dtraderhq.DataTypeZBWT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := []dtraderhq.ZBWT{
+		{Index: 1, DateTime: 111257470, Price: 490, Volume: 100, Type: dtraderhq.OrderTypeBuyAdd},
+		{Index: 2, DateTime: 999999999, Price: 490, Volume: 100, Type: dtraderhq.OrderTypeBuyAdd},
+		{Index: 3, DateTime: 111257480, Price: 491, Volume: 200, Type: dtraderhq.OrderTypeSellCancel},
+	}
+	frame := &dtraderhq.DecodedFrame{
+		MarketData: &dtraderhq.MarketData{StockCode: "SZ002240", DataType: dtraderhq.DataTypeZBWT, Timestamp: 1750993978, Data: orders},
+		Orders:     orders,
+	}
+
+	skipped, err := tbl.appendFrame(dtraderhq.NewExchangeClock(), frame)
+	if skipped != 1 || err == nil {
+		t.Fatalf("skipped = %d, err = %v", skipped, err)
+	}
+	for _, col := range tbl.columns {
+		if col.len() != 2 {
+			t.Fatalf("column %s has %d rows, want 2", col.name, col.len())
+		}
+	}
+	if idx := tbl.index["index"].ints; idx[0] != 1 || idx[1] != 3 {
+		t.Fatalf("index column = %v", idx)
+	}
+}
+
+// 沪市委托时间为 HHMMSScc，全部记录都应转换成功
+func TestConvertSampleOrders(t *testing.T) {
+	out := t.TempDir()
+	for _, name := range []string{
+		"SH603065_order_20250627.json",
+		"SH603166_order_20250627.json",
+		"SH603256_order_20250627.json",
+		"SZ002240_order_20250627.json",
+	} {
+		info, _ := store.ParseFileName(filepath.Join(sampleDir, name))
+		rows, err := convertFile(info, out, []string{"csv", "parquet"}, true)
+		if err != nil || rows == 0 {
+			t.Fatalf("%s: rows = %d, err = %v", name, rows, err)
+		}
+
+		f, err := os.Open(filepath.Join(out, name[:len(name)-len(".json")]+".csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != rows+1 {
+			t.Fatalf("%s: csv has %d records, want %d", name, len(records), rows+1)
+		}
+	}
+}
+
+// 采集中断留下的半行被跳过，其余记录照常转换
+func TestConvertTruncatedFile(t *testing.T) {
+	const name = "SZ002240_transaction_20250627.json"
+	info, _ := store.ParseFileName(filepath.Join(sampleDir, name))
+	want, err := convertFile(info, t.TempDir(), []string{"csv"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(info.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, append(b, `{"data":[{"OrderPackId":1,`...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ = store.ParseFileName(path)
+	rows, err := convertFile(info, dir, []string{"csv"}, false)
+	if err != nil || rows != want {
+		t.Fatalf("rows = %d, err = %v, want %d rows", rows, err, want)
+	}
+}
@@ -0,0 +1,239 @@
+// dtraderhq-convert 将 collector 记录的行情文件转换为 CSV / Parquet
+//
+// 每个输入文件（{股票代码}_{数据类型}_{日期}.json 或 .dtb）对应一个输出文件，
+// 记录按 OrderPackId / Index 去重，价格换算为元，方向和时间解码为可读字段，
+// 同一数据类型的输出始终使用相同的列。
+//
+//	dtraderhq-convert -in ./stock_data -out ./export -format csv,parquet
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+	"github.com/DTrader-store/level2-client-go/store"
+)
+
+func main() {
+	inDir := flag.String("in", "./stock_data", "记录文件所在目录")
+	outDir := flag.String("out", "./export", "输出目录")
+	formats := flag.String("format", "csv,parquet", "输出格式，逗号分隔：csv、parquet")
+	codes := flag.String("codes", "", "只转换这些股票，逗号分隔，为空表示全部")
+	types := flag.String("types", "", "只转换这些数据类型，逗号分隔，为空表示全部")
+	dedup := flag.Bool("dedup", true, "按记录序号去重")
+	flag.Parse()
+
+	outFormats, err := parseFormats(*formats)
+	if err != nil {
+		log.Fatal(err)
+	}
+	typeFilter, err := parseInts(*types)
+	if err != nil {
+		log.Fatalf("invalid -types: %v", err)
+	}
+	codeFilter := splitList(*codes)
+
+	files, err := store.ListFiles(*inDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("create output dir: %v", err)
+	}
+
+	converted := 0
+	for _, info := range files {
+		if len(codeFilter) > 0 && !contains(codeFilter, info.StockCode) {
+			continue
+		}
+		if len(typeFilter) > 0 && !containsInt(typeFilter, info.DataType) {
+			continue
+		}
+
+		rows, err := convertFile(info, *outDir, outFormats, *dedup)
+		if err != nil {
+			log.Printf("转换失败 %s: %v", info.Path, err)
+			continue
+		}
+		log.Printf("已转换 %s: %d 行", filepath.Base(info.Path), rows)
+		converted++
+	}
+
+	log.Printf("完成，共转换 %d 个文件", converted)
+}
+
+// convertFile 转换单个记录文件，返回输出的行数
+func convertFile(info store.FileInfo, outDir string, formats []string, dedup bool) (int, error) {
+	t, err := newTableFor(info.DataType)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(info.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var it interface {
+		store.FrameIterator
+		Corrupted() int
+	}
+	if info.Format == store.FormatBinary {
+		if it, err = store.NewBinaryReader(f); err != nil {
+			return 0, err
+		}
+	} else {
+		it = store.NewJSONLReader(f)
+	}
+
+	var deduper *dtraderhq.Deduper
+	if dedup {
+		deduper = dtraderhq.NewDeduper(0)
+	}
+	clock := dtraderhq.NewExchangeClock()
+
+	var skipped int
+	var skipErr error
+	for it.Next() {
+		frame := it.Frame()
+		if deduper != nil {
+			if frame = deduper.Filter(frame); frame == nil {
+				continue
+			}
+		}
+		n, err := t.appendFrame(clock, frame)
+		if n > 0 && skipped == 0 {
+			skipErr = err
+		}
+		skipped += n
+	}
+	if err := it.Err(); err != nil {
+		return 0, err
+	}
+	if skipped > 0 {
+		log.Printf("%s: 跳过 %d 条无效记录，首个错误: %v", filepath.Base(info.Path), skipped, skipErr)
+	}
+	if n := it.Corrupted(); n > 0 {
+		log.Printf("%s: 跳过 %d 处损坏的数据", filepath.Base(info.Path), n)
+	}
+
+	if t.rows() == 0 {
+		return 0, nil
+	}
+
+	base := strings.TrimSuffix(filepath.Base(info.Path), filepath.Ext(info.Path))
+	for _, format := range formats {
+		path := filepath.Join(outDir, base+"."+format)
+		if err := writeOutput(path, format, t); err != nil {
+			return 0, err
+		}
+	}
+	return t.rows(), nil
+}
+
+func writeOutput(path, format string, t *table) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "parquet":
+		err = writeParquet(out, t)
+	default:
+		err = writeCSV(out, t)
+	}
+	if err != nil {
+		out.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return out.Close()
+}
+
+// writeCSV 输出带表头的 CSV
+func writeCSV(w io.Writer, t *table) error {
+	cw := csv.NewWriter(w)
+
+	header := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		header[i] = col.name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(t.columns))
+	for row := 0; row < t.rows(); row++ {
+		for i, col := range t.columns {
+			record[i] = col.format(row)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func parseFormats(s string) ([]string, error) {
+	formats := splitList(s)
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no output format")
+	}
+	for _, f := range formats {
+		if f != "csv" && f != "parquet" {
+			return nil, fmt.Errorf("unsupported format %q", f)
+		}
+	}
+	return formats, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+func parseInts(s string) ([]int, error) {
+	var list []int
+	for _, part := range splitList(s) {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// 最小化的 Parquet 写入实现：单个 row group，每列一个 PLAIN 编码、不压缩的数据页，
+// 所有列均为 REQUIRED。足以被 pandas/pyarrow/Spark 读取，避免为转换工具引入额外依赖。
+//
+// 元数据使用 Thrift compact protocol 编码，字段编号见 parquet-format 的 parquet.thrift。
+
+const parquetMagic = "PAR1"
+
+// Parquet 物理类型
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+)
+
+// Parquet 编码与其他枚举值
+const (
+	encodingPlain       = 0
+	encodingRLE         = 3
+	codecUncompressed   = 0
+	pageTypeData        = 0
+	repetitionRequired  = 0
+	convertedUTF8       = 0
+	convertedTimestampM = 9
+)
+
+func physicalType(kind columnKind) int32 {
+	switch kind {
+	case kindString:
+		return parquetByteArray
+	case kindInt32:
+		return parquetInt32
+	case kindDouble:
+		return parquetDouble
+	default:
+		return parquetInt64
+	}
+}
+
+// plainValues 按 PLAIN 编码输出一列的全部值
+func plainValues(c *column) []byte {
+	var buf bytes.Buffer
+	var tmp [8]byte
+	switch c.kind {
+	case kindString:
+		for _, s := range c.strings {
+			binary.LittleEndian.PutUint32(tmp[:4], uint32(len(s)))
+			buf.Write(tmp[:4])
+			buf.WriteString(s)
+		}
+	case kindInt32:
+		for _, v := range c.ints {
+			binary.LittleEndian.PutUint32(tmp[:4], uint32(int32(v)))
+			buf.Write(tmp[:4])
+		}
+	case kindDouble:
+		for _, v := range c.floats {
+			binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(v))
+			buf.Write(tmp[:])
+		}
+	default:
+		for _, v := range c.ints {
+			binary.LittleEndian.PutUint64(tmp[:], uint64(v))
+			buf.Write(tmp[:])
+		}
+	}
+	return buf.Bytes()
+}
+
+// writeParquet 将表写为 Parquet 文件
+func writeParquet(w io.Writer, t *table) error {
+	var out bytes.Buffer
+	out.WriteString(parquetMagic)
+
+	rows := int64(t.rows())
+	chunks := make([]func(*thriftWriter), 0, len(t.columns))
+	var totalSize int64
+
+	for _, col := range t.columns {
+		values := plainValues(col)
+
+		page := newThriftWriter()
+		page.structBegin()
+		page.i32Field(1, pageTypeData)
+		page.i32Field(2, int32(len(values)))
+		page.i32Field(3, int32(len(values)))
+		page.structField(5, func(w *thriftWriter) {
+			w.i32Field(1, int32(rows))
+			w.i32Field(2, encodingPlain)
+			w.i32Field(3, encodingRLE)
+			w.i32Field(4, encodingRLE)
+		})
+		page.structEnd()
+
+		offset := int64(out.Len())
+		out.Write(page.bytes())
+		out.Write(values)
+		size := int64(out.Len()) - offset
+		totalSize += size
+
+		col := col
+		chunks = append(chunks, func(w *thriftWriter) {
+			w.i64Field(2, offset)
+			w.structField(3, func(w *thriftWriter) {
+				w.i32Field(1, physicalType(col.kind))
+				w.listField(2, thriftI32, 2, func(w *thriftWriter) {
+					w.varint(encodingPlain)
+					w.varint(encodingRLE)
+				})
+				w.listField(3, thriftBinary, 1, func(w *thriftWriter) {
+					w.binary(col.name)
+				})
+				w.i32Field(4, codecUncompressed)
+				w.i64Field(5, rows)
+				w.i64Field(6, size)
+				w.i64Field(7, size)
+				w.i64Field(9, offset)
+			})
+		})
+	}
+
+	meta := newThriftWriter()
+	meta.structBegin()
+	meta.i32Field(1, 1)
+	meta.listField(2, thriftStruct, len(t.columns)+1, func(w *thriftWriter) {
+		// 根节点
+		w.structBegin()
+		w.binaryField(4, "schema")
+		w.i32Field(5, int32(len(t.columns)))
+		w.structEnd()
+
+		for _, col := range t.columns {
+			w.structBegin()
+			w.i32Field(1, physicalType(col.kind))
+			w.i32Field(3, repetitionRequired)
+			w.binaryField(4, col.name)
+			switch col.kind {
+			case kindString:
+				w.i32Field(6, convertedUTF8)
+				w.structField(10, func(w *thriftWriter) {
+					w.structField(1, func(*thriftWriter) {}) // STRING
+				})
+			case kindTimestamp:
+				w.i32Field(6, convertedTimestampM)
+				w.structField(10, func(w *thriftWriter) {
+					w.structField(8, func(w *thriftWriter) { // TIMESTAMP
+						w.boolField(1, true)
+						w.structField(2, func(w *thriftWriter) {
+							w.structField(1, func(*thriftWriter) {}) // MILLIS
+						})
+					})
+				})
+			}
+			w.structEnd()
+		}
+	})
+	meta.i64Field(3, rows)
+	meta.listField(4, thriftStruct, 1, func(w *thriftWriter) {
+		w.structBegin()
+		w.listField(1, thriftStruct, len(chunks), func(w *thriftWriter) {
+			for _, chunk := range chunks {
+				w.structBegin()
+				chunk(w)
+				w.structEnd()
+			}
+		})
+		w.i64Field(2, totalSize)
+		w.i64Field(3, rows)
+		w.structEnd()
+	})
+	meta.binaryField(6, "dtraderhq-convert")
+	meta.structEnd()
+
+	footer := meta.bytes()
+	out.Write(footer)
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(footer)))
+	out.Write(size[:])
+	out.WriteString(parquetMagic)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// Thrift compact protocol 类型编号
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter Thrift compact protocol 编码器，只实现 Parquet 元数据用到的部分
+type thriftWriter struct {
+	buf     bytes.Buffer
+	lastIDs []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{}
+}
+
+func (w *thriftWriter) bytes() []byte {
+	return w.buf.Bytes()
+}
+
+func (w *thriftWriter) varint(v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], uint64((v<<1)^(v>>63)))
+	w.buf.Write(tmp[:n])
+}
+
+func (w *thriftWriter) uvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	w.buf.Write(tmp[:n])
+}
+
+func (w *thriftWriter) binary(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *thriftWriter) structBegin() {
+	w.lastIDs = append(w.lastIDs, 0)
+}
+
+func (w *thriftWriter) structEnd() {
+	w.buf.WriteByte(0)
+	w.lastIDs = w.lastIDs[:len(w.lastIDs)-1]
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &w.lastIDs[len(w.lastIDs)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) i32Field(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64Field(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) boolField(id int16, v bool) {
+	if v {
+		w.fieldHeader(id, thriftTrue)
+	} else {
+		w.fieldHeader(id, thriftFalse)
+	}
+}
+
+func (w *thriftWriter) binaryField(id int16, s string) {
+	w.fieldHeader(id, thriftBinary)
+	w.binary(s)
+}
+
+func (w *thriftWriter) structField(id int16, body func(*thriftWriter)) {
+	w.fieldHeader(id, thriftStruct)
+	w.structBegin()
+	body(w)
+	w.structEnd()
+}
+
+func (w *thriftWriter) listField(id int16, elemType byte, size int, body func(*thriftWriter)) {
+	w.fieldHeader(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		w.buf.WriteByte(0xF0 | elemType)
+		w.uvarint(uint64(size))
+	}
+	body(w)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+)
+
+// columnKind 列类型，CSV 与 Parquet 共用同一套 schema
+type columnKind int
+
+const (
+	kindString    columnKind = iota
+	kindInt32                // 小整数（标志位）
+	kindInt64                // 整数（序号、数量）
+	kindDouble               // 价格
+	kindTimestamp            // 毫秒时间戳，CSV 中输出为交易所时区的本地时间
+)
+
+// column 一列数据，根据 kind 使用其中一个切片
+type column struct {
+	name    string
+	kind    columnKind
+	strings []string
+	ints    []int64
+	floats  []float64
+}
+
+func (c *column) len() int {
+	switch c.kind {
+	case kindString:
+		return len(c.strings)
+	case kindDouble:
+		return len(c.floats)
+	default:
+		return len(c.ints)
+	}
+}
+
+// format 返回第 i 行的 CSV 文本
+func (c *column) format(i int) string {
+	switch c.kind {
+	case kindString:
+		return c.strings[i]
+	case kindDouble:
+		return fmt.Sprintf("%.2f", c.floats[i])
+	case kindTimestamp:
+		return time.UnixMilli(c.ints[i]).In(dtraderhq.ShanghaiLocation).Format("2006-01-02 15:04:05.000")
+	default:
+		return fmt.Sprint(c.ints[i])
+	}
+}
+
+// table 按列存储的一组记录
+type table struct {
+	columns []*column
+	index   map[string]*column
+}
+
+func newTable(schema ...column) *table {
+	t := &table{index: make(map[string]*column)}
+	for i := range schema {
+		col := schema[i]
+		t.columns = append(t.columns, &col)
+		t.index[col.name] = &col
+	}
+	return t
+}
+
+func (t *table) rows() int {
+	if len(t.columns) == 0 {
+		return 0
+	}
+	return t.columns[0].len()
+}
+
+func (t *table) str(name, v string) {
+	c := t.index[name]
+	c.strings = append(c.strings, v)
+}
+
+func (t *table) integer(name string, v int64) {
+	c := t.index[name]
+	c.ints = append(c.ints, v)
+}
+
+func (t *table) price(name string, v int64) {
+	c := t.index[name]
+	c.floats = append(c.floats, float64(v)/dtraderhq.PriceScale)
+}
+
+func (t *table) timestamp(name string, v time.Time) {
+	c := t.index[name]
+	c.ints = append(c.ints, v.UnixMilli())
+}
+
+// newTableFor 返回数据类型对应的固定 schema
+func newTableFor(dataType int) (*table, error) {
+	switch dataType {
+	case dtraderhq.DataTypeTransaction:
+		return newTable(
+			column{name: "stock_code", kind: kindString},
+			column{name: "frame_time", kind: kindTimestamp},
+			column{name: "order_pack_id", kind: kindInt64},
+			column{name: "time", kind: kindTimestamp},
+			column{name: "price", kind: kindDouble},
+			column{name: "volume", kind: kindInt64},
+			column{name: "side", kind: kindString},
+		), nil
+	case dtraderhq.DataTypeBigOrder:
+		return newTable(
+			column{name: "stock_code", kind: kindString},
+			column{name: "frame_time", kind: kindTimestamp},
+			column{name: "order_pack_id", kind: kindInt64},
+			column{name: "buy_order_pack_id", kind: kindInt64},
+			column{name: "sell_order_pack_id", kind: kindInt64},
+			column{name: "buy_flag", kind: kindInt32},
+			column{name: "sell_flag", kind: kindInt32},
+			column{name: "buy_price", kind: kindDouble},
+			column{name: "sell_price", kind: kindDouble},
+			column{name: "buy_vol", kind: kindInt64},
+			column{name: "sell_vol", kind: kindInt64},
+		), nil
+	case dtraderhq.DataTypeZBWT:
+		return newTable(
+			column{name: "stock_code", kind: kindString},
+			column{name: "frame_time", kind: kindTimestamp},
+			column{name: "index", kind: kindInt64},
+			column{name: "time", kind: kindTimestamp},
+			column{name: "price", kind: kindDouble},
+			column{name: "volume", kind: kindInt64},
+			column{name: "type", kind: kindString},
+			column{name: "side", kind: kindString},
+			column{name: "action", kind: kindString},
+		), nil
+	}
+	return nil, fmt.Errorf("unsupported data type %d", dataType)
+}
+
+// appendFrame 将一帧数据展开为多行，时间无法解析的记录被跳过，返回跳过的记录数和其中第一个错误
+func (t *table) appendFrame(clock *dtraderhq.ExchangeClock, frame *dtraderhq.DecodedFrame) (skipped int, err error) {
+	frameTime := clock.FrameTime(frame.MarketData)
+
+	for _, tr := range frame.Transactions {
+		t.str("stock_code", frame.StockCode)
+		t.timestamp("frame_time", frameTime)
+		t.integer("order_pack_id", tr.OrderPackId)
+		t.timestamp("time", clock.TransactionTime(tr))
+		t.price("price", tr.Price)
+		t.integer("volume", tr.AbsVolume())
+		t.str("side", sideOf(tr.IsBuy()))
+	}
+
+	for _, b := range frame.BigOrders {
+		t.str("stock_code", frame.StockCode)
+		t.timestamp("frame_time", frameTime)
+		t.integer("order_pack_id", b.OrderPackId)
+		t.integer("buy_order_pack_id", b.BuyOrderPackId)
+		t.integer("sell_order_pack_id", b.SellOrderPackId)
+		t.integer("buy_flag", int64(b.BuyFlag))
+		t.integer("sell_flag", int64(b.SellFlag))
+		t.price("buy_price", b.BuyPrice)
+		t.price("sell_price", b.SellPrice)
+		t.integer("buy_vol", b.BuyVol)
+		t.integer("sell_vol", b.SellVol)
+	}
+
+	for _, z := range frame.Orders {
+		ts, zerr := clock.ZBWTTime(frame.StockCode, z, frameTime)
+		if zerr != nil {
+			if skipped == 0 {
+				err = fmt.Errorf("order %d: %w", z.Index, zerr)
+			}
+			skipped++
+			continue
+		}
+		action := "add"
+		if z.Type.IsCancel() {
+			action = "cancel"
+		}
+		t.str("stock_code", frame.StockCode)
+		t.timestamp("frame_time", frameTime)
+		t.integer("index", z.Index)
+		t.timestamp("time", ts)
+		t.price("price", z.Price)
+		t.integer("volume", z.Volume)
+		t.str("type", z.Type.String())
+		t.str("side", sideOf(z.Type.IsBuy()))
+		t.str("action", action)
+	}
+
+	return skipped, err
+}
+
+func sideOf(buy bool) string {
+	if buy {
+		return "B"
+	}
+	return "S"
+}
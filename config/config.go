@@ -0,0 +1,399 @@
+// Package config 从 YAML / TOML 文件加载客户端与采集器配置
+//
+//	server:
+//	  endpoints: ["ws://127.0.0.1:8080/ws"]
+//	credentials:
+//	  token_env: DTRADERHQ_TOKEN   # 或 token_file、token_command
+//	subscriptions:
+//	  default_types: [4, 8, 14]
+//	  symbols_file: symbols.txt
+//	  symbols:
+//	    SZ002240: [4, 14]
+//	buffer:
+//	  data_channel: 1000
+//	  backpressure: drop_oldest
+//	sinks:
+//	  - format: jsonl
+//	    dir: ./stock_data
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+	"github.com/DTrader-store/level2-client-go/store"
+)
+
+// OpenDataTypes 当前开放订阅的数据类型
+var OpenDataTypes = []int{
+	dtraderhq.DataTypeTransaction,
+	dtraderhq.DataTypeBigOrder,
+	dtraderhq.DataTypeZBWT,
+}
+
+// Config 配置文件结构
+type Config struct {
+	Server        Server        `yaml:"server" toml:"server"`
+	Credentials   Credentials   `yaml:"credentials" toml:"credentials"`
+	Subscriptions Subscriptions `yaml:"subscriptions" toml:"subscriptions"`
+	Buffer        Buffer        `yaml:"buffer" toml:"buffer"`
+	Sinks         []Sink        `yaml:"sinks" toml:"sinks"`
+
+	// dir 配置文件所在目录，用于解析相对路径
+	dir string
+}
+
+// Server 服务器配置
+type Server struct {
+	Endpoints []string `yaml:"endpoints" toml:"endpoints"` // 按优先级排列的服务器地址
+	Keepalive string   `yaml:"keepalive" toml:"keepalive"` // 心跳方式：json（默认）、control 或 both
+
+	// EndpointKeepalive 按服务器地址单独指定心跳方式
+	EndpointKeepalive map[string]string `yaml:"endpoint_keepalive" toml:"endpoint_keepalive"`
+}
+
+// Credentials 认证信息来源，按 TokenCommand、TokenFile、TokenEnv、Token 的顺序取第一个非空值
+type Credentials struct {
+	TokenCommand []string `yaml:"token_command" toml:"token_command"` // 命令及参数，标准输出为 token
+	TokenFile    string   `yaml:"token_file" toml:"token_file"`
+	TokenEnv     string   `yaml:"token_env" toml:"token_env"`
+	Token        string   `yaml:"token" toml:"token"` // 不建议直接写在配置文件中
+}
+
+// Subscriptions 订阅配置
+type Subscriptions struct {
+	DefaultTypes []int            `yaml:"default_types" toml:"default_types"`
+	SymbolsFile  string           `yaml:"symbols_file" toml:"symbols_file"` // 格式见 dtraderhq.ParseSymbolList
+	Symbols      map[string][]int `yaml:"symbols" toml:"symbols"`           // 数据类型为空时使用 DefaultTypes
+	BatchSize    int              `yaml:"batch_size" toml:"batch_size"`     // SubscribeAll 每批订阅数量，默认且最大为 100
+}
+
+// Buffer 通道容量与背压配置
+type Buffer struct {
+	DataChannel  int    `yaml:"data_channel" toml:"data_channel"`
+	ErrorChannel int    `yaml:"error_channel" toml:"error_channel"`
+	Backpressure string `yaml:"backpressure" toml:"backpressure"` // block、drop_newest、drop_oldest
+}
+
+// Sink 落盘配置
+type Sink struct {
+	Format string `yaml:"format" toml:"format"` // jsonl 或 binary
+	Dir    string `yaml:"dir" toml:"dir"`
+}
+
+// Load 加载配置文件，根据扩展名选择 YAML（.yaml/.yml）或 TOML（.toml），并完成校验
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	cfg := &Config{dir: filepath.Dir(path)}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(string(b)))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(b), cfg)
+		if err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("parse config %s: unknown field %s", path, undecoded[0])
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", ext)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate 校验配置
+func (c *Config) Validate() error {
+	var errs []error
+
+	if len(c.Server.Endpoints) == 0 {
+		errs = append(errs, errors.New("server.endpoints is empty"))
+	}
+	for _, endpoint := range c.Server.Endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("server.endpoints: invalid websocket URL %q", endpoint))
+		}
+	}
+
+	if _, err := dtraderhq.ParseKeepaliveMode(c.Server.Keepalive); err != nil {
+		errs = append(errs, fmt.Errorf("server.keepalive: %w", err))
+	}
+	for endpoint, mode := range c.Server.EndpointKeepalive {
+		if _, err := dtraderhq.ParseKeepaliveMode(mode); err != nil {
+			errs = append(errs, fmt.Errorf("server.endpoint_keepalive.%s: %w", endpoint, err))
+		}
+	}
+
+	if len(c.Credentials.TokenCommand) > 0 && c.Credentials.TokenCommand[0] == "" {
+		errs = append(errs, errors.New("credentials.token_command: empty command"))
+	}
+
+	if err := validateTypes("subscriptions.default_types", c.Subscriptions.DefaultTypes); err != nil {
+		errs = append(errs, err)
+	}
+	for code, types := range c.Subscriptions.Symbols {
+		if code == "" {
+			errs = append(errs, errors.New("subscriptions.symbols: empty stock code"))
+		}
+		if len(types) == 0 && len(c.Subscriptions.DefaultTypes) == 0 {
+			errs = append(errs, fmt.Errorf("subscriptions.symbols.%s: no data types and no default_types", code))
+		}
+		if err := validateTypes("subscriptions.symbols."+code, types); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(c.Subscriptions.Symbols) == 0 && c.Subscriptions.SymbolsFile == "" {
+		errs = append(errs, errors.New("subscriptions: neither symbols nor symbols_file is set"))
+	}
+	if c.Subscriptions.BatchSize < 0 || c.Subscriptions.BatchSize > dtraderhq.MaxBatchSize {
+		errs = append(errs, fmt.Errorf("subscriptions.batch_size must be between 1 and %d, or 0 for the default", dtraderhq.MaxBatchSize))
+	}
+
+	if c.Buffer.DataChannel < 0 || c.Buffer.ErrorChannel < 0 {
+		errs = append(errs, errors.New("buffer: channel size must not be negative"))
+	}
+	if _, err := parseBackpressure(c.Buffer.Backpressure); err != nil {
+		errs = append(errs, err)
+	}
+
+	for i, sink := range c.Sinks {
+		if f := store.Format(sink.Format); f != store.FormatJSONL && f != store.FormatBinary {
+			errs = append(errs, fmt.Errorf("sinks[%d]: unsupported format %q", i, sink.Format))
+		}
+		if sink.Dir == "" {
+			errs = append(errs, fmt.Errorf("sinks[%d]: dir is empty", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateTypes(field string, types []int) error {
+	for _, t := range types {
+		open := false
+		for _, o := range OpenDataTypes {
+			if t == o {
+				open = true
+				break
+			}
+		}
+		if !open {
+			return fmt.Errorf("%s: data type %d is not open (allowed: %v)", field, t, OpenDataTypes)
+		}
+	}
+	return nil
+}
+
+func parseBackpressure(s string) (dtraderhq.BackpressurePolicy, error) {
+	switch s {
+	case "", "block":
+		return dtraderhq.BackpressureBlock, nil
+	case "drop_newest":
+		return dtraderhq.BackpressureDropNewest, nil
+	case "drop_oldest":
+		return dtraderhq.BackpressureDropOldest, nil
+	}
+	return 0, fmt.Errorf("buffer.backpressure: unknown policy %q", s)
+}
+
+// path 将相对路径解析为相对于配置文件所在目录
+func (c *Config) path(p string) string {
+	if p == "" || filepath.IsAbs(p) || c.dir == "" {
+		return p
+	}
+	return filepath.Join(c.dir, p)
+}
+
+// Token 按配置读取认证 token
+func (c *Config) Token() (string, error) {
+	return c.TokenProvider().Token(context.Background())
+}
+
+// TokenProvider 返回按配置获取 token 的 TokenProvider，每次调用都重新执行命令或读取文件和环境变量
+func (c *Config) TokenProvider() dtraderhq.TokenProvider {
+	return dtraderhq.TokenProviderFunc(c.token)
+}
+
+func (c *Config) token(ctx context.Context) (string, error) {
+	cred := c.Credentials
+	if len(cred.TokenCommand) > 0 {
+		return dtraderhq.CommandToken{Name: cred.TokenCommand[0], Args: cred.TokenCommand[1:]}.Token(ctx)
+	}
+	if cred.TokenFile != "" {
+		b, err := os.ReadFile(c.path(cred.TokenFile))
+		if err != nil {
+			return "", fmt.Errorf("read token file: %w", err)
+		}
+		if token := strings.TrimSpace(string(b)); token != "" {
+			return token, nil
+		}
+	}
+	if cred.TokenEnv != "" {
+		if token := strings.TrimSpace(os.Getenv(cred.TokenEnv)); token != "" {
+			return token, nil
+		}
+	}
+	if cred.Token != "" {
+		return cred.Token, nil
+	}
+	return "", errors.New("credentials: no token available")
+}
+
+// SubscriptionList 合并 symbols_file 与 symbols，返回完整订阅列表（symbols 中的配置优先）
+func (c *Config) SubscriptionList() ([]dtraderhq.SubscribeMessage, error) {
+	var subs []dtraderhq.SubscribeMessage
+	if c.Subscriptions.SymbolsFile != "" {
+		list, err := dtraderhq.LoadSymbolList(c.path(c.Subscriptions.SymbolsFile), c.Subscriptions.DefaultTypes)
+		if err != nil {
+			return nil, err
+		}
+		for _, sub := range list {
+			if err := validateTypes("subscriptions.symbols_file: "+sub.StockCode, sub.DataTypes); err != nil {
+				return nil, err
+			}
+		}
+		subs = list
+	}
+
+	index := make(map[string]int, len(subs))
+	for i, sub := range subs {
+		index[sub.StockCode] = i
+	}
+
+	codes := make([]string, 0, len(c.Subscriptions.Symbols))
+	for code := range c.Subscriptions.Symbols {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		types := c.Subscriptions.Symbols[code]
+		if len(types) == 0 {
+			types = c.Subscriptions.DefaultTypes
+		}
+		sub := dtraderhq.SubscribeMessage{StockCode: code, DataTypes: append([]int(nil), types...)}
+		if i, ok := index[code]; ok {
+			subs[i] = sub
+			continue
+		}
+		index[code] = len(subs)
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// SymbolsFile 返回 symbols_file 的路径（已按配置文件目录解析），未配置时为空
+func (c *Config) SymbolsFile() string {
+	return c.path(c.Subscriptions.SymbolsFile)
+}
+
+// ClientOptions 返回与配置对应的客户端选项
+func (c *Config) ClientOptions() []dtraderhq.Option {
+	var opts []dtraderhq.Option
+	if c.Buffer.DataChannel > 0 {
+		opts = append(opts, dtraderhq.WithDataBuffer(c.Buffer.DataChannel))
+	}
+	if c.Buffer.ErrorChannel > 0 {
+		opts = append(opts, dtraderhq.WithErrorBuffer(c.Buffer.ErrorChannel))
+	}
+	if policy, err := parseBackpressure(c.Buffer.Backpressure); err == nil {
+		opts = append(opts, dtraderhq.WithBackpressure(policy))
+	}
+	if c.Subscriptions.BatchSize > 0 {
+		opts = append(opts, dtraderhq.WithBatchSize(c.Subscriptions.BatchSize))
+	}
+	opts = append(opts, dtraderhq.WithTokenProvider(c.TokenProvider()))
+	return opts
+}
+
+// keepalive 返回服务器使用的心跳方式
+func (c *Config) keepalive(endpoint string) dtraderhq.KeepaliveMode {
+	name, ok := c.Server.EndpointKeepalive[endpoint]
+	if !ok {
+		name = c.Server.Keepalive
+	}
+	mode, _ := dtraderhq.ParseKeepaliveMode(name)
+	return mode
+}
+
+// NewClient 使用首个服务器地址和缓冲配置创建客户端
+func (c *Config) NewClient(opts ...dtraderhq.Option) *dtraderhq.Client {
+	endpoint := c.Server.Endpoints[0]
+	opts = append([]dtraderhq.Option{dtraderhq.WithKeepalive(c.keepalive(endpoint))}, opts...)
+	return dtraderhq.NewClient(endpoint, append(c.ClientOptions(), opts...)...)
+}
+
+// NewFailoverClient 使用全部服务器地址（按配置顺序）创建多服务器客户端，心跳方式按服务器设置
+func (c *Config) NewFailoverClient(opts ...dtraderhq.Option) *dtraderhq.FailoverClient {
+	fc := dtraderhq.NewFailoverClient(c.Server.Endpoints, append(c.ClientOptions(), opts...)...)
+	fc.Keepalive = make(map[string]dtraderhq.KeepaliveMode, len(c.Server.Endpoints))
+	for _, endpoint := range c.Server.Endpoints {
+		fc.Keepalive[endpoint] = c.keepalive(endpoint)
+	}
+	return fc
+}
+
+// NewSink 按 sinks 配置创建落盘目标，未配置时返回 nil
+func (c *Config) NewSink() store.Sink {
+	switch len(c.Sinks) {
+	case 0:
+		return nil
+	case 1:
+		return store.NewRecorder(c.path(c.Sinks[0].Dir), store.Format(c.Sinks[0].Format))
+	}
+
+	sinks := make(store.MultiSink, 0, len(c.Sinks))
+	for _, s := range c.Sinks {
+		sinks = append(sinks, store.NewRecorder(c.path(s.Dir), store.Format(s.Format)))
+	}
+	return sinks
+}
+
+// Setup 由配置构造出的运行所需对象
+type Setup struct {
+	Client        *dtraderhq.Client
+	Sink          store.Sink // 未配置 sinks 时为 nil
+	Token         string
+	Subscriptions []dtraderhq.SubscribeMessage
+}
+
+// Build 构造客户端、落盘目标，并读取 token 和订阅列表
+func (c *Config) Build(opts ...dtraderhq.Option) (*Setup, error) {
+	token, err := c.Token()
+	if err != nil {
+		return nil, err
+	}
+	subs, err := c.SubscriptionList()
+	if err != nil {
+		return nil, err
+	}
+	return &Setup{
+		Client:        c.NewClient(opts...),
+		Sink:          c.NewSink(),
+		Token:         token,
+		Subscriptions: subs,
+	}, nil
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+)
+
+func validConfig() *Config {
+	return &Config{
+		Server:        Server{Endpoints: []string{"ws://127.0.0.1:8080/ws"}},
+		Subscriptions: Subscriptions{DefaultTypes: []int{4}, Symbols: map[string][]int{"SZ002240": nil}},
+	}
+}
+
+func TestLoadExample(t *testing.T) {
+	t.Setenv("DTRADERHQ_TOKEN", "example-token")
+	cfg, err := Load("../examples/collector.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, err := cfg.Token(); err != nil || token != "example-token" {
+		t.Fatalf("Token() = %q, %v", token, err)
+	}
+	subs, err := cfg.SubscriptionList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, sub := range subs {
+		if sub.StockCode == "SZ002240" {
+			found = len(sub.DataTypes) == 2
+		}
+	}
+	if !found {
+		t.Fatalf("symbols override missing: %+v", subs)
+	}
+}
+
+func TestLoadRejectsUnknownField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "c.toml")
+	content := "[server]\nendpoints = [\"ws://127.0.0.1:8080/ws\"]\nunknown = 1\n[subscriptions]\nsymbols = { SZ002240 = [4] }\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("Load = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		err    string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"default batch size", func(c *Config) { c.Subscriptions.BatchSize = 0 }, ""},
+		{"batch size 1", func(c *Config) { c.Subscriptions.BatchSize = 1 }, ""},
+		{"max batch size", func(c *Config) { c.Subscriptions.BatchSize = dtraderhq.MaxBatchSize }, ""},
+		{"negative batch size", func(c *Config) { c.Subscriptions.BatchSize = -1 }, "batch_size"},
+		{"batch size too large", func(c *Config) { c.Subscriptions.BatchSize = dtraderhq.MaxBatchSize + 1 }, "batch_size"},
+		{"no endpoints", func(c *Config) { c.Server.Endpoints = nil }, "server.endpoints"},
+		{"http endpoint", func(c *Config) { c.Server.Endpoints = []string{"http://x"} }, "invalid websocket URL"},
+		{"closed data type", func(c *Config) { c.Subscriptions.DefaultTypes = []int{5} }, "not open"},
+		{"keepalive both", func(c *Config) { c.Server.Keepalive = "both" }, ""},
+		{"bad keepalive", func(c *Config) { c.Server.Keepalive = "tcp" }, "server.keepalive"},
+		{"bad endpoint keepalive", func(c *Config) {
+			c.Server.EndpointKeepalive = map[string]string{"ws://127.0.0.1:8080/ws": "tcp"}
+		}, "server.endpoint_keepalive"},
+		{"bad backpressure", func(c *Config) { c.Buffer.Backpressure = "drop" }, "backpressure"},
+		{"bad sink", func(c *Config) { c.Sinks = []Sink{{Format: "csv"}} }, "sinks[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestKeepaliveByEndpoint(t *testing.T) {
+	cfg := validConfig()
+	cfg.Server.Endpoints = []string{"ws://a/ws", "ws://b/ws", "ws://c/ws"}
+	cfg.Server.Keepalive = "control"
+	cfg.Server.EndpointKeepalive = map[string]string{"ws://b/ws": "json", "ws://c/ws": "both"}
+
+	fc := cfg.NewFailoverClient()
+	want := map[string]dtraderhq.KeepaliveMode{
+		"ws://a/ws": dtraderhq.KeepaliveControl,
+		"ws://b/ws": dtraderhq.KeepaliveJSON,
+		"ws://c/ws": dtraderhq.KeepaliveBoth,
+	}
+	for endpoint, mode := range want {
+		if fc.Keepalive[endpoint] != mode {
+			t.Errorf("%s keepalive = %s, want %s", endpoint, fc.Keepalive[endpoint], mode)
+		}
+	}
+}
+
+// batch_size 经 NewClient 作用于 SubscribeAll
+func TestBatchSizeAppliedToClient(t *testing.T) {
+	var mu sync.Mutex
+	batches := 0
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg map[string]any
+			_, b, err := conn.ReadMessage()
+			if err != nil || json.Unmarshal(b, &msg) != nil {
+				return
+			}
+			switch msg["type"] {
+			case dtraderhq.MessageTypeAuth:
+				conn.WriteJSON(map[string]any{"type": dtraderhq.MessageTypeAuth, "data": map[string]any{"message": "认证成功"}})
+			case dtraderhq.MessageTypeBatchSubscribe:
+				mu.Lock()
+				batches++
+				mu.Unlock()
+			}
+		}
+	}))
+	defer srv.Close()
+
+	cfg := validConfig()
+	cfg.Server.Endpoints = []string{"ws" + strings.TrimPrefix(srv.URL, "http")}
+	cfg.Subscriptions.BatchSize = 2
+	cfg.Credentials.Token = "token"
+
+	client := cfg.NewClient()
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Authenticate(""); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.WaitAuthenticated(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	subs := []dtraderhq.SubscribeMessage{
+		{StockCode: "SZ000001", DataTypes: []int{4}},
+		{StockCode: "SZ000002", DataTypes: []int{4}},
+		{StockCode: "SZ000003", DataTypes: []int{4}},
+	}
+	if err := client.SubscribeAll(subs); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		mu.Lock()
+		n := batches
+		mu.Unlock()
+		if n == 2 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d batches, want 2", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
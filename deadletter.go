@@ -0,0 +1,57 @@
+package dtraderhq
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// deadLetter 将无法解析的消息写入 io.Writer，每行一个 JSON 对象
+type deadLetter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// deadLetterRecord 死信记录，raw 为完整的原始消息
+type deadLetterRecord struct {
+	ReceivedAt time.Time `json:"received_at"`
+	Endpoint   string    `json:"endpoint"`
+	Type       string    `json:"type,omitempty"`
+	Error      string    `json:"error"`
+	Size       int       `json:"size"`
+	Raw        string    `json:"raw"`
+}
+
+// WithDeadLetter 将无法解析的消息完整写入 w，每行一个 JSON 对象，便于排查服务器格式变化
+//
+// 同一个选项用于多个客户端（如 NewPool）时共用同一个 w，写入时互斥。
+func WithDeadLetter(w io.Writer) Option {
+	if w == nil {
+		return func(*Client) {}
+	}
+	d := &deadLetter{w: w}
+	return func(c *Client) {
+		c.deadLetter = d
+	}
+}
+
+func (d *deadLetter) write(endpoint string, decodeErr *DecodeError, raw []byte, receivedAt time.Time) error {
+	line, err := json.Marshal(deadLetterRecord{
+		ReceivedAt: receivedAt,
+		Endpoint:   endpoint,
+		Type:       decodeErr.MessageType,
+		Error:      decodeErr.Err.Error(),
+		Size:       len(raw),
+		Raw:        string(raw),
+	})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err = d.w.Write(line)
+	return err
+}
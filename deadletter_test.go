@@ -0,0 +1,94 @@
+package dtraderhq
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeFailuresAndDeadLetter(t *testing.T) {
+	long := `{"type":"quote","data":{"note":"` + strings.Repeat("x", 1000) + `"}}`
+	tests := []struct {
+		name    string
+		raw     string
+		msgType string
+		unknown bool
+	}{
+		{"not json", `{"type":`, "", false},
+		{"bad data frame", `{"type":"data","data":{"stock_code":1}}`, MessageTypeData, false},
+		{"unknown type", long, "quote", true},
+	}
+
+	var buf syncBuffer
+	c := NewClient("ws://127.0.0.1:1", WithDeadLetter(&buf), WithErrorBuffer(len(tests)))
+	done := make(chan struct{})
+	for _, tt := range tests {
+		c.handleMessage([]byte(tt.raw), done)
+
+		var decodeErr *DecodeError
+		if err := <-c.ErrorChannel(); !errors.As(err, &decodeErr) {
+			t.Fatalf("%s: error = %v", tt.name, err)
+		}
+		if decodeErr.MessageType != tt.msgType || decodeErr.Size != len(tt.raw) || errors.Is(decodeErr, ErrUnknownMessageType) != tt.unknown {
+			t.Errorf("%s: DecodeError = %+v", tt.name, decodeErr)
+		}
+		if len(decodeErr.Raw) != min(len(tt.raw), maxRawPayload) {
+			t.Errorf("%s: kept %d bytes", tt.name, len(decodeErr.Raw))
+		}
+	}
+	if c.DecodeErrors() != uint64(len(tests)) {
+		t.Fatalf("DecodeErrors() = %d", c.DecodeErrors())
+	}
+
+	// 死信保留完整的原始消息
+	scanner := bufio.NewScanner(strings.NewReader(buf.String()))
+	scanner.Buffer(nil, 1<<20)
+	lines := 0
+	for i := 0; scanner.Scan(); i++ {
+		lines++
+		var rec deadLetterRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Raw != tests[i].raw || rec.Type != tests[i].msgType || rec.Size != len(tests[i].raw) || rec.Endpoint != "ws://127.0.0.1:1" || rec.Error == "" {
+			t.Errorf("dead letter %d = %+v", i, rec)
+		}
+	}
+	if lines != len(tests) {
+		t.Fatalf("wrote %d dead letters", lines)
+	}
+}
+
+// 错误通道已满时丢弃解析错误的报告，不阻塞读取
+func TestDecodeErrorChannelFull(t *testing.T) {
+	c := NewClient("ws://127.0.0.1:1", WithErrorBuffer(1))
+	handled := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			c.handleMessage([]byte(`{"type":"quote"}`), make(chan struct{}))
+		}
+		close(handled)
+	}()
+	select {
+	case <-handled:
+	case <-time.After(3 * time.Second):
+		t.Fatal("read loop blocked on a full error channel")
+	}
+	if c.DecodeErrors() != 3 || c.DroppedDecodeErrors() != 2 || len(c.ErrorChannel()) != 1 {
+		t.Fatalf("DecodeErrors() = %d, DroppedDecodeErrors() = %d, queued = %d", c.DecodeErrors(), c.DroppedDecodeErrors(), len(c.ErrorChannel()))
+	}
+}
+
+func TestDecodeErrorMessage(t *testing.T) {
+	err := &DecodeError{MessageType: "quote", Raw: []byte(`{"type":"quote"}`), Size: 16, Err: ErrUnknownMessageType}
+	if got := err.Error(); got != `decode "quote" message (16 bytes): unknown message type: {"type":"quote"}` {
+		t.Fatalf("Error() = %s", got)
+	}
+	err.MessageType = ""
+	if got := err.Error(); !strings.HasPrefix(got, "decode message (16 bytes)") {
+		t.Fatalf("Error() = %s", got)
+	}
+}
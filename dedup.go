@@ -0,0 +1,157 @@
+package dtraderhq
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultDedupWindow 每个股票、数据类型默认保留的去重键数量
+const defaultDedupWindow = 200000
+
+// streamKey 去重按股票和数据类型分别进行
+type streamKey struct {
+	stockCode string
+	dataType  int
+}
+
+// dedupStream 单个股票、数据类型在一个交易日内的去重状态
+type dedupStream struct {
+	day   int // 交易日，yyyymmdd
+	seen  map[int64]struct{}
+	max   int64
+	floor int64 // 低于该值的键视为已见过
+}
+
+// Deduper 基于记录自带序号去重：逐笔成交、逐笔大单使用 OrderPackId，逐笔委托使用 Index
+//
+// 序号每个交易日从头编号，去重按帧时间戳所属的交易日分别进行：某个股票、数据类型出现
+// 更晚交易日的帧时丢弃前一日的状态，之后再到达的更早交易日的记录不去重。
+// 每个股票、数据类型只保留当日最近 window 个序号，更早的序号一律视为重复。
+type Deduper struct {
+	mu      sync.Mutex
+	window  int64
+	streams map[streamKey]*dedupStream
+}
+
+// NewDeduper 创建去重器，window<=0 时使用默认值
+func NewDeduper(window int) *Deduper {
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+	return &Deduper{
+		window:  int64(window),
+		streams: make(map[streamKey]*dedupStream),
+	}
+}
+
+// Seen 标记交易日 day 的一个序号，返回该序号此前是否已出现过，day 只取交易所时区的日期
+func (d *Deduper) Seen(stockCode string, dataType int, day time.Time, id int64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.seenLocked(streamKey{stockCode: stockCode, dataType: dataType}, dayNumber(day), id)
+}
+
+func (d *Deduper) seenLocked(key streamKey, day int, id int64) bool {
+	s := d.streams[key]
+	if s != nil && day < s.day {
+		return false
+	}
+	if s == nil || day > s.day {
+		s = &dedupStream{day: day, seen: make(map[int64]struct{}), max: id, floor: id - d.window}
+		d.streams[key] = s
+	}
+
+	if id < s.floor {
+		return true
+	}
+	if _, ok := s.seen[id]; ok {
+		return true
+	}
+	s.seen[id] = struct{}{}
+
+	if id > s.max {
+		s.max = id
+	}
+	if int64(len(s.seen)) > 2*d.window {
+		s.floor = s.max - d.window
+		for k := range s.seen {
+			if k < s.floor {
+				delete(s.seen, k)
+			}
+		}
+	}
+	return false
+}
+
+// Filter 去掉帧内已出现过的记录，全部重复时返回 nil
+//
+// 返回的帧是新对象，MarketData.Data 被替换为去重后的类型化记录切片；
+// 未开放的数据类型没有序号，原样返回。
+func (d *Deduper) Filter(frame *DecodedFrame) *DecodedFrame {
+	key := streamKey{stockCode: frame.StockCode, dataType: frame.DataType}
+	day := frameDay(frame.MarketData)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	md := *frame.MarketData
+	out := &DecodedFrame{MarketData: &md}
+
+	switch frame.DataType {
+	case DataTypeTransaction:
+		for _, t := range frame.Transactions {
+			if !d.seenLocked(key, day, t.OrderPackId) {
+				out.Transactions = append(out.Transactions, t)
+			}
+		}
+		if len(out.Transactions) == 0 {
+			return nil
+		}
+		md.Data = out.Transactions
+	case DataTypeBigOrder:
+		for _, b := range frame.BigOrders {
+			if !d.seenLocked(key, day, b.OrderPackId) {
+				out.BigOrders = append(out.BigOrders, b)
+			}
+		}
+		if len(out.BigOrders) == 0 {
+			return nil
+		}
+		md.Data = out.BigOrders
+	case DataTypeZBWT:
+		times := frame.orderTimes()
+		for i, z := range frame.Orders {
+			if !d.seenLocked(key, day, z.Index) {
+				out.Orders = append(out.Orders, z)
+				if times != nil {
+					out.OrderTimes = append(out.OrderTimes, times[i])
+				}
+			}
+		}
+		if len(out.Orders) == 0 {
+			return nil
+		}
+		md.Data = out.Orders
+	default:
+		return frame
+	}
+
+	return out
+}
+
+// frameDay 帧所属的交易日：按帧时间戳，没有时按接收时间，都没有时为 0
+func frameDay(md *MarketData) int {
+	switch {
+	case md.Timestamp != 0:
+		return dayNumber(time.Unix(md.Timestamp, 0))
+	case !md.ReceivedAt.IsZero():
+		return dayNumber(md.ReceivedAt)
+	}
+	return 0
+}
+
+// dayNumber 返回 t 在交易所时区的日期，格式为 yyyymmdd
+func dayNumber(t time.Time) int {
+	y, m, d := t.In(ShanghaiLocation).Date()
+	return y*10000 + int(m)*100 + d
+}
@@ -0,0 +1,69 @@
+package dtraderhq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeduperSeen(t *testing.T) {
+	d := NewDeduper(10)
+	day1 := time.Date(2025, 6, 26, 9, 30, 0, 0, ShanghaiLocation)
+	day2 := day1.AddDate(0, 0, 1)
+	steps := []struct {
+		code string
+		day  time.Time
+		id   int64
+		seen bool
+	}{
+		{"SZ000001", day1, 100, false},
+		{"SZ000001", day1.Add(time.Hour), 100, true},
+		{"SZ000002", day1, 100, false}, // 不同股票分别去重
+		{"SZ000001", day1, 95, false},  // 乱序但在窗口内
+		{"SZ000001", day1, 85, true},   // 低于首个序号减窗口
+		{"SZ000001", day2, 1, false},   // 序号每个交易日重新编号
+		{"SZ000001", day2, 100, false},
+		{"SZ000001", day2, 1, true},
+		{"SZ000001", day1, 100, false}, // 更早交易日的记录不再去重
+	}
+	for i, s := range steps {
+		if got := d.Seen(s.code, DataTypeTransaction, s.day, s.id); got != s.seen {
+			t.Fatalf("step %d: Seen(%s, %d) = %v, want %v", i, s.code, s.id, got, s.seen)
+		}
+	}
+}
+
+func TestDeduperFilter(t *testing.T) {
+	d := NewDeduper(0)
+	frame := func(ids ...int64) *DecodedFrame {
+		orders := make([]ZBWT, len(ids))
+		times := make([]int64, len(ids))
+		for i, id := range ids {
+			orders[i] = ZBWT{Index: id, DateTime: 111257000 + id}
+			times[i] = id
+		}
+		return &DecodedFrame{
+			MarketData: &MarketData{StockCode: "SZ002240", DataType: DataTypeZBWT, Data: orders},
+			Orders:     orders,
+			OrderTimes: times,
+		}
+	}
+
+	if got := d.Filter(frame(1, 2, 3)); got == nil || len(got.Orders) != 3 {
+		t.Fatalf("first frame = %+v", got)
+	}
+	got := d.Filter(frame(2, 3, 4))
+	if got == nil || len(got.Orders) != 1 || got.Orders[0].Index != 4 || got.OrderTimes[0] != 4 {
+		t.Fatalf("overlapping frame = %+v", got)
+	}
+	if orders, _ := got.Data.([]ZBWT); len(orders) != 1 {
+		t.Fatalf("Data not replaced: %+v", got.Data)
+	}
+	if got := d.Filter(frame(1, 4)); got != nil {
+		t.Fatalf("duplicate frame = %+v", got)
+	}
+
+	raw := &DecodedFrame{MarketData: &MarketData{DataType: 99}}
+	if d.Filter(raw) != raw {
+		t.Fatal("frame without record ids was not passed through")
+	}
+}
@@ -0,0 +1,506 @@
+package dtraderhq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AlertKind 告警类型
+type AlertKind string
+
+// 内置检测器产生的告警类型
+const (
+	AlertLargeTrade  AlertKind = "large_trade"  // 大额成交
+	AlertCancelBurst AlertKind = "cancel_burst" // 同价位撤单激增
+	AlertFlashOrder  AlertKind = "flash_order"  // 报单后短时间内撤单
+	AlertPriceSweep  AlertKind = "price_sweep"  // 连续扫过多个价位
+)
+
+// Alert 检测器产生的告警事件，附带触发告警的原始记录
+type Alert struct {
+	Kind      AlertKind `json:"kind"`
+	Detector  string    `json:"detector"`
+	StockCode string    `json:"stock_code"`
+	Message   string    `json:"message"`
+	Price     int64     `json:"price"`     // 触发价位（原始值，需除以 PriceScale）
+	Volume    int64     `json:"volume"`    // 触发量
+	Timestamp int64     `json:"timestamp"` // 触发帧的时间戳
+
+	Transactions []Transaction `json:"transactions,omitempty"`
+	BigOrders    []BigOrder    `json:"big_orders,omitempty"`
+	Orders       []ZBWT        `json:"orders,omitempty"`
+}
+
+// DecodedFrame 已解码的数据帧，检测器共享同一次解码结果
+type DecodedFrame struct {
+	*MarketData
+	Transactions []Transaction
+	BigOrders    []BigOrder
+	Orders       []ZBWT
+	OrderTimes   []int64 // Orders 各条记录的当日毫秒数，见 OrderMillis
+}
+
+// DecodeFrame 按数据类型解码数据帧，未开放的数据类型只保留原始数据
+func DecodeFrame(md *MarketData) (*DecodedFrame, error) {
+	frame := &DecodedFrame{MarketData: md}
+
+	var err error
+	switch md.DataType {
+	case DataTypeTransaction:
+		frame.Transactions, err = DecodeTransactions(md)
+	case DataTypeBigOrder:
+		frame.BigOrders, err = DecodeBigOrders(md)
+	case DataTypeZBWT:
+		if frame.Orders, err = DecodeZBWT(md); err == nil {
+			frame.OrderTimes, err = OrderMillis(md, frame.Orders)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// OrderMillis 按股票所属市场解析逐笔委托的 DateTime，返回当日零点起的毫秒数；
+// 股票代码不带市场标识时以数据帧时间戳为参考判断格式
+func OrderMillis(md *MarketData, orders []ZBWT) ([]int64, error) {
+	format := MarketClockFormat(md.StockCode)
+	ref := int64(-1)
+	if md.Timestamp > 0 {
+		// 交易所时区为 UTC+8，没有夏令时
+		ref = (md.Timestamp + 8*3600) % 86400 * 1000
+	}
+
+	times := make([]int64, len(orders))
+	for i, z := range orders {
+		ms, err := ParseClock(z.DateTime, format, ref)
+		if err != nil {
+			return nil, fmt.Errorf("order %d: %w", z.Index, err)
+		}
+		times[i] = ms
+	}
+	return times, nil
+}
+
+// orderTimes 返回 OrderTimes，未填充时（例如手工构造的帧）按 OrderMillis 解析，无法解析时返回 nil
+func (f *DecodedFrame) orderTimes() []int64 {
+	if len(f.OrderTimes) == len(f.Orders) {
+		return f.OrderTimes
+	}
+	times, err := OrderMillis(f.MarketData, f.Orders)
+	if err != nil {
+		return nil
+	}
+	f.OrderTimes = times
+	return times
+}
+
+// timedOrder 带有已解析时刻的逐笔委托
+type timedOrder struct {
+	ZBWT
+	at int64 // 当日毫秒数
+}
+
+func untimed(orders []timedOrder) []ZBWT {
+	out := make([]ZBWT, len(orders))
+	for i, o := range orders {
+		out[i] = o.ZBWT
+	}
+	return out
+}
+
+// Detector 订单流检测器
+//
+// Observe 由 DetectorEngine 串行调用，实现无需自行加锁。
+type Detector interface {
+	Name() string
+	Observe(frame *DecodedFrame) []Alert
+}
+
+// DetectorEngine 组合多个检测器，对数据帧解码一次后依次分发
+type DetectorEngine struct {
+	mu        sync.Mutex
+	detectors []Detector
+}
+
+// NewDetectorEngine 创建检测引擎
+func NewDetectorEngine(detectors ...Detector) *DetectorEngine {
+	return &DetectorEngine{detectors: detectors}
+}
+
+// Add 添加检测器
+func (e *DetectorEngine) Add(d Detector) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.detectors = append(e.detectors, d)
+}
+
+// Process 处理一帧数据，返回所有检测器产生的告警
+func (e *DetectorEngine) Process(md *MarketData) ([]Alert, error) {
+	frame, err := DecodeFrame(md)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var alerts []Alert
+	for _, d := range e.detectors {
+		for _, alert := range d.Observe(frame) {
+			if alert.Detector == "" {
+				alert.Detector = d.Name()
+			}
+			if alert.StockCode == "" {
+				alert.StockCode = md.StockCode
+			}
+			if alert.Timestamp == 0 {
+				alert.Timestamp = md.Timestamp
+			}
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+// Run 持续消费数据通道并输出告警，in 关闭或 ctx 取消后关闭返回的通道
+//
+// 解码失败的数据帧会被跳过。
+func (e *DetectorEngine) Run(ctx context.Context, in <-chan *MarketData) <-chan Alert {
+	out := make(chan Alert, 100)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case md, ok := <-in:
+				if !ok {
+					return
+				}
+				alerts, err := e.Process(md)
+				if err != nil {
+					continue
+				}
+				for _, alert := range alerts {
+					select {
+					case out <- alert:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// LargeTradeDetector 大额成交检测器
+//
+// 对逐笔成交（4）按单笔成交判断，对逐笔大单（8）按买卖双方委托量判断，
+// 同一笔委托只告警一次。MinVolume 与 MinAmount 为0表示不启用该条件。
+type LargeTradeDetector struct {
+	MinVolume int64   // 最小成交量（股）
+	MinAmount float64 // 最小成交额（元）
+
+	seen map[string]map[int64]struct{} // stockCode -> 已告警的委托号
+}
+
+// Name 检测器名称
+func (d *LargeTradeDetector) Name() string { return "large_trade" }
+
+func (d *LargeTradeDetector) hit(volume, price int64) bool {
+	if d.MinVolume > 0 && volume >= d.MinVolume {
+		return true
+	}
+	amount := float64(volume) * float64(price) / PriceScale
+	return d.MinAmount > 0 && amount >= d.MinAmount
+}
+
+// markSeen 记录已告警的委托号，返回该委托是否首次告警
+func (d *LargeTradeDetector) markSeen(stockCode string, orderID int64) bool {
+	if d.seen == nil {
+		d.seen = make(map[string]map[int64]struct{})
+	}
+	ids := d.seen[stockCode]
+	if ids == nil || len(ids) > 100000 {
+		ids = make(map[int64]struct{})
+		d.seen[stockCode] = ids
+	}
+	if _, ok := ids[orderID]; ok {
+		return false
+	}
+	ids[orderID] = struct{}{}
+	return true
+}
+
+// Observe 检测大额成交
+func (d *LargeTradeDetector) Observe(frame *DecodedFrame) []Alert {
+	var alerts []Alert
+
+	for _, t := range frame.Transactions {
+		if !d.hit(t.AbsVolume(), t.Price) {
+			continue
+		}
+		alerts = append(alerts, Alert{
+			Kind:         AlertLargeTrade,
+			Message:      fmt.Sprintf("large trade %d @ %.2f", t.Volume, t.RealPrice()),
+			Price:        t.Price,
+			Volume:       t.Volume,
+			Transactions: []Transaction{t},
+		})
+	}
+
+	for _, b := range frame.BigOrders {
+		if d.hit(b.BuyVol, b.BuyPrice) && d.markSeen(frame.StockCode, b.BuyOrderPackId) {
+			alerts = append(alerts, Alert{
+				Kind:      AlertLargeTrade,
+				Message:   fmt.Sprintf("large buy order %d: %d @ %.2f", b.BuyOrderPackId, b.BuyVol, float64(b.BuyPrice)/PriceScale),
+				Price:     b.BuyPrice,
+				Volume:    b.BuyVol,
+				BigOrders: []BigOrder{b},
+			})
+		}
+		if d.hit(b.SellVol, b.SellPrice) && d.markSeen(frame.StockCode, -b.SellOrderPackId-1) {
+			alerts = append(alerts, Alert{
+				Kind:      AlertLargeTrade,
+				Message:   fmt.Sprintf("large sell order %d: %d @ %.2f", b.SellOrderPackId, b.SellVol, float64(b.SellPrice)/PriceScale),
+				Price:     b.SellPrice,
+				Volume:    -b.SellVol,
+				BigOrders: []BigOrder{b},
+			})
+		}
+	}
+
+	return alerts
+}
+
+// priceSideKey 同一股票内按方向和价位分组
+type priceSideKey struct {
+	stockCode string
+	buy       bool
+	price     int64
+}
+
+// CancelBurstDetector 同价位撤单激增检测器（基于逐笔委托的 BD/SD）
+//
+// 在 Window 时间窗内同一方向同一价位的撤单笔数达到 MinCount 时告警，
+// 告警后该价位重新计数。
+type CancelBurstDetector struct {
+	Window   time.Duration
+	MinCount int
+
+	cancels map[priceSideKey][]timedOrder
+}
+
+// Name 检测器名称
+func (d *CancelBurstDetector) Name() string { return "cancel_burst" }
+
+// Observe 检测撤单激增
+func (d *CancelBurstDetector) Observe(frame *DecodedFrame) []Alert {
+	if d.cancels == nil {
+		d.cancels = make(map[priceSideKey][]timedOrder)
+	}
+	window := d.Window.Milliseconds()
+	times := frame.orderTimes()
+	if times == nil {
+		return nil
+	}
+
+	var alerts []Alert
+	for i, z := range frame.Orders {
+		if !z.Type.IsCancel() {
+			continue
+		}
+
+		key := priceSideKey{stockCode: frame.StockCode, buy: z.Type.IsBuy(), price: z.Price}
+		now := times[i]
+
+		bucket := d.cancels[key][:0:0]
+		for _, prev := range d.cancels[key] {
+			if now-prev.at <= window {
+				bucket = append(bucket, prev)
+			}
+		}
+		bucket = append(bucket, timedOrder{ZBWT: z, at: now})
+
+		if len(bucket) < d.MinCount {
+			d.cancels[key] = bucket
+			continue
+		}
+
+		var volume int64
+		for _, c := range bucket {
+			volume += c.Volume
+		}
+		alerts = append(alerts, Alert{
+			Kind:    AlertCancelBurst,
+			Message: fmt.Sprintf("%d %s cancels @ %.2f within %s", len(bucket), z.Type, z.RealPrice(), d.Window),
+			Price:   z.Price,
+			Volume:  volume,
+			Orders:  untimed(bucket),
+		})
+		delete(d.cancels, key)
+	}
+
+	// 价位过多时整体重置，避免长时间运行时内存增长
+	if len(d.cancels) > 10000 {
+		d.cancels = make(map[priceSideKey][]timedOrder)
+	}
+
+	return alerts
+}
+
+// orderShapeKey 用于匹配报单与撤单（逐笔委托中没有原始委托号，按方向、价格、数量匹配）
+type orderShapeKey struct {
+	stockCode string
+	buy       bool
+	price     int64
+	volume    int64
+}
+
+// FlashOrderDetector 闪撤检测器：报单后在 MaxLifetime 内被撤销
+//
+// 逐笔委托不携带原始委托号，撤单与此前同方向、同价格、同数量的最近一笔报单配对。
+type FlashOrderDetector struct {
+	MaxLifetime time.Duration
+
+	pending map[orderShapeKey][]timedOrder
+}
+
+// Name 检测器名称
+func (d *FlashOrderDetector) Name() string { return "flash_order" }
+
+// Observe 检测闪撤
+func (d *FlashOrderDetector) Observe(frame *DecodedFrame) []Alert {
+	if d.pending == nil {
+		d.pending = make(map[orderShapeKey][]timedOrder)
+	}
+	lifetime := d.MaxLifetime.Milliseconds()
+	times := frame.orderTimes()
+	if times == nil {
+		return nil
+	}
+
+	var alerts []Alert
+	for i, z := range frame.Orders {
+		key := orderShapeKey{stockCode: frame.StockCode, buy: z.Type.IsBuy(), price: z.Price, volume: z.Volume}
+		now := times[i]
+
+		// 丢弃已超出存活时间的报单
+		adds := d.pending[key][:0:0]
+		for _, prev := range d.pending[key] {
+			if now-prev.at <= lifetime {
+				adds = append(adds, prev)
+			}
+		}
+
+		if !z.Type.IsCancel() {
+			d.pending[key] = append(adds, timedOrder{ZBWT: z, at: now})
+			continue
+		}
+
+		// 同一帧内记录可能乱序，只与撤单时间之前的报单配对
+		match := -1
+		for j := len(adds) - 1; j >= 0; j-- {
+			if adds[j].at <= now {
+				match = j
+				break
+			}
+		}
+		if match < 0 {
+			d.pending[key] = adds
+			continue
+		}
+
+		add := adds[match]
+		d.pending[key] = append(adds[:match], adds[match+1:]...)
+		alerts = append(alerts, Alert{
+			Kind:    AlertFlashOrder,
+			Message: fmt.Sprintf("%s %d @ %.2f cancelled after %dms", add.Type, z.Volume, z.RealPrice(), now-add.at),
+			Price:   z.Price,
+			Volume:  z.Volume,
+			Orders:  []ZBWT{add.ZBWT, z},
+		})
+	}
+
+	if len(d.pending) > 100000 {
+		d.pending = make(map[orderShapeKey][]timedOrder)
+	}
+
+	return alerts
+}
+
+// sweepState 单只股票的扫单状态
+type sweepState struct {
+	buy     bool
+	start   int64
+	last    int64
+	levels  int
+	alerted bool
+	trades  []Transaction
+}
+
+// PriceSweepDetector 扫价位检测器（基于逐笔成交）
+//
+// 同方向的连续成交在 Window 内单调穿过至少 MinLevels 个价位时告警，
+// 反向成交、价格回头或超出时间窗都会重新开始计数。
+type PriceSweepDetector struct {
+	Window    time.Duration
+	MinLevels int
+
+	states map[string]*sweepState
+}
+
+// Name 检测器名称
+func (d *PriceSweepDetector) Name() string { return "price_sweep" }
+
+// Observe 检测扫价位
+func (d *PriceSweepDetector) Observe(frame *DecodedFrame) []Alert {
+	if d.states == nil {
+		d.states = make(map[string]*sweepState)
+	}
+	window := int64(d.Window / time.Second)
+
+	var alerts []Alert
+	for _, t := range frame.Transactions {
+		if t.Volume == 0 {
+			continue
+		}
+
+		st := d.states[frame.StockCode]
+		reset := st == nil || st.buy != t.IsBuy() || t.Time-st.start > window ||
+			(st.buy && t.Price < st.last) || (!st.buy && t.Price > st.last)
+		if reset {
+			st = &sweepState{buy: t.IsBuy(), start: t.Time, last: t.Price, levels: 1}
+			d.states[frame.StockCode] = st
+		} else if t.Price != st.last {
+			st.levels++
+			st.last = t.Price
+		}
+		st.trades = append(st.trades, t)
+
+		if st.alerted || st.levels < d.MinLevels {
+			continue
+		}
+		st.alerted = true
+
+		var volume int64
+		for _, tr := range st.trades {
+			volume += tr.Volume
+		}
+		first := st.trades[0]
+		alerts = append(alerts, Alert{
+			Kind: AlertPriceSweep,
+			Message: fmt.Sprintf("sweep across %d levels %.2f -> %.2f",
+				st.levels, first.RealPrice(), t.RealPrice()),
+			Price:        t.Price,
+			Volume:       volume,
+			Transactions: append([]Transaction(nil), st.trades...),
+		})
+	}
+
+	return alerts
+}
@@ -0,0 +1,150 @@
+package dtraderhq
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// frameAt 2025-06-27 11:13:01 CST，与 SH603065 录制数据的第一帧相同
+const frameAt = 1750993981
+
+func ordersFrame(stockCode string, orders ...ZBWT) *MarketData {
+	return &MarketData{StockCode: stockCode, DataType: DataTypeZBWT, Timestamp: frameAt, Data: orders}
+}
+
+func process(t *testing.T, e *DetectorEngine, md *MarketData) []Alert {
+	t.Helper()
+	alerts, err := e.Process(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alerts
+}
+
+func TestCancelBurstDetector(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		times  []int64
+		alerts int
+	}{
+		// 沪市 HHMMSScc：相隔 0.63 秒，按 HHMMSSmmm 解析会相隔 4 秒以上
+		{"SH within window", "SH603065", []int64{11125987, 11130010, 11130050}, 1},
+		{"SH outside window", "SH603065", []int64{11125987, 11130010, 11130190}, 0},
+		{"SZ within window", "SZ002062", []int64{111259870, 111300100, 111300500}, 1},
+		{"SZ outside window", "SZ002062", []int64{111259870, 111300100, 111301900}, 0},
+		{"bare code", "603065", []int64{11125987, 11130010, 11130050}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewDetectorEngine(&CancelBurstDetector{Window: time.Second, MinCount: 3})
+			var got []Alert
+			for i, v := range tt.times {
+				z := ZBWT{Index: int64(i), DateTime: v, Price: 976, Volume: 100, Type: OrderTypeSellCancel}
+				got = append(got, process(t, e, ordersFrame(tt.code, z))...)
+			}
+			if len(got) != tt.alerts {
+				t.Fatalf("alerts = %+v, want %d", got, tt.alerts)
+			}
+			if tt.alerts > 0 && (got[0].Kind != AlertCancelBurst || len(got[0].Orders) != 3 || got[0].Volume != 300) {
+				t.Fatalf("alert = %+v", got[0])
+			}
+		})
+	}
+}
+
+func TestFlashOrderDetector(t *testing.T) {
+	e := NewDetectorEngine(&FlashOrderDetector{MaxLifetime: 500 * time.Millisecond})
+
+	add := ZBWT{Index: 1, DateTime: 11125987, Price: 976, Volume: 200, Type: OrderTypeSellAdd}
+	other := ZBWT{Index: 2, DateTime: 11130000, Price: 976, Volume: 300, Type: OrderTypeSellAdd}
+	cancel := ZBWT{Index: 3, DateTime: 11130010, Price: 976, Volume: 200, Type: OrderTypeSellCancel}
+
+	if alerts := process(t, e, ordersFrame("SH603065", add, other)); len(alerts) != 0 {
+		t.Fatalf("unexpected alerts %+v", alerts)
+	}
+	alerts := process(t, e, ordersFrame("SH603065", cancel))
+	if len(alerts) != 1 {
+		t.Fatalf("alerts = %+v, want 1", alerts)
+	}
+	if a := alerts[0]; a.Kind != AlertFlashOrder || !strings.Contains(a.Message, "after 230ms") ||
+		a.Orders[0].Index != 1 || a.StockCode != "SH603065" || a.Timestamp != frameAt {
+		t.Fatalf("alert = %+v", a)
+	}
+
+	// 报单已被配对，再次撤单不会告警；超过存活时间的撤单也不会告警
+	late := ZBWT{Index: 4, DateTime: 11130100, Price: 976, Volume: 300, Type: OrderTypeSellCancel}
+	if alerts := process(t, e, ordersFrame("SH603065", cancel, late)); len(alerts) != 0 {
+		t.Fatalf("unexpected alerts %+v", alerts)
+	}
+}
+
+func TestLargeTradeDetector(t *testing.T) {
+	e := NewDetectorEngine(&LargeTradeDetector{MinAmount: 100000})
+
+	trades := &MarketData{StockCode: "SH603065", DataType: DataTypeTransaction, Timestamp: frameAt, Data: []Transaction{
+		{OrderPackId: 1, Price: 975, Time: frameAt, Volume: -4500},
+		{OrderPackId: 2, Price: 975, Time: frameAt, Volume: 20000},
+	}}
+	alerts := process(t, e, trades)
+	if len(alerts) != 1 || alerts[0].Volume != 20000 {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+
+	big := &MarketData{StockCode: "SH603065", DataType: DataTypeBigOrder, Timestamp: frameAt, Data: []BigOrder{
+		{OrderPackId: 1, BuyOrderPackId: 10, SellOrderPackId: 11, BuyPrice: 975, SellPrice: 975, BuyVol: 20000, SellVol: 100},
+	}}
+	if alerts := process(t, e, big); len(alerts) != 1 || alerts[0].Volume != 20000 {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	// 同一委托只告警一次
+	if alerts := process(t, e, big); len(alerts) != 0 {
+		t.Fatalf("repeated alerts %+v", alerts)
+	}
+}
+
+func TestPriceSweepDetector(t *testing.T) {
+	e := NewDetectorEngine(&PriceSweepDetector{Window: 3 * time.Second, MinLevels: 3})
+
+	frame := func(trades ...Transaction) *MarketData {
+		return &MarketData{StockCode: "SZ002062", DataType: DataTypeTransaction, Timestamp: frameAt, Data: trades}
+	}
+	alerts := process(t, e, frame(
+		Transaction{OrderPackId: 1, Price: 479, Time: frameAt, Volume: 100},
+		Transaction{OrderPackId: 2, Price: 480, Time: frameAt, Volume: 100},
+		Transaction{OrderPackId: 3, Price: 480, Time: frameAt + 1, Volume: 100},
+		Transaction{OrderPackId: 4, Price: 481, Time: frameAt + 2, Volume: 200},
+		Transaction{OrderPackId: 5, Price: 482, Time: frameAt + 2, Volume: 100},
+	))
+	if len(alerts) != 1 || alerts[0].Volume != 500 || len(alerts[0].Transactions) != 4 {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+
+	// 反向成交后重新计数
+	alerts = process(t, e, frame(
+		Transaction{OrderPackId: 6, Price: 481, Time: frameAt + 3, Volume: -100},
+		Transaction{OrderPackId: 7, Price: 480, Time: frameAt + 3, Volume: -100},
+	))
+	if len(alerts) != 0 {
+		t.Fatalf("unexpected alerts %+v", alerts)
+	}
+}
+
+// 在录制的沪市逐笔委托上运行时，闪撤间隔不会因时间格式错误而出现负数或超过存活时间
+func TestFlashOrderDetectorSample(t *testing.T) {
+	d := &FlashOrderDetector{MaxLifetime: 2 * time.Second}
+	for _, md := range loadSample(t, "SH603166_order_20250627.json") {
+		frame, err := DecodeFrame(md)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range d.Observe(frame) {
+			first, _ := ParseClock(a.Orders[0].DateTime, ClockCentis, -1)
+			last, _ := ParseClock(a.Orders[1].DateTime, ClockCentis, -1)
+			if lifetime := last - first; lifetime < 0 || lifetime > 2000 {
+				t.Fatalf("alert %q with lifetime %dms", a.Message, lifetime)
+			}
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package dtraderhq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultArbitrationWindow 等待另一路行情送达同一记录的默认时长
+const defaultArbitrationWindow = 5 * time.Second
+
+// FeedStats 单路行情的到达统计
+type FeedStats struct {
+	URL        string
+	Frames     uint64        // 收到的数据帧数
+	Records    uint64        // 收到的记录数（含重复）
+	Led        uint64        // 先于另一路到达的记录数
+	Lagged     uint64        // 晚于另一路到达的记录数
+	Exclusive  uint64        // 窗口内另一路始终没有送达的记录数
+	TotalLag   time.Duration // 落后时的累计落后时长
+	MaxLag     time.Duration
+	Reconnects uint64
+}
+
+// MeanLag 落后时的平均落后时长
+func (s FeedStats) MeanLag() time.Duration {
+	if s.Lagged == 0 {
+		return 0
+	}
+	return s.TotalLag / time.Duration(s.Lagged)
+}
+
+// LeadRatio 两路都送达的记录中本路领先的比例
+func (s FeedStats) LeadRatio() float64 {
+	if s.Led+s.Lagged == 0 {
+		return 0
+	}
+	return float64(s.Led) / float64(s.Led+s.Lagged)
+}
+
+// recordKey 跨两路行情匹配同一条记录
+type recordKey struct {
+	stream streamKey
+	id     int64
+}
+
+// arrival 记录首次到达的来源和时间
+type arrival struct {
+	feed int
+	at   time.Time
+}
+
+// DualFeed 在两个服务器上同时订阅，合并两路行情，每条记录（按 OrderPackId / Index）取先到的一份
+//
+// 一路断开时另一路继续输出，不会出现数据缺口；断开的一路在 Run 中自动重连并恢复订阅。
+// 未开放的数据类型没有记录序号，只输出第一路的数据，第一路未认证期间改为输出第二路的数据。
+type DualFeed struct {
+	// Window 等待另一路送达同一记录的时长，超过后记为 Exclusive，默认 5s
+	Window time.Duration
+
+	feeds     [2]*Client
+	dataChan  chan *MarketData
+	errorChan chan error
+
+	mu        sync.Mutex
+	deduper   *Deduper
+	pending   map[recordKey]arrival
+	stats     [2]FeedStats
+	lastSweep time.Time
+}
+
+// NewDualFeed 合并两个客户端的行情，客户端需已连接并认证
+func NewDualFeed(primary, secondary *Client) *DualFeed {
+	d := &DualFeed{
+		feeds:     [2]*Client{primary, secondary},
+		dataChan:  make(chan *MarketData, cap(primary.dataChan)),
+		errorChan: make(chan error, cap(primary.errorChan)),
+		deduper:   NewDeduper(0),
+		pending:   make(map[recordKey]arrival),
+	}
+	for i, c := range d.feeds {
+		d.stats[i].URL = c.URL()
+	}
+	return d
+}
+
+// DataChannel 获取合并后的数据通道
+func (d *DualFeed) DataChannel() <-chan *MarketData {
+	return d.dataChan
+}
+
+// ErrorChannel 获取两路的错误通道，错误带有来源地址
+func (d *DualFeed) ErrorChannel() <-chan error {
+	return d.errorChan
+}
+
+// Stats 返回两路行情的到达统计
+func (d *DualFeed) Stats() [2]FeedStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sweepLocked(time.Now())
+	return d.stats
+}
+
+// SubscribeAll 在两路上订阅，任一路失败时返回错误
+func (d *DualFeed) SubscribeAll(subscriptions []SubscribeMessage) error {
+	for _, c := range d.feeds {
+		if err := c.SubscribeAll(subscriptions); err != nil {
+			return fmt.Errorf("%s: %w", c.URL(), err)
+		}
+	}
+	return nil
+}
+
+// BatchSubscribe 在两路上批量订阅
+func (d *DualFeed) BatchSubscribe(subscriptions []SubscribeMessage) error {
+	for _, c := range d.feeds {
+		if err := c.BatchSubscribe(subscriptions); err != nil {
+			return fmt.Errorf("%s: %w", c.URL(), err)
+		}
+	}
+	return nil
+}
+
+// Subscribe 在两路上订阅单只股票
+func (d *DualFeed) Subscribe(stockCode string, dataTypes []int) error {
+	return d.BatchSubscribe([]SubscribeMessage{{StockCode: stockCode, DataTypes: dataTypes}})
+}
+
+// BatchUnsubscribe 在两路上批量取消订阅
+func (d *DualFeed) BatchUnsubscribe(stockCodes []string) error {
+	for _, c := range d.feeds {
+		if err := c.BatchUnsubscribe(stockCodes); err != nil {
+			return fmt.Errorf("%s: %w", c.URL(), err)
+		}
+	}
+	return nil
+}
+
+// Unsubscribe 在两路上取消订阅单只股票
+func (d *DualFeed) Unsubscribe(stockCode string) error {
+	return d.BatchUnsubscribe([]string{stockCode})
+}
+
+// Run 合并两路行情直到 ctx 结束，断开的一路自动重连
+//
+// 重连时从客户端的 TokenProvider 获取 token，未设置时沿用该客户端上次认证的 token。
+func (d *DualFeed) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := range d.feeds {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			d.consume(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// consume 读取一路行情，连接断开时按指数退避重连
+func (d *DualFeed) consume(ctx context.Context, i int) {
+	c := d.feeds[i]
+	done := c.Done()
+	for {
+		select {
+		case md := <-c.DataChannel():
+			out, err := d.arbitrate(i, md)
+			if err != nil {
+				d.reportError(ctx, fmt.Errorf("%s: %w", c.URL(), err))
+				continue
+			}
+			if out != nil {
+				select {
+				case d.dataChan <- out:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+		case err := <-c.ErrorChannel():
+			d.reportError(ctx, fmt.Errorf("%s: %w", c.URL(), err))
+
+		case <-done:
+			if ctx.Err() != nil {
+				return
+			}
+			if err := d.reconnect(ctx, c); err != nil {
+				return
+			}
+			d.mu.Lock()
+			d.stats[i].Reconnects++
+			d.mu.Unlock()
+			done = c.Done()
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *DualFeed) reconnect(ctx context.Context, c *Client) error {
+	backoff := time.Second
+	for {
+		// token 为空时由 Authenticate 从 TokenProvider 获取
+		token := ""
+		if c.tokens == nil {
+			token = c.currentToken()
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := c.Reconnect(attemptCtx, token)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		d.reportError(ctx, fmt.Errorf("%s: reconnect: %w", c.URL(), err))
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (d *DualFeed) reportError(ctx context.Context, err error) {
+	select {
+	case d.errorChan <- err:
+	case <-ctx.Done():
+	}
+}
+
+// arbitrate 保留帧内首次到达的记录并更新领先/落后统计，全部记录都已由另一路送达时返回 nil
+func (d *DualFeed) arbitrate(feed int, md *MarketData) (*MarketData, error) {
+	frame, err := DecodeFrame(md)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s/%d: %w", md.StockCode, md.DataType, err)
+	}
+
+	at := md.ReceivedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sweepLocked(at)
+	stats := &d.stats[feed]
+	stats.Frames++
+
+	stream := streamKey{stockCode: md.StockCode, dataType: md.DataType}
+	day := frameDay(md)
+	first := func(id int64) bool {
+		stats.Records++
+		key := recordKey{stream: stream, id: id}
+		if !d.deduper.seenLocked(stream, day, id) {
+			d.pending[key] = arrival{feed: feed, at: at}
+			return true
+		}
+		if prev, ok := d.pending[key]; ok && prev.feed != feed {
+			delete(d.pending, key)
+			lag := at.Sub(prev.at)
+			stats.Lagged++
+			stats.TotalLag += lag
+			if lag > stats.MaxLag {
+				stats.MaxLag = lag
+			}
+			d.stats[prev.feed].Led++
+		}
+		return false
+	}
+
+	out := *md
+	switch md.DataType {
+	case DataTypeTransaction:
+		var kept []Transaction
+		for _, t := range frame.Transactions {
+			if first(t.OrderPackId) {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) == 0 {
+			return nil, nil
+		}
+		out.Data = kept
+	case DataTypeBigOrder:
+		var kept []BigOrder
+		for _, b := range frame.BigOrders {
+			if first(b.OrderPackId) {
+				kept = append(kept, b)
+			}
+		}
+		if len(kept) == 0 {
+			return nil, nil
+		}
+		out.Data = kept
+	case DataTypeZBWT:
+		var kept []ZBWT
+		for _, z := range frame.Orders {
+			if first(z.Index) {
+				kept = append(kept, z)
+			}
+		}
+		if len(kept) == 0 {
+			return nil, nil
+		}
+		out.Data = kept
+	default:
+		// 没有记录序号无法去重，第一路不可用时由第二路补上
+		if feed != 0 && d.feeds[0].IsAuthenticated() {
+			return nil, nil
+		}
+	}
+	return &out, nil
+}
+
+// sweepLocked 清理超过窗口仍未被另一路送达的记录
+func (d *DualFeed) sweepLocked(now time.Time) {
+	window := d.Window
+	if window <= 0 {
+		window = defaultArbitrationWindow
+	}
+	if now.Sub(d.lastSweep) < window {
+		return
+	}
+	d.lastSweep = now
+
+	for key, a := range d.pending {
+		if now.Sub(a.at) > window {
+			d.stats[a.feed].Exclusive++
+			delete(d.pending, key)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package dtraderhq
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func transactionFrame(at time.Time, ids ...int64) *MarketData {
+	records := make([]Transaction, len(ids))
+	for i, id := range ids {
+		records[i] = Transaction{OrderPackId: id, Price: 1000, Volume: 100}
+	}
+	return &MarketData{StockCode: "SZ000001", DataType: int(DataTypeTransaction), Data: records, ReceivedAt: at}
+}
+
+func TestDualFeedArbitrate(t *testing.T) {
+	d := NewDualFeed(NewClient("ws://primary"), NewClient("ws://secondary"))
+	base := time.Now().Add(-time.Minute)
+
+	out, err := d.arbitrate(0, transactionFrame(base, 1, 2))
+	if err != nil || !reflect.DeepEqual(ids(out), []int64{1, 2}) {
+		t.Fatalf("feed 0 = %v, %v", ids(out), err)
+	}
+	// 第二路只输出第一路没有送达的记录
+	out, err = d.arbitrate(1, transactionFrame(base.Add(10*time.Millisecond), 2, 3))
+	if err != nil || !reflect.DeepEqual(ids(out), []int64{3}) {
+		t.Fatalf("feed 1 = %v, %v", ids(out), err)
+	}
+	if out, _ = d.arbitrate(0, transactionFrame(base.Add(30*time.Millisecond), 3)); out != nil {
+		t.Fatalf("duplicate frame passed: %v", ids(out))
+	}
+
+	stats := d.Stats()
+	if stats[0].Led != 1 || stats[0].Lagged != 1 || stats[1].Led != 1 || stats[1].Lagged != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if stats[1].MaxLag != 10*time.Millisecond || stats[0].MaxLag != 20*time.Millisecond {
+		t.Fatalf("lag = %s, %s", stats[0].MaxLag, stats[1].MaxLag)
+	}
+	// 记录 1 超过窗口仍未由第二路送达
+	if stats[0].Exclusive != 1 || stats[0].Records != 3 || stats[1].Records != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+// 跨过交易日后序号重新编号，不应被当作前一日已送达的记录
+func TestDualFeedArbitrateNextDay(t *testing.T) {
+	d := NewDualFeed(NewClient("ws://primary"), NewClient("ws://secondary"))
+	day1 := time.Date(2025, 6, 26, 14, 59, 0, 0, ShanghaiLocation)
+	day2 := time.Date(2025, 6, 27, 9, 30, 0, 0, ShanghaiLocation)
+
+	frame := transactionFrame(day1, 1, 2)
+	frame.Timestamp = day1.Unix()
+	if out, _ := d.arbitrate(0, frame); !reflect.DeepEqual(ids(out), []int64{1, 2}) {
+		t.Fatalf("day 1 = %v", ids(out))
+	}
+	frame = transactionFrame(day2, 1, 2)
+	frame.Timestamp = day2.Unix()
+	if out, _ := d.arbitrate(1, frame); !reflect.DeepEqual(ids(out), []int64{1, 2}) {
+		t.Fatalf("day 2 = %v", ids(out))
+	}
+}
+
+func ids(md *MarketData) []int64 {
+	if md == nil {
+		return nil
+	}
+	var out []int64
+	for _, t := range md.Data.([]Transaction) {
+		out = append(out, t.OrderPackId)
+	}
+	return out
+}
+
+// 没有记录序号的数据只取第一路，第一路断开时由第二路补上
+func TestDualFeedUntypedFallback(t *testing.T) {
+	primary := connectTestClient(t, newTestServer(t))
+	secondary := connectTestClient(t, newTestServer(t))
+	d := NewDualFeed(primary, secondary)
+	frame := &MarketData{StockCode: "SZ000001", DataType: 1, Data: []any{}}
+
+	if out, _ := d.arbitrate(0, frame); out == nil {
+		t.Fatal("primary frame dropped")
+	}
+	if out, _ := d.arbitrate(1, frame); out != nil {
+		t.Fatal("secondary frame passed while primary is up")
+	}
+	primary.Close()
+	if out, _ := d.arbitrate(1, frame); out == nil {
+		t.Fatal("secondary frame dropped while primary is down")
+	}
+}
+
+// 重连时使用 TokenProvider，未设置时沿用上次的 token
+func TestDualFeedReconnectUsesTokenProvider(t *testing.T) {
+	srv0, srv1 := newTestServer(t), newTestServer(t)
+	var fetched atomic.Int32
+	primary := connectTestClient(t, srv0, WithTokenProvider(TokenProviderFunc(func(context.Context) (string, error) {
+		fetched.Add(1)
+		return "fresh", nil
+	})))
+	secondary := connectTestClient(t, srv1)
+	d := NewDualFeed(primary, secondary)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	srv0.dropConnections()
+	srv1.dropConnections()
+	waitFor(t, "reconnect", func() bool {
+		stats := d.Stats()
+		return stats[0].Reconnects == 1 && stats[1].Reconnects == 1
+	})
+
+	lastToken := func(srv *testServer) any {
+		auths := srv.messages(MessageTypeAuth)
+		return auths[len(auths)-1]["data"].(map[string]any)["token"]
+	}
+	if got := lastToken(srv0); got != "fresh" || fetched.Load() != 1 {
+		t.Fatalf("primary re-authenticated with %v, fetched %d", got, fetched.Load())
+	}
+	if got := lastToken(srv1); got != "token" {
+		t.Fatalf("secondary re-authenticated with %v", got)
+	}
+}
@@ -0,0 +1,285 @@
+package dtraderhq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// 客户端错误，可用 errors.Is 判断
+var (
+	ErrNotConnected       = errors.New("not connected")
+	ErrAlreadyConnected   = errors.New("already connected")
+	ErrNotAuthenticated   = errors.New("not authenticated")
+	ErrConnectionClosed   = errors.New("connection closed")
+	ErrBatchTooLarge      = fmt.Errorf("batch size exceeds limit (max %d)", MaxBatchSize)
+	ErrEmptyBatch         = errors.New("batch is empty")
+	ErrNotSubscribed      = errors.New("not subscribed")
+	ErrPongTimeout        = errors.New("pong timeout")
+	ErrNoLiveConnection   = errors.New("no live connection")
+	ErrNoEndpoints        = errors.New("no endpoints")
+	ErrAlreadyStarted     = errors.New("already started")
+	ErrEmptySymbolList    = errors.New("symbol list is empty")
+	ErrAllEndpointsFailed = errors.New("all endpoints failed")
+	ErrUnknownMessageType = errors.New("unknown message type")
+	ErrSchemaDrift        = errors.New("schema drift")
+	ErrAuthFailed         = errors.New("authentication failed")
+	ErrNoToken            = errors.New("no token")
+	ErrTokenExpiring      = errors.New("token expiring")
+	ErrTokenExpired       = errors.New("token expired")
+	ErrAckTimeout         = errors.New("no reply from server")
+)
+
+// maxRawPayload DecodeError 中保留的原始消息最大长度
+const maxRawPayload = 512
+
+// DecodeError 无法解析的消息
+type DecodeError struct {
+	MessageType string // 消息的 type 字段，消息本身无法解析时为空
+	Raw         []byte // 原始消息，超过 512 字节时截断
+	Size        int    // 原始消息的完整长度
+	Err         error  // JSON 解析错误或 ErrUnknownMessageType
+}
+
+// Error 实现 error 接口
+func (e *DecodeError) Error() string {
+	if e.MessageType != "" {
+		return fmt.Sprintf("decode %q message (%d bytes): %v: %s", e.MessageType, e.Size, e.Err, e.Raw)
+	}
+	return fmt.Sprintf("decode message (%d bytes): %v: %s", e.Size, e.Err, e.Raw)
+}
+
+// Unwrap 返回底层错误
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// truncatePayload 复制原始消息，超过 maxRawPayload 时截断
+func truncatePayload(raw []byte) []byte {
+	return append([]byte(nil), raw[:min(len(raw), maxRawPayload)]...)
+}
+
+// ServerError 服务器返回的错误消息
+type ServerError struct {
+	Message string         // error 字段
+	Code    string         // data.code，服务器未提供时为空
+	Context map[string]any // data 中的其他字段
+}
+
+// Error 实现 error 接口
+func (e *ServerError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("server error %s: %s", e.Code, e.Message)
+	}
+	return "server error: " + e.Message
+}
+
+// Retryable 是否为限流、服务器内部错误等可重试的错误，与 Kind 的分类一致
+func (e *ServerError) Retryable() bool {
+	switch e.Kind() {
+	case ErrorKindRateLimit, ErrorKindServer:
+		return true
+	}
+	return false
+}
+
+// newServerError 由错误消息构造 ServerError
+func newServerError(msg *Message) *ServerError {
+	e := &ServerError{Message: msg.Error}
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		e.Context = data
+		switch code := data["code"].(type) {
+		case string:
+			e.Code = code
+		case float64:
+			e.Code = strconv.FormatFloat(code, 'f', -1, 64)
+		}
+		if e.Message == "" {
+			e.Message, _ = data["message"].(string)
+		}
+	}
+	return e
+}
+
+// RequestError 服务器对某个请求返回的错误
+type RequestError struct {
+	Op         string   // 请求的消息类型，如 batch_subscribe
+	StockCodes []string // 请求涉及的股票
+	Err        *ServerError
+}
+
+// Error 实现 error 接口
+func (e *RequestError) Error() string {
+	codes := strings.Join(e.StockCodes, ",")
+	if len(e.StockCodes) > 5 {
+		codes = strings.Join(e.StockCodes[:5], ",") + fmt.Sprintf(",... (%d stocks)", len(e.StockCodes))
+	}
+	return fmt.Sprintf("%s [%s]: %v", e.Op, codes, e.Err)
+}
+
+// Unwrap 返回服务器错误
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKind 服务器错误的分类
+type ErrorKind string
+
+// 服务器错误分类
+const (
+	ErrorKindUnknown        ErrorKind = "unknown"
+	ErrorKindRateLimit      ErrorKind = "rate_limit"      // 请求过于频繁
+	ErrorKindAuth           ErrorKind = "auth"            // 未认证、token 无效或无权限
+	ErrorKindInvalidStock   ErrorKind = "invalid_stock"   // 股票代码不存在或不支持
+	ErrorKindInvalidRequest ErrorKind = "invalid_request" // 参数或消息格式错误
+	ErrorKindServer         ErrorKind = "server"          // 服务器内部错误或暂不可用
+)
+
+// Kind 按错误码和错误信息对服务器错误分类
+func (e *ServerError) Kind() ErrorKind {
+	code := strings.ToLower(e.Code)
+	if n, err := strconv.Atoi(code); err == nil {
+		switch {
+		case n == 429:
+			return ErrorKindRateLimit
+		case n == 401 || n == 403:
+			return ErrorKindAuth
+		case n == 404 || n == 400 || n == 422:
+			if mentionsStock(e.Message) {
+				return ErrorKindInvalidStock
+			}
+			return ErrorKindInvalidRequest
+		case n >= 500 && n < 600:
+			return ErrorKindServer
+		}
+	}
+
+	text := code + " " + strings.ToLower(e.Message)
+	switch {
+	case containsAny(text, "rate_limit", "rate limit", "too_many_requests", "too many requests", "限流", "频繁"):
+		return ErrorKindRateLimit
+	case containsAny(text, "unauthorized", "forbidden", "auth", "token", "认证", "权限"):
+		return ErrorKindAuth
+	case containsAny(text, "invalid_stock", "invalid_symbol", "stock_not_found", "unknown_stock") || mentionsStock(text):
+		return ErrorKindInvalidStock
+	case containsAny(text, "internal", "unavailable", "busy", "timeout", "服务器", "繁忙"):
+		return ErrorKindServer
+	case containsAny(text, "invalid", "bad_request", "bad request", "参数", "格式"):
+		return ErrorKindInvalidRequest
+	}
+	return ErrorKindUnknown
+}
+
+func mentionsStock(s string) bool {
+	return containsAny(strings.ToLower(s), "stock", "symbol", "股票")
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscriptionError 单只股票的订阅失败
+type SubscriptionError struct {
+	Op        string // subscribe、unsubscribe 或 reset
+	StockCode string
+	DataType  int   // 服务器未指明数据类型时为 0
+	Err       error // 通常为 *ServerError
+}
+
+// Error 实现 error 接口
+func (e *SubscriptionError) Error() string {
+	if e.DataType != 0 {
+		return fmt.Sprintf("%s %s/%d: %v", e.Op, e.StockCode, e.DataType, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Op, e.StockCode, e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *SubscriptionError) Unwrap() error {
+	return e.Err
+}
+
+// subscriptionErrors 从批量订阅结果的 error_list 中提取每只股票的失败原因
+func subscriptionErrors(op string, data interface{}) []error {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	list, ok := dataMap["error_list"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var errs []error
+	for _, item := range list {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		stockCode, _ := entry["stock_code"].(string)
+		message, _ := entry["error"].(string)
+		if message == "" {
+			message, _ = entry["message"].(string)
+		}
+
+		cause := &ServerError{Message: message, Context: entry}
+		if code, ok := entry["code"].(string); ok {
+			cause.Code = code
+		}
+
+		dataType := 0
+		if dt, ok := entry["data_type"].(float64); ok {
+			dataType = int(dt)
+		}
+		errs = append(errs, &SubscriptionError{Op: op, StockCode: stockCode, DataType: dataType, Err: cause})
+	}
+	return errs
+}
+
+// IsRetryable 判断错误是否为暂时性的，重连或稍后重试可能成功
+//
+// 连接断开、超时、pong 超时以及服务器的限流/内部错误属于可重试错误；
+// 参数错误（批量过大、列表为空）、未认证和其他服务器错误不可重试。
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.Retryable()
+	}
+
+	switch {
+	case errors.Is(err, ErrConnectionClosed),
+		errors.Is(err, ErrNotConnected),
+		errors.Is(err, ErrPongTimeout),
+		errors.Is(err, ErrNoLiveConnection),
+		errors.Is(err, ErrAllEndpointsFailed),
+		errors.Is(err, ErrAckTimeout),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, websocket.ErrCloseSent),
+		errors.Is(err, net.ErrClosed):
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return closeErr.Code != websocket.CloseNormalClosure && closeErr.Code != websocket.ClosePolicyViolation
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package dtraderhq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"connection closed", fmt.Errorf("subscribe: %w", ErrConnectionClosed), true},
+		{"not connected", ErrNotConnected, true},
+		{"pong timeout", ErrPongTimeout, true},
+		{"ack timeout", ErrAckTimeout, true},
+		{"all endpoints failed", ErrAllEndpointsFailed, true},
+		{"deadline", context.DeadlineExceeded, true},
+		{"net error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"abnormal close", &websocket.CloseError{Code: websocket.CloseAbnormalClosure}, true},
+		{"normal close", &websocket.CloseError{Code: websocket.CloseNormalClosure}, false},
+		{"policy violation", &websocket.CloseError{Code: websocket.ClosePolicyViolation}, false},
+		{"batch too large", ErrBatchTooLarge, false},
+		{"empty batch", ErrEmptyBatch, false},
+		{"not authenticated", ErrNotAuthenticated, false},
+		{"cancelled", context.Canceled, false},
+		{"other", io.ErrUnexpectedEOF, false},
+		{"rate limited", &ServerError{Code: "RATE_LIMITED"}, true},
+		{"http 503", &ServerError{Code: "503"}, true},
+		{"http 429 wrapped", &RequestError{Op: "subscribe", Err: &ServerError{Code: "429"}}, true},
+		{"invalid request", &ServerError{Code: "INVALID_REQUEST"}, false},
+		{"http 404", &ServerError{Code: "404"}, false},
+		{"rate limit message without code", &ServerError{Message: "请求过于频繁"}, true},
+		{"server busy without code", &ServerError{Message: "server busy"}, true},
+		{"invalid stock without code", &ServerError{Message: "无效的股票代码"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Fatalf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerErrorKind(t *testing.T) {
+	tests := []struct {
+		err  ServerError
+		want ErrorKind
+	}{
+		{ServerError{Code: "429"}, ErrorKindRateLimit},
+		{ServerError{Message: "请求过于频繁"}, ErrorKindRateLimit},
+		{ServerError{Code: "401"}, ErrorKindAuth},
+		{ServerError{Message: "invalid token"}, ErrorKindAuth},
+		{ServerError{Code: "404", Message: "stock not found"}, ErrorKindInvalidStock},
+		{ServerError{Code: "400", Message: "missing field"}, ErrorKindInvalidRequest},
+		{ServerError{Message: "无效的股票代码"}, ErrorKindInvalidStock},
+		{ServerError{Code: "500"}, ErrorKindServer},
+		{ServerError{Message: "服务器繁忙"}, ErrorKindServer},
+		{ServerError{Code: "INVALID_REQUEST"}, ErrorKindInvalidRequest},
+		{ServerError{Message: "something happened"}, ErrorKindUnknown},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Kind(); got != tt.want {
+			t.Errorf("%v: Kind() = %s, want %s", &tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNewServerError(t *testing.T) {
+	e := newServerError(&Message{Type: MessageTypeError, Data: map[string]any{"code": 429.0, "message": "slow down", "retry_after": 1.0}})
+	if e.Code != "429" || e.Message != "slow down" || e.Context["retry_after"] != 1.0 {
+		t.Fatalf("ServerError = %+v", e)
+	}
+	if e.Error() != "server error 429: slow down" {
+		t.Fatalf("Error() = %q", e.Error())
+	}
+	if got := newServerError(&Message{Error: "boom"}).Error(); got != "server error: boom" {
+		t.Fatalf("Error() = %q", got)
+	}
+}
+
+func TestRequestErrorMessage(t *testing.T) {
+	err := &RequestError{
+		Op:         MessageTypeBatchSubscribe,
+		StockCodes: []string{"SZ000001", "SZ000002", "SZ000003", "SZ000004", "SZ000005", "SZ000006"},
+		Err:        &ServerError{Message: "invalid"},
+	}
+	want := "batch_subscribe [SZ000001,SZ000002,SZ000003,SZ000004,SZ000005,... (6 stocks)]: server error: invalid"
+	if err.Error() != want {
+		t.Fatalf("Error() = %q", err.Error())
+	}
+	var serverErr *ServerError
+	if !errors.As(err, &serverErr) || serverErr.Message != "invalid" {
+		t.Fatal("RequestError does not unwrap to ServerError")
+	}
+}
+
+func TestSubscriptionErrors(t *testing.T) {
+	errs := subscriptionErrors("subscribe", map[string]any{
+		"success_count": 1.0,
+		"error_list": []any{
+			map[string]any{"stock_code": "SZ999999", "error": "stock not found", "code": "NOT_FOUND"},
+			map[string]any{"stock_code": "SZ000001", "message": "type closed", "data_type": 5.0},
+			"ignored",
+		},
+	})
+	if len(errs) != 2 {
+		t.Fatalf("errors = %v", errs)
+	}
+	var subErr *SubscriptionError
+	if !errors.As(errs[0], &subErr) || subErr.StockCode != "SZ999999" || subErr.Err.(*ServerError).Code != "NOT_FOUND" {
+		t.Fatalf("first error = %v", errs[0])
+	}
+	if got := errs[1].Error(); got != "subscribe SZ000001/5: server error: type closed" {
+		t.Fatalf("second error = %q", got)
+	}
+	if subscriptionErrors("subscribe", map[string]any{"success_count": 2.0}) != nil {
+		t.Fatal("errors reported without error_list")
+	}
+	if got := subscriptionErrors("subscribe", "ok"); !reflect.DeepEqual(got, []error(nil)) {
+		t.Fatalf("errors = %v", got)
+	}
+}
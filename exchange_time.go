@@ -0,0 +1,158 @@
+package dtraderhq
+
+import "time"
+
+// ShanghaiLocation 交易所所在时区（Asia/Shanghai），系统缺少时区数据库时回退为固定的 UTC+8
+var ShanghaiLocation = loadShanghaiLocation()
+
+func loadShanghaiLocation() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Shanghai"); err == nil {
+		return loc
+	}
+	return time.FixedZone("CST", 8*3600)
+}
+
+// TradingDateSource 交易日来源，返回参考时刻所属交易日（当地零点）
+type TradingDateSource interface {
+	TradingDate(ref time.Time) time.Time
+}
+
+// CalendarDate 以参考时刻在交易所时区的自然日作为交易日
+type CalendarDate struct{}
+
+// TradingDate 返回 ref 在交易所时区的当日零点
+func (CalendarDate) TradingDate(ref time.Time) time.Time {
+	return startOfDay(ref.In(ShanghaiLocation))
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// ExchangeClock 将服务器下发的各类时间字段转换为交易所时区的 time.Time
+//
+//   - 数据帧 Timestamp、逐笔成交 Time：Unix 秒
+//   - 逐笔委托 DateTime：深市 HHMMSSmmm、沪市 HHMMSScc，不含日期，日期由 Dates 根据参考时刻确定
+type ExchangeClock struct {
+	Location *time.Location
+	Dates    TradingDateSource
+}
+
+// NewExchangeClock 创建使用 Asia/Shanghai 时区和自然日的时钟
+func NewExchangeClock() *ExchangeClock {
+	return &ExchangeClock{Location: ShanghaiLocation, Dates: CalendarDate{}}
+}
+
+func (c *ExchangeClock) location() *time.Location {
+	if c == nil || c.Location == nil {
+		return ShanghaiLocation
+	}
+	return c.Location
+}
+
+func (c *ExchangeClock) dates() TradingDateSource {
+	if c == nil || c.Dates == nil {
+		return CalendarDate{}
+	}
+	return c.Dates
+}
+
+// FrameTime 数据帧时间戳（服务器发送时间，秒级）
+func (c *ExchangeClock) FrameTime(md *MarketData) time.Time {
+	return time.Unix(md.Timestamp, 0).In(c.location())
+}
+
+// TransactionTime 逐笔成交时间（秒级）
+func (c *ExchangeClock) TransactionTime(t Transaction) time.Time {
+	return time.Unix(t.Time, 0).In(c.location())
+}
+
+// ZBWTTime 逐笔委托时间，时间格式按 stockCode 所属市场确定（见 MarketClockFormat），
+// ref 为用于确定交易日的参考时刻（通常是数据帧时间戳或接收时间）
+//
+// 委托时刻与参考时刻相差超过12小时时视为跨越零点，自动调整到前一天或后一天。
+func (c *ExchangeClock) ZBWTTime(stockCode string, z ZBWT, ref time.Time) (time.Time, error) {
+	return c.ClockTime(z.DateTime, MarketClockFormat(stockCode), ref)
+}
+
+// ClockTime 将逐笔委托格式的时刻（见 ParseClock）转换为完整时间，ClockAuto 时以 ref 为参考判断格式
+func (c *ExchangeClock) ClockTime(v int64, format ClockFormat, ref time.Time) (time.Time, error) {
+	loc := c.location()
+	ref = ref.In(loc)
+	day := c.dates().TradingDate(ref).In(loc)
+
+	ms, err := ParseClock(v, format, ref.Sub(startOfDay(ref)).Milliseconds())
+	if err != nil {
+		return time.Time{}, err
+	}
+	t := time.Date(day.Year(), day.Month(), day.Day(),
+		int(ms/3600000), int(ms/60000%60), int(ms/1000%60), int(ms%1000)*int(time.Millisecond), loc)
+
+	// 处理跨零点：例如 23:59:59.900 的委托在次日 00:00:00 收到
+	switch diff := t.Sub(ref); {
+	case diff > 12*time.Hour:
+		t = t.AddDate(0, 0, -1)
+	case diff < -12*time.Hour:
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// FrameTiming 一帧数据的时间信息，用于延迟分析
+type FrameTiming struct {
+	ReceivedAt   time.Time // 客户端接收时间
+	FrameTime    time.Time // 数据帧时间戳
+	ExchangeTime time.Time // 帧内最新一条记录的交易所时间，逐笔大单等无时间字段的数据为零值
+}
+
+// Latency 交易所时间到接收时间的延迟，无法计算时返回0
+func (ft FrameTiming) Latency() time.Duration {
+	if ft.ExchangeTime.IsZero() || ft.ReceivedAt.IsZero() {
+		return 0
+	}
+	return ft.ReceivedAt.Sub(ft.ExchangeTime)
+}
+
+// Timing 计算一帧数据的接收时间、帧时间和交易所时间
+func (c *ExchangeClock) Timing(md *MarketData) (FrameTiming, error) {
+	loc := c.location()
+	ft := FrameTiming{FrameTime: c.FrameTime(md)}
+	if !md.ReceivedAt.IsZero() {
+		ft.ReceivedAt = md.ReceivedAt.In(loc)
+	}
+
+	ref := ft.ReceivedAt
+	if ref.IsZero() {
+		ref = ft.FrameTime
+	}
+
+	switch md.DataType {
+	case DataTypeTransaction:
+		trades, err := DecodeTransactions(md)
+		if err != nil {
+			return ft, err
+		}
+		for _, t := range trades {
+			if tt := c.TransactionTime(t); tt.After(ft.ExchangeTime) {
+				ft.ExchangeTime = tt
+			}
+		}
+	case DataTypeZBWT:
+		orders, err := DecodeZBWT(md)
+		if err != nil {
+			return ft, err
+		}
+		for _, z := range orders {
+			tt, err := c.ZBWTTime(md.StockCode, z, ref)
+			if err != nil {
+				return ft, err
+			}
+			if tt.After(ft.ExchangeTime) {
+				ft.ExchangeTime = tt
+			}
+		}
+	}
+
+	return ft, nil
+}
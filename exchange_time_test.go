@@ -0,0 +1,95 @@
+package dtraderhq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockTime(t *testing.T) {
+	clock := NewExchangeClock()
+	at := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04:05.000", s, ShanghaiLocation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	tests := []struct {
+		name   string
+		v      int64
+		format ClockFormat
+		ref    string
+		want   string
+	}{
+		{"SZ", 111257470, ClockMillis, "2025-06-27 11:12:58.000", "2025-06-27 11:12:57.470"},
+		{"SH", 11125987, ClockCentis, "2025-06-27 11:13:01.000", "2025-06-27 11:12:59.870"},
+		{"SH before 10:00", 9300050, ClockCentis, "2025-06-27 09:30:01.000", "2025-06-27 09:30:00.500"},
+		{"auto SH", 11125987, ClockAuto, "2025-06-27 11:13:01.000", "2025-06-27 11:12:59.870"},
+		{"auto SZ before 10:00", 93000120, ClockAuto, "2025-06-27 09:30:01.000", "2025-06-27 09:30:00.120"},
+		{"previous day", 235959900, ClockMillis, "2025-06-28 00:00:00.300", "2025-06-27 23:59:59.900"},
+		{"next day", 100, ClockMillis, "2025-06-27 23:59:59.950", "2025-06-28 00:00:00.100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := clock.ClockTime(tt.v, tt.format, at(tt.ref))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := at(tt.want); !got.Equal(want) {
+				t.Fatalf("ClockTime(%d) = %s, want %s", tt.v, got, want)
+			}
+		})
+	}
+
+	// 沪市 14:59:59.99 按深市格式解析时秒数为 95，应报错而不是给出错误的时刻
+	if _, err := clock.ClockTime(14595999, ClockMillis, at("2025-06-27 15:00:01.000")); err == nil {
+		t.Fatal("HHMMSScc value accepted as HHMMSSmmm")
+	}
+}
+
+func TestZBWTTimeSamples(t *testing.T) {
+	clock := NewExchangeClock()
+	for _, file := range []string{"SH603065_order_20250627.json", "SZ002062_order_20250627.json"} {
+		t.Run(file, func(t *testing.T) {
+			for _, md := range loadSample(t, file) {
+				ft := clock.FrameTime(md)
+				timing, err := clock.Timing(md)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// 帧内最新一条委托应在帧时间戳前后几秒内
+				if d := ft.Sub(timing.ExchangeTime); d < -2*time.Second || d > 2*time.Minute {
+					t.Fatalf("%s frame at %s, exchange time %s", md.StockCode, ft, timing.ExchangeTime)
+				}
+			}
+		})
+	}
+}
+
+func TestTimingLatency(t *testing.T) {
+	clock := NewExchangeClock()
+	md := &MarketData{
+		StockCode:  "SH603065",
+		DataType:   DataTypeZBWT,
+		Timestamp:  frameAt,
+		Data:       []ZBWT{{DateTime: 11125987}, {DateTime: 11130010}},
+		ReceivedAt: time.Unix(frameAt, 500*int64(time.Millisecond)),
+	}
+	timing, err := clock.Timing(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := timing.Latency(); got != 1400*time.Millisecond {
+		t.Fatalf("latency = %s, want 1.4s", got)
+	}
+
+	trades := &MarketData{DataType: DataTypeTransaction, Timestamp: frameAt, Data: []Transaction{{Time: frameAt - 1}}}
+	timing, err = clock.Timing(trades)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timing.Latency() != 0 || !timing.ExchangeTime.Equal(time.Unix(frameAt-1, 0)) {
+		t.Fatalf("timing = %+v", timing)
+	}
+}
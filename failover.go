@@ -0,0 +1,409 @@
+package dtraderhq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SwitchEvent 服务器切换事件
+type SwitchEvent struct {
+	From   string // 切换前的地址，首次连接时为空
+	To     string
+	Reason string
+	At     time.Time
+	Err    error // 切换失败时非空，此时 To 为空
+}
+
+// EndpointHealth 单个服务器的健康状态
+type EndpointHealth struct {
+	URL            string
+	Active         bool
+	ConnectLatency time.Duration // 最近一次建立连接的耗时
+	RTT            time.Duration // 最近一次 ping/pong 往返时间，仅当前服务器
+	LastData       time.Time     // 最近一次收到数据的时间，仅当前服务器
+	Failures       int           // 连续健康检查失败次数
+	LastError      error
+	CheckedAt      time.Time
+}
+
+// FailoverClient 按优先级使用多个服务器地址的客户端
+//
+// 健康检查发现连接断开、ping 超时或往返时间过长、交易时段内数据中断时，
+// 按优先级切换到其他服务器并恢复订阅。内嵌的 Client 在切换前后保持不变，
+// DataChannel、订阅等接口可以直接使用。
+type FailoverClient struct {
+	*Client
+
+	// 健康检查参数，零值使用默认值
+	CheckInterval  time.Duration // 检查间隔，默认 5s
+	MaxRTT         time.Duration // ping 超时和可接受的最大往返时间，默认 3s
+	MaxFailures    int           // 连续失败多少次后切换，默认 2
+	ConnectTimeout time.Duration // 连接并认证的超时，默认 10s
+	// MaxSilence 已订阅时允许的最长无数据时间，0 表示不检查；
+	// 设置 Calendar 时只在交易时段内检查
+	MaxSilence time.Duration
+	Calendar   *Calendar
+	Market     Market
+	// ProbeInterval 使用备用服务器时探测更高优先级服务器的间隔，0 表示不切回
+	ProbeInterval time.Duration
+	// Keepalive 按服务器地址指定心跳方式，未指定的服务器使用创建时的 WithKeepalive 选项
+	Keepalive map[string]KeepaliveMode
+
+	endpoints []string
+	keepalive KeepaliveMode // 默认心跳方式
+	events    chan SwitchEvent
+	token     string
+
+	mu          sync.Mutex
+	active      int
+	health      []EndpointHealth
+	connectedAt time.Time
+	lastProbe   time.Time
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// NewFailoverClient 创建多服务器客户端，endpoints 按优先级排列
+func NewFailoverClient(endpoints []string, opts ...Option) *FailoverClient {
+	f := &FailoverClient{
+		endpoints: append([]string(nil), endpoints...),
+		events:    make(chan SwitchEvent, 16),
+		active:    -1,
+		health:    make([]EndpointHealth, len(endpoints)),
+	}
+	for i, endpoint := range endpoints {
+		f.health[i].URL = endpoint
+	}
+	first := ""
+	if len(endpoints) > 0 {
+		first = endpoints[0]
+	}
+	f.Client = NewClient(first, opts...)
+	f.keepalive = f.Client.keepalive
+	return f
+}
+
+// Events 返回切换事件通道，未及时读取时丢弃较新的事件
+func (f *FailoverClient) Events() <-chan SwitchEvent {
+	return f.events
+}
+
+// Active 返回当前使用的服务器地址，未连接时为空
+func (f *FailoverClient) Active() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.active < 0 {
+		return ""
+	}
+	return f.endpoints[f.active]
+}
+
+// Health 返回各服务器的健康状态
+func (f *FailoverClient) Health() []EndpointHealth {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result := append([]EndpointHealth(nil), f.health...)
+	if f.active >= 0 {
+		result[f.active].RTT = f.Client.LastRTT()
+		result[f.active].LastData = f.Client.LastDataAt()
+	}
+	return result
+}
+
+// Start 按优先级连接第一个可用的服务器并认证，之后在后台进行健康检查，直到 ctx 结束或 Close
+func (f *FailoverClient) Start(ctx context.Context, token string) error {
+	if len(f.endpoints) == 0 {
+		return ErrNoEndpoints
+	}
+
+	f.mu.Lock()
+	if f.cancel != nil {
+		f.mu.Unlock()
+		return ErrAlreadyStarted
+	}
+	f.token = token
+	ctx, f.cancel = context.WithCancel(ctx)
+	f.mu.Unlock()
+
+	if err := f.failover(ctx, -1, "initial connect"); err != nil {
+		f.Close()
+		return err
+	}
+
+	f.wg.Add(1)
+	go f.healthLoop(ctx)
+	return nil
+}
+
+// Close 停止健康检查并关闭连接
+func (f *FailoverClient) Close() error {
+	f.mu.Lock()
+	cancel := f.cancel
+	f.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	f.wg.Wait()
+	return f.Client.Close()
+}
+
+func (f *FailoverClient) durationOr(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// healthLoop 定期检查当前服务器，不健康时切换
+func (f *FailoverClient) healthLoop(ctx context.Context) {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(f.durationOr(f.CheckInterval, 5*time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.Client.Done():
+			if ctx.Err() != nil {
+				return
+			}
+			f.switchFrom(ctx, "connection lost")
+		case <-ticker.C:
+			if reason := f.check(ctx); reason != "" {
+				f.switchFrom(ctx, reason)
+			} else {
+				f.probe(ctx)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// switchFrom 切换到其他服务器，全部失败时按检查间隔重试
+func (f *FailoverClient) switchFrom(ctx context.Context, reason string) {
+	f.mu.Lock()
+	from := f.active
+	f.mu.Unlock()
+
+	for ctx.Err() == nil {
+		if err := f.failover(ctx, from, reason); err == nil {
+			return
+		}
+		select {
+		case <-time.After(f.durationOr(f.CheckInterval, 5*time.Second)):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// check 检查当前服务器，需要切换时返回原因
+func (f *FailoverClient) check(ctx context.Context) string {
+	maxRTT := f.durationOr(f.MaxRTT, 3*time.Second)
+
+	pingCtx, cancel := context.WithTimeout(ctx, maxRTT)
+	rtt, err := f.Client.Ping(pingCtx)
+	cancel()
+	if err == nil && rtt > maxRTT {
+		err = fmt.Errorf("ping round trip %s exceeds %s", rtt, maxRTT)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.active < 0 {
+		return ""
+	}
+	h := &f.health[f.active]
+	h.CheckedAt = time.Now()
+
+	if err != nil {
+		h.Failures++
+		h.LastError = err
+		maxFailures := f.MaxFailures
+		if maxFailures <= 0 {
+			maxFailures = 2
+		}
+		if h.Failures >= maxFailures {
+			return fmt.Sprintf("ping failed %d times: %v", h.Failures, err)
+		}
+		return ""
+	}
+	h.Failures = 0
+	h.LastError = nil
+
+	if reason := f.staleLocked(); reason != "" {
+		h.LastError = errors.New(reason)
+		return reason
+	}
+	return ""
+}
+
+// staleLocked 检查数据是否中断
+func (f *FailoverClient) staleLocked() string {
+	if f.MaxSilence <= 0 || len(f.Client.GetSubscriptions()) == 0 {
+		return ""
+	}
+	now := time.Now()
+	if f.Calendar != nil && !f.Calendar.PhaseAt(f.Market, now).IsTrading() {
+		return ""
+	}
+
+	// 切换后尚未收到数据时从连接建立时算起
+	last := f.Client.LastDataAt()
+	if last.Before(f.connectedAt) {
+		last = f.connectedAt
+	}
+	if silence := now.Sub(last); silence > f.MaxSilence {
+		return fmt.Sprintf("no data for %s", silence.Truncate(time.Second))
+	}
+	return ""
+}
+
+// probe 使用备用服务器时，按 ProbeInterval 探测更高优先级的服务器，可用时切回
+func (f *FailoverClient) probe(ctx context.Context) {
+	f.mu.Lock()
+	active := f.active
+	due := f.ProbeInterval > 0 && active > 0 && time.Since(f.lastProbe) >= f.ProbeInterval
+	if due {
+		f.lastProbe = time.Now()
+	}
+	f.mu.Unlock()
+	if !due {
+		return
+	}
+
+	dialer := websocket.Dialer{HandshakeTimeout: f.durationOr(f.ConnectTimeout, 10*time.Second)}
+	for i := 0; i < active; i++ {
+		start := time.Now()
+		conn, _, err := dialer.DialContext(ctx, f.endpoints[i], nil)
+		latency := time.Since(start)
+
+		f.mu.Lock()
+		h := &f.health[i]
+		h.CheckedAt = time.Now()
+		if err != nil {
+			h.Failures++
+			h.LastError = err
+		} else {
+			h.Failures = 0
+			h.LastError = nil
+			h.ConnectLatency = latency
+		}
+		f.mu.Unlock()
+
+		if err == nil {
+			conn.Close()
+			f.switchFrom(ctx, fmt.Sprintf("higher priority endpoint %s available", f.endpoints[i]))
+			return
+		}
+	}
+}
+
+// failover 按优先级尝试除 from 以外的服务器，最后再尝试 from，成功时发出切换事件
+func (f *FailoverClient) failover(ctx context.Context, from int, reason string) error {
+	order := make([]int, 0, len(f.endpoints))
+	for i := range f.endpoints {
+		if i != from {
+			order = append(order, i)
+		}
+	}
+	if from >= 0 {
+		order = append(order, from)
+	}
+
+	fromURL := ""
+	if from >= 0 {
+		fromURL = f.endpoints[from]
+	}
+
+	var errs []error
+	for _, i := range order {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := f.connectTo(ctx, i); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.endpoints[i], err))
+			continue
+		}
+		f.Client.log.Info("endpoint switched",
+			slog.String("from", fromURL),
+			slog.String("to", f.endpoints[i]),
+			slog.String("reason", reason))
+		f.emit(SwitchEvent{From: fromURL, To: f.endpoints[i], Reason: reason, At: time.Now()})
+		return nil
+	}
+
+	err := fmt.Errorf("%w: %w", ErrAllEndpointsFailed, errors.Join(errs...))
+	f.Client.log.Warn("endpoint switch failed",
+		slog.String("from", fromURL),
+		slog.String("reason", reason),
+		slog.Any("error", err))
+	f.emit(SwitchEvent{From: fromURL, Reason: reason, At: time.Now(), Err: err})
+	return err
+}
+
+// connectTo 连接到第 i 个服务器，认证并恢复订阅
+func (f *FailoverClient) connectTo(ctx context.Context, i int) error {
+	mode, ok := f.Keepalive[f.endpoints[i]]
+	if !ok {
+		mode = f.keepalive
+	}
+	f.Client.Close()
+	f.Client.setURL(f.endpoints[i], mode)
+
+	start := time.Now()
+	err := f.Client.Connect()
+	latency := time.Since(start)
+
+	if err == nil {
+		err = f.Client.Authenticate(f.token)
+	}
+	if err == nil {
+		authCtx, cancel := context.WithTimeout(ctx, f.durationOr(f.ConnectTimeout, 10*time.Second))
+		err = f.Client.WaitAuthenticated(authCtx)
+		cancel()
+	}
+	if err == nil {
+		err = f.Client.Resubscribe()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h := &f.health[i]
+	h.CheckedAt = time.Now()
+	if err != nil {
+		f.Client.Close()
+		h.Failures++
+		h.LastError = err
+		if f.active == i {
+			f.active = -1
+		}
+		return err
+	}
+
+	for k := range f.health {
+		f.health[k].Active = k == i
+	}
+	h.ConnectLatency = latency
+	h.Failures = 0
+	h.LastError = nil
+	f.active = i
+	f.connectedAt = time.Now()
+	return nil
+}
+
+// emit 发出切换事件，通道已满时丢弃
+func (f *FailoverClient) emit(ev SwitchEvent) {
+	select {
+	case f.events <- ev:
+	default:
+	}
+}
@@ -0,0 +1,123 @@
+package dtraderhq
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func nextSwitch(t *testing.T, f *FailoverClient) SwitchEvent {
+	t.Helper()
+	select {
+	case ev := <-f.Events():
+		return ev
+	case <-time.After(3 * time.Second):
+		t.Fatal("no switch event")
+		return SwitchEvent{}
+	}
+}
+
+// 当前服务器断开时切换到备用服务器并恢复订阅
+func TestFailoverOnConnectionLoss(t *testing.T) {
+	primary, backup := newTestServer(t), newTestServer(t)
+	f := NewFailoverClient([]string{primary.url(), backup.url()})
+	if err := f.Start(context.Background(), "token"); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if ev := nextSwitch(t, f); ev.From != "" || ev.To != primary.url() || ev.Err != nil {
+		t.Fatalf("initial event = %+v", ev)
+	}
+	if err := f.Subscribe("SZ000001", []int{4}); err != nil {
+		t.Fatal(err)
+	}
+
+	primary.dropConnections()
+	ev := nextSwitch(t, f)
+	if ev.From != primary.url() || ev.To != backup.url() || ev.Reason != "connection lost" {
+		t.Fatalf("switch event = %+v", ev)
+	}
+	if f.Active() != backup.url() {
+		t.Fatalf("Active() = %s", f.Active())
+	}
+	waitFor(t, "resubscribe on backup", func() bool { return len(backup.messages(MessageTypeBatchSubscribe)) == 1 })
+	if codes := requestCodes(backup.messages(MessageTypeBatchSubscribe)[0]); len(codes) != 1 || codes[0] != "SZ000001" {
+		t.Fatalf("restored %v", codes)
+	}
+	health := f.Health()
+	if health[0].Active || !health[1].Active {
+		t.Fatalf("health = %+v", health)
+	}
+}
+
+// 连续 ping 超时达到 MaxFailures 时切换
+func TestFailoverOnPingTimeout(t *testing.T) {
+	primary, backup := newTestServer(t), newTestServer(t)
+	primary.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		return msg["type"] == MessageTypePing
+	})
+	f := NewFailoverClient([]string{primary.url(), backup.url()})
+	f.CheckInterval = 20 * time.Millisecond
+	f.MaxRTT = 50 * time.Millisecond
+	f.MaxFailures = 2
+	if err := f.Start(context.Background(), "token"); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	nextSwitch(t, f)
+
+	ev := nextSwitch(t, f)
+	if ev.To != backup.url() || !strings.Contains(ev.Reason, "ping failed 2 times") {
+		t.Fatalf("switch event = %+v", ev)
+	}
+	if h := f.Health()[0]; h.Failures < 2 || !errors.Is(h.LastError, context.DeadlineExceeded) {
+		t.Fatalf("primary health = %+v", h)
+	}
+}
+
+// 主服务器不可用时使用备用服务器，恢复后经探测切回
+func TestFailoverProbesHigherPriority(t *testing.T) {
+	primary, backup := newTestServer(t), newTestServer(t)
+	primary.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		return msg["type"] == MessageTypeAuth // 不回复认证
+	})
+	f := NewFailoverClient([]string{primary.url(), backup.url()})
+	f.ConnectTimeout = 100 * time.Millisecond
+	f.CheckInterval = 20 * time.Millisecond
+	f.ProbeInterval = 20 * time.Millisecond
+	if err := f.Start(context.Background(), "token"); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if ev := nextSwitch(t, f); ev.To != backup.url() {
+		t.Fatalf("initial event = %+v", ev)
+	}
+
+	primary.setHandler(nil)
+	ev := nextSwitch(t, f)
+	if ev.From != backup.url() || ev.To != primary.url() || !strings.Contains(ev.Reason, "higher priority") {
+		t.Fatalf("switch event = %+v", ev)
+	}
+}
+
+func TestFailoverAllEndpointsFailed(t *testing.T) {
+	srv := newTestServer(t)
+	srv.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		return msg["type"] == MessageTypeAuth
+	})
+	f := NewFailoverClient([]string{srv.url()})
+	f.ConnectTimeout = 50 * time.Millisecond
+	if err := f.Start(context.Background(), "token"); !errors.Is(err, ErrAllEndpointsFailed) {
+		t.Fatalf("Start = %v", err)
+	}
+	if ev := nextSwitch(t, f); ev.Err == nil || ev.To != "" {
+		t.Fatalf("event = %+v", ev)
+	}
+	if err := NewFailoverClient(nil).Start(context.Background(), "token"); !errors.Is(err, ErrNoEndpoints) {
+		t.Fatalf("Start without endpoints = %v", err)
+	}
+}
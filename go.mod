@@ -2,6 +2,10 @@ module github.com/DTrader-store/level2-client-go
 
 go 1.21
 
-require github.com/gorilla/websocket v1.5.1
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/gorilla/websocket v1.5.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require golang.org/x/net v0.17.0 // indirect
@@ -0,0 +1,6 @@
+// Package parquetcheck 用独立的 Parquet 实现读回 dtraderhq-convert 的输出，与同时输出的 CSV 逐值比较。
+//
+// 校验依赖 parquet-go，单独作为一个模块，库和命令的使用者不会因此引入该依赖：
+//
+//	cd internal/parquetcheck && go test ./...
+package parquetcheck
@@ -0,0 +1,16 @@
+module github.com/DTrader-store/level2-client-go/internal/parquetcheck
+
+go 1.21
+
+require github.com/parquet-go/parquet-go v0.20.0
+
+require (
+	github.com/andybalholm/brotli v1.0.5 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/klauspost/compress v1.16.7 // indirect
+	github.com/mattn/go-runewidth v0.0.9 // indirect
+	github.com/olekukonko/tablewriter v0.0.5 // indirect
+	github.com/pierrec/lz4/v4 v4.1.18 // indirect
+	github.com/segmentio/encoding v0.3.6 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+)
@@ -0,0 +1,149 @@
+package parquetcheck
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const repoRoot = "../.."
+
+var shanghai = func() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Shanghai"); err == nil {
+		return loc
+	}
+	return time.FixedZone("CST", 8*3600)
+}()
+
+func TestParquetMatchesCSV(t *testing.T) {
+	names := []string{
+		"SZ002240_transaction_20250627",
+		"SZ002240_detail_20250627",
+		"SH603065_order_20250627",
+		"SZ002240_order_20250627",
+	}
+	in, out := t.TempDir(), t.TempDir()
+	for _, name := range names {
+		b, err := os.ReadFile(filepath.Join(repoRoot, "examples/stock_collector/stock_data", name+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(in, name+".json"), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", "./cmd/dtraderhq-convert", "-in", in, "-out", out, "-format", "csv,parquet")
+	cmd.Dir = repoRoot
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("dtraderhq-convert: %v\n%s", err, output)
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			compare(t, filepath.Join(out, name+".csv"), filepath.Join(out, name+".parquet"))
+		})
+	}
+}
+
+// compare 逐行逐列比较 Parquet 文件与 CSV 文件，并校验列的类型
+func compare(t *testing.T, csvPath, parquetPath string) {
+	f, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(f).ReadAll()
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, records := records[0], records[1:]
+	if len(records) == 0 {
+		t.Fatal("no rows")
+	}
+
+	pf, err := os.Open(parquetPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pf.Close()
+	stat, err := pf.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parquet.OpenFile(pf, stat.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.NumRows() != int64(len(records)) {
+		t.Fatalf("NumRows = %d, want %d", file.NumRows(), len(records))
+	}
+
+	fields := file.Schema().Fields()
+	if len(fields) != len(header) {
+		t.Fatalf("schema has %d fields, want %d", len(fields), len(header))
+	}
+	for i, field := range fields {
+		if field.Name() != header[i] || !field.Required() {
+			t.Fatalf("field %d = %s, want required %s", i, field.Name(), header[i])
+		}
+		if field.Type().Kind() == parquet.ByteArray {
+			if lt := field.Type().LogicalType(); lt == nil || lt.UTF8 == nil {
+				t.Fatalf("field %s logical type = %v, want STRING", field.Name(), lt)
+			}
+		}
+	}
+
+	r := parquet.NewReader(file)
+	defer r.Close()
+	rows := make([]parquet.Row, 64)
+	row := 0
+	for {
+		n, err := r.ReadRows(rows)
+		for _, values := range rows[:n] {
+			for i, field := range fields {
+				if got := formatValue(field, values[i]); got != records[row][i] {
+					t.Fatalf("row %d column %s = %s, want %s", row, field.Name(), got, records[row][i])
+				}
+			}
+			row++
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if row != len(records) {
+		t.Fatalf("read %d rows, want %d", row, len(records))
+	}
+}
+
+// formatValue 按 CSV 的格式输出 Parquet 值：时间为交易所时区的本地时间，价格保留两位小数
+func formatValue(field parquet.Field, v parquet.Value) string {
+	if lt := field.Type().LogicalType(); lt != nil && lt.Timestamp != nil {
+		if lt.Timestamp.Unit.Millis == nil {
+			return fmt.Sprintf("timestamp unit %v", lt.Timestamp.Unit)
+		}
+		return time.UnixMilli(v.Int64()).In(shanghai).Format("2006-01-02 15:04:05.000")
+	}
+	switch field.Type().Kind() {
+	case parquet.ByteArray:
+		return string(v.ByteArray())
+	case parquet.Int32:
+		return fmt.Sprint(v.Int32())
+	case parquet.Double:
+		return fmt.Sprintf("%.2f", v.Double())
+	default:
+		return fmt.Sprint(v.Int64())
+	}
+}
@@ -0,0 +1,74 @@
+package dtraderhq
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// discardHandler 丢弃全部日志，未设置 WithLogger 时使用
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// WithLogger 设置结构化日志，记录连接、认证、订阅应答、丢帧和解析失败等事件
+//
+// 日志中的 token 始终以脱敏形式输出。未设置时不输出日志。
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		if logger != nil {
+			c.log = logger
+		}
+	}
+}
+
+// redactToken 返回脱敏后的 token，空 token 保持为空以便区分
+func redactToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+// LogValue 实现 slog.LogValuer，记录认证消息时不输出 token
+func (m AuthMessage) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("token", redactToken(m.Token)))
+}
+
+// logger 返回带有当前服务器地址的日志
+func (c *Client) logger() *slog.Logger {
+	return c.log.With(slog.String("endpoint", c.URL()))
+}
+
+// loggerLocked 同 logger，调用方需持有锁
+func (c *Client) loggerLocked() *slog.Logger {
+	return c.log.With(slog.String("endpoint", c.url))
+}
+
+// logSubscriptionReply 记录订阅应答的成功/失败数量和逐只股票的失败原因
+func (c *Client) logSubscriptionReply(op string, msg *Message, errs []error) {
+	logger := c.logger()
+	attrs := []any{slog.String("op", op), slog.String("type", msg.Type)}
+	if dataMap, ok := msg.Data.(map[string]interface{}); ok {
+		for _, key := range []string{"success_count", "error_count", "cancelled_count"} {
+			if n, ok := dataMap[key].(float64); ok {
+				attrs = append(attrs, slog.Int(key, int(n)))
+			}
+		}
+	}
+	logger.Debug("subscription reply", attrs...)
+
+	for _, err := range errs {
+		var subErr *SubscriptionError
+		if errors.As(err, &subErr) {
+			logger.Warn("subscription failed",
+				slog.String("op", subErr.Op),
+				slog.String("stock", subErr.StockCode),
+				slog.Int("data_type", subErr.DataType),
+				slog.Any("error", subErr.Err))
+		}
+	}
+}
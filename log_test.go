@@ -0,0 +1,82 @@
+package dtraderhq
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// syncBuffer 可并发写入的日志缓冲
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAuthMessageLogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	logger.Info("auth", slog.Any("auth", AuthMessage{Token: "secret-token"}), slog.Any("empty", AuthMessage{}))
+	if got := buf.String(); strings.Contains(got, "secret-token") || !strings.Contains(got, "auth.token=[REDACTED]") || !strings.Contains(got, `empty.token=""`) {
+		t.Fatalf("log = %s", got)
+	}
+}
+
+func TestClientLogging(t *testing.T) {
+	srv := newTestServer(t)
+	srv.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		if msg["type"] != MessageTypeBatchSubscribe {
+			return false
+		}
+		srv.write(conn, map[string]any{"type": MessageTypeBatchSubscribe, "data": map[string]any{
+			"success_count": 1,
+			"error_count":   1,
+			"error_list":    []any{map[string]any{"stock_code": "SZ999999", "error": "stock not found"}},
+		}})
+		return true
+	})
+
+	var buf syncBuffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := connectTestClient(t, srv, WithLogger(logger))
+	if err := c.BatchSubscribe([]SubscribeMessage{
+		{StockCode: "SZ000001", DataTypes: []int{4}},
+		{StockCode: "SZ999999", DataTypes: []int{4}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "subscription failure log", func() bool { return strings.Contains(buf.String(), `"msg":"subscription failed"`) })
+	c.Close()
+
+	out := buf.String()
+	if strings.Contains(out, `"token":"token"`) {
+		t.Fatalf("token leaked: %s", out)
+	}
+	for _, want := range []string{
+		`"msg":"connected"`,
+		`"auth":{"token":"[REDACTED]"}`,
+		`"msg":"authenticated"`,
+		`"endpoint":"` + srv.url() + `"`,
+		`"stock":"SZ999999"`,
+		`"msg":"connection closed"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log missing %s", want)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package dtraderhq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets 端到端延迟直方图的上界（秒）
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// frameKey 按股票和数据类型统计的帧数
+type frameKey struct {
+	stockCode string
+	dataType  int
+}
+
+// histogram 累积分布的直方图，counts[i] 为不超过 latencyBuckets[i] 的样本数
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// Metrics 客户端运行指标，可按 Prometheus 文本格式导出
+//
+// 通过 WithMetrics 接入一个或多个客户端（如 Pool 中的各个连接），计数器在各客户端间累加，
+// 通道占用、ping 往返时间、订阅数等按连接分别导出：客户端连接成功后开始导出，
+// 调用 Close 后不再导出，从未连接成功的客户端不导出。只依赖标准库。
+type Metrics struct {
+	mu           sync.Mutex
+	clients      map[*Client]int // 正在导出的客户端 -> conn 标签
+	nextConn     int
+	frames       map[frameKey]uint64
+	bytesRead    uint64
+	decodeErrors map[string]uint64 // data_type -> 次数，消息本身无法解析时为 "unknown"，含严格模式下字段不一致的数据帧
+	dropped      map[int]uint64
+	reconnects   uint64
+	latency      map[int]*histogram
+	drift        map[driftKey]uint64
+}
+
+// driftKey 字段差异，kind 为 unknown 或 missing
+type driftKey struct {
+	dataType int
+	kind     string
+	field    string
+}
+
+// NewMetrics 创建指标收集器
+func NewMetrics() *Metrics {
+	return &Metrics{
+		clients:      make(map[*Client]int),
+		frames:       make(map[frameKey]uint64),
+		decodeErrors: make(map[string]uint64),
+		dropped:      make(map[int]uint64),
+		latency:      make(map[int]*histogram),
+		drift:        make(map[driftKey]uint64),
+	}
+}
+
+// WithMetrics 将客户端的运行指标记录到 m，多个客户端可以共用同一个 m
+func WithMetrics(m *Metrics) Option {
+	return func(c *Client) {
+		if m == nil {
+			return
+		}
+		c.metrics = m
+	}
+}
+
+// register 开始导出客户端的连接状态，客户端已在导出时沿用原来的 conn 标签
+func (m *Metrics) register(c *Client) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.clients[c]; !ok {
+		m.clients[c] = m.nextConn
+		m.nextConn++
+	}
+}
+
+// unregister 不再导出客户端的连接状态
+func (m *Metrics) unregister(c *Client) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	delete(m.clients, c)
+	m.mu.Unlock()
+}
+
+// observeRead 记录读取的字节数
+func (m *Metrics) observeRead(n int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.bytesRead += uint64(n)
+	m.mu.Unlock()
+}
+
+// observeFrame 记录收到的数据帧和交易所时间到接收时间的延迟
+func (m *Metrics) observeFrame(md *MarketData, clock *ExchangeClock) {
+	if m == nil {
+		return
+	}
+	timing, err := clock.Timing(md)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.frames[frameKey{stockCode: md.StockCode, dataType: md.DataType}]++
+	if err != nil {
+		m.decodeErrors[strconv.Itoa(md.DataType)]++
+		return
+	}
+	if !timing.ExchangeTime.IsZero() {
+		h := m.latency[md.DataType]
+		if h == nil {
+			h = &histogram{}
+			m.latency[md.DataType] = h
+		}
+		h.observe(timing.Latency().Seconds())
+	}
+}
+
+// observeDecodeError 记录无法解析的消息，此时数据类型未知
+func (m *Metrics) observeDecodeError() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.decodeErrors["unknown"]++
+	m.mu.Unlock()
+}
+
+// observeSchemaDrift 记录首次出现的字段差异
+func (m *Metrics) observeSchemaDrift(d *SchemaDrift) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, field := range d.Unknown {
+		m.drift[driftKey{dataType: d.DataType, kind: "unknown", field: field}]++
+	}
+	for _, field := range d.Missing {
+		m.drift[driftKey{dataType: d.DataType, kind: "missing", field: field}]++
+	}
+}
+
+// observeRejected 记录严格模式下因字段差异未投递的数据帧，计入该数据类型的解析失败
+func (m *Metrics) observeRejected(dataType int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.decodeErrors[strconv.Itoa(dataType)]++
+	m.mu.Unlock()
+}
+
+// observeDropped 记录因数据通道已满而丢弃的数据帧
+func (m *Metrics) observeDropped(md *MarketData) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.dropped[md.DataType]++
+	m.mu.Unlock()
+}
+
+// observeReconnect 记录一次重新建立的连接
+func (m *Metrics) observeReconnect() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.reconnects++
+	m.mu.Unlock()
+}
+
+// clientGauges 导出时采集的单个连接状态
+type clientGauges struct {
+	conn          int
+	endpoint      string
+	connected     bool
+	channelLen    int
+	channelCap    int
+	rtt           time.Duration
+	subscriptions int
+}
+
+// WriteTo 按 Prometheus 文本格式写出全部指标
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	gauges := make([]clientGauges, 0, len(m.clients))
+	clients := make([]*Client, 0, len(m.clients))
+	for c, conn := range m.clients {
+		gauges = append(gauges, clientGauges{conn: conn})
+		clients = append(clients, c)
+	}
+	m.mu.Unlock()
+
+	// 连接状态需要获取客户端的锁，不在持有 m.mu 时采集
+	for i, c := range clients {
+		gauges[i].endpoint = c.URL()
+		gauges[i].connected = c.IsConnected()
+		gauges[i].channelLen = len(c.dataChan)
+		gauges[i].channelCap = cap(c.dataChan)
+		gauges[i].rtt = c.LastRTT()
+		gauges[i].subscriptions = len(c.GetSubscriptions())
+	}
+	sort.Slice(gauges, func(i, j int) bool { return gauges[i].conn < gauges[j].conn })
+
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	m.mu.Lock()
+	m.write(cw, gauges)
+	m.mu.Unlock()
+	if cw.err == nil {
+		cw.err = bw.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP 实现 http.Handler，可直接挂载为 /metrics
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+func (m *Metrics) write(w *countingWriter, gauges []clientGauges) {
+	w.header("dtraderhq_frames_received_total", "counter", "Data frames received.")
+	keys := make([]frameKey, 0, len(m.frames))
+	for k := range m.frames {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].stockCode != keys[j].stockCode {
+			return keys[i].stockCode < keys[j].stockCode
+		}
+		return keys[i].dataType < keys[j].dataType
+	})
+	for _, k := range keys {
+		w.sample("dtraderhq_frames_received_total", labels("stock", k.stockCode, "data_type", strconv.Itoa(k.dataType)), float64(m.frames[k]))
+	}
+
+	w.header("dtraderhq_bytes_read_total", "counter", "Bytes read from the WebSocket connections.")
+	w.sample("dtraderhq_bytes_read_total", "", float64(m.bytesRead))
+
+	w.header("dtraderhq_decode_errors_total", "counter", "Frames that could not be decoded.")
+	for _, dataType := range sortedKeys(m.decodeErrors) {
+		w.sample("dtraderhq_decode_errors_total", labels("data_type", dataType), float64(m.decodeErrors[dataType]))
+	}
+
+	w.header("dtraderhq_schema_drift_total", "counter", "Payload fields that differ from the record definitions, counted once per authenticated session.")
+	driftKeys := make([]driftKey, 0, len(m.drift))
+	for k := range m.drift {
+		driftKeys = append(driftKeys, k)
+	}
+	sort.Slice(driftKeys, func(i, j int) bool {
+		a, b := driftKeys[i], driftKeys[j]
+		if a.dataType != b.dataType {
+			return a.dataType < b.dataType
+		}
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		return a.field < b.field
+	})
+	for _, k := range driftKeys {
+		w.sample("dtraderhq_schema_drift_total", labels("data_type", strconv.Itoa(k.dataType), "kind", k.kind, "field", k.field), float64(m.drift[k]))
+	}
+
+	w.header("dtraderhq_frames_dropped_total", "counter", "Data frames dropped because the data channel was full.")
+	for _, dataType := range sortedKeys(m.dropped) {
+		w.sample("dtraderhq_frames_dropped_total", labels("data_type", strconv.Itoa(dataType)), float64(m.dropped[dataType]))
+	}
+
+	w.header("dtraderhq_reconnects_total", "counter", "Connections re-established after the first connect.")
+	w.sample("dtraderhq_reconnects_total", "", float64(m.reconnects))
+
+	type gauge struct {
+		name, help string
+		value      func(clientGauges) float64
+	}
+	for _, g := range []gauge{
+		{"dtraderhq_connected", "Whether the connection is up.", func(c clientGauges) float64 {
+			if c.connected {
+				return 1
+			}
+			return 0
+		}},
+		{"dtraderhq_data_channel_length", "Frames waiting in the data channel.", func(c clientGauges) float64 { return float64(c.channelLen) }},
+		{"dtraderhq_data_channel_capacity", "Capacity of the data channel.", func(c clientGauges) float64 { return float64(c.channelCap) }},
+		{"dtraderhq_ping_rtt_seconds", "Round trip time of the last ping.", func(c clientGauges) float64 { return c.rtt.Seconds() }},
+		{"dtraderhq_subscriptions", "Subscribed stock codes.", func(c clientGauges) float64 { return float64(c.subscriptions) }},
+	} {
+		w.header(g.name, "gauge", g.help)
+		for _, c := range gauges {
+			w.sample(g.name, labels("conn", strconv.Itoa(c.conn), "endpoint", c.endpoint), g.value(c))
+		}
+	}
+
+	w.header("dtraderhq_latency_seconds", "histogram", "Latency from exchange time to receive time.")
+	for _, dataType := range sortedKeys(m.latency) {
+		h := m.latency[dataType]
+		dt := strconv.Itoa(dataType)
+		for i, bound := range latencyBuckets {
+			w.sample("dtraderhq_latency_seconds_bucket", labels("data_type", dt, "le", formatFloat(bound)), float64(h.counts[i]))
+		}
+		w.sample("dtraderhq_latency_seconds_bucket", labels("data_type", dt, "le", "+Inf"), float64(h.count))
+		w.sample("dtraderhq_latency_seconds_sum", labels("data_type", dt), h.sum)
+		w.sample("dtraderhq_latency_seconds_count", labels("data_type", dt), float64(h.count))
+	}
+}
+
+// countingWriter 统计写出的字节数并保留第一个错误
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
+
+func (w *countingWriter) header(name, typ, help string) {
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (w *countingWriter) sample(name, labels string, value float64) {
+	w.printf("%s%s %s\n", name, labels, formatFloat(value))
+}
+
+// labels 按 name, value 成对格式化标签
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
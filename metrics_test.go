@@ -0,0 +1,135 @@
+package dtraderhq
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLabels(t *testing.T) {
+	if got := labels("stock", "SZ000001", "note", "a\"b\\c\nd"); got != `{stock="SZ000001",note="a\"b\\c\nd"}` {
+		t.Fatalf("labels = %s", got)
+	}
+	for v, want := range map[float64]string{1: "1", 0.005: "0.005", 2.5: "2.5", 1e6: "1e+06"} {
+		if got := formatFloat(v); got != want {
+			t.Errorf("formatFloat(%v) = %s, want %s", v, got, want)
+		}
+	}
+}
+
+func TestHistogramCumulative(t *testing.T) {
+	var h histogram
+	for _, v := range []float64{0.0005, 0.02, 0.02, 3, 20} {
+		h.observe(v)
+	}
+	want := map[float64]uint64{0.001: 1, 0.01: 1, 0.025: 3, 2.5: 3, 5: 4, 10: 4}
+	for i, bound := range latencyBuckets {
+		if n, ok := want[bound]; ok && h.counts[i] != n {
+			t.Errorf("bucket %v = %d, want %d", bound, h.counts[i], n)
+		}
+	}
+	if h.count != 5 || h.sum != 23.0405 {
+		t.Fatalf("count = %d, sum = %v", h.count, h.sum)
+	}
+}
+
+func TestMetricsExport(t *testing.T) {
+	srv := newTestServer(t)
+	m := NewMetrics()
+	c := connectTestClient(t, srv, WithMetrics(m), WithAutoReconnect(), WithDataBuffer(1), WithBackpressure(BackpressureDropNewest))
+	if err := c.Subscribe("SZ000001", []int{4}); err != nil {
+		t.Fatal(err)
+	}
+	closed := connectTestClient(t, srv, WithMetrics(m))
+	closed.Close()
+
+	trade := []Transaction{{OrderPackId: 1, Price: 1000, Time: time.Now().Unix() - 3, Volume: 100}}
+	frame := map[string]any{"type": MessageTypeData, "data": map[string]any{"stock_code": "SZ000001", "data_type": 4, "data": trade}}
+	srv.broadcast(frame)
+	srv.broadcast(frame) // 数据通道已满，丢弃
+	srv.broadcast("not an envelope")
+	waitFor(t, "frames", func() bool { return c.DroppedFrames() == 1 && c.DecodeErrors() == 1 })
+
+	done := c.Done()
+	srv.dropConnections()
+	<-done
+	waitFor(t, "reconnect", c.IsAuthenticated)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %s", ct)
+	}
+	out := rec.Body.String()
+	conn := `{conn="0",endpoint="` + srv.url() + `"}`
+	for _, want := range []string{
+		"# TYPE dtraderhq_frames_received_total counter\n",
+		`dtraderhq_frames_received_total{stock="SZ000001",data_type="4"} 2` + "\n",
+		`dtraderhq_decode_errors_total{data_type="unknown"} 1` + "\n",
+		`dtraderhq_frames_dropped_total{data_type="4"} 1` + "\n",
+		"dtraderhq_reconnects_total 1\n",
+		"dtraderhq_connected" + conn + " 1\n",
+		"dtraderhq_data_channel_capacity" + conn + " 1\n",
+		"dtraderhq_subscriptions" + conn + " 1\n",
+		`dtraderhq_latency_seconds_bucket{data_type="4",le="2.5"} 0` + "\n",
+		`dtraderhq_latency_seconds_bucket{data_type="4",le="5"} 2` + "\n",
+		`dtraderhq_latency_seconds_count{data_type="4"} 2` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+	// 已关闭的客户端不导出
+	if strings.Contains(out, `conn="1"`) {
+		t.Error("closed client exported")
+	}
+	if strings.Contains(out, "dtraderhq_bytes_read_total 0\n") {
+		t.Error("bytes read not counted")
+	}
+
+	var buf bytes.Buffer
+	n, err := m.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo = %d, %v (wrote %d)", n, err, buf.Len())
+	}
+	if _, err := m.WriteTo(failingWriter{}); err == nil {
+		t.Fatal("write error not returned")
+	}
+}
+
+// 反复创建、关闭客户端以及连接失败的客户端不应在导出的连接中累积
+func TestMetricsUnregister(t *testing.T) {
+	srv := newTestServer(t)
+	m := NewMetrics()
+	for i := 0; i < 3; i++ {
+		c := connectTestClient(t, srv, WithMetrics(m))
+		c.Close()
+		failed := NewClient("ws://127.0.0.1:1", WithMetrics(m))
+		if err := failed.Connect(); err == nil {
+			t.Fatal("connect to closed port succeeded")
+		}
+	}
+	live := connectTestClient(t, srv, WithMetrics(m))
+
+	m.mu.Lock()
+	n := len(m.clients)
+	m.mu.Unlock()
+	if n != 1 {
+		t.Fatalf("registered clients = %d, want 1", n)
+	}
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(buf.String(), "dtraderhq_connected{"); got != 1 {
+		t.Fatalf("exported connections = %d, want 1\n%s", got, buf.String())
+	}
+	live.Close()
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, io.ErrClosedPipe }
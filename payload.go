@@ -0,0 +1,299 @@
+package dtraderhq
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DataType 数据类型（与订阅时的 data_types 取值一致）
+type DataType = int
+
+// 当前开放的数据类型
+const (
+	DataTypeTransaction DataType = 4  // 逐笔成交（ZhubiData）
+	DataTypeBigOrder    DataType = 8  // 逐笔大单（BigOrder）
+	DataTypeZBWT        DataType = 14 // 逐笔委托（ZBWTData）
+)
+
+// PriceScale 价格缩放系数，服务器下发的价格需要除以100才是实际价格
+const PriceScale = 100
+
+// Transaction 逐笔成交记录（data_type=4）
+type Transaction struct {
+	OrderPackId int64 `json:"OrderPackId"`
+	Price       int64 `json:"Price"`
+	Time        int64 `json:"Time"`   // Unix秒
+	Volume      int64 `json:"Volume"` // 正数为主动买，负数为主动卖
+}
+
+// RealPrice 返回实际价格
+func (t Transaction) RealPrice() float64 {
+	return float64(t.Price) / PriceScale
+}
+
+// IsBuy 是否为主动买成交
+func (t Transaction) IsBuy() bool {
+	return t.Volume > 0
+}
+
+// AbsVolume 返回成交量的绝对值
+func (t Transaction) AbsVolume() int64 {
+	if t.Volume < 0 {
+		return -t.Volume
+	}
+	return t.Volume
+}
+
+// BigOrder 逐笔大单记录（data_type=8）
+type BigOrder struct {
+	OrderPackId     int64 `json:"OrderPackId"`
+	BuyOrderPackId  int64 `json:"BuyOrderPackId"`
+	SellOrderPackId int64 `json:"SellOrderPackId"`
+	BuyFlag         int   `json:"BuyFlag"`
+	SellFlag        int   `json:"SellFlag"`
+	BuyPrice        int64 `json:"BuyPrice"`
+	SellPrice       int64 `json:"SellPrice"`
+	BuyVol          int64 `json:"BuyVol"`
+	SellVol         int64 `json:"SellVol"`
+}
+
+// ZBWT 逐笔委托记录（data_type=14）
+type ZBWT struct {
+	Index    int64     `json:"Index"`
+	DateTime int64     `json:"DateTime"` // 深市 HHMMSSmmm（111258320 为 11:12:58.320），沪市 HHMMSScc（11125987 为 11:12:59.87）
+	Price    int64     `json:"Price"`
+	Volume   int64     `json:"Volume"`
+	Type     OrderType `json:"Type"`
+}
+
+// RealPrice 返回实际价格
+func (z ZBWT) RealPrice() float64 {
+	return float64(z.Price) / PriceScale
+}
+
+// MillisOfDay 将 DateTime 转换为当日零点起的毫秒数，格式按 ClockAuto 判断，无法解析时返回 -1
+//
+// 已知股票代码和数据帧时间时应使用 ParseClock。
+func (z ZBWT) MillisOfDay() int64 {
+	ms, err := ParseClock(z.DateTime, ClockAuto, -1)
+	if err != nil {
+		return -1
+	}
+	return ms
+}
+
+// ClockFormat 逐笔委托 DateTime 的格式
+type ClockFormat int
+
+const (
+	// ClockAuto 两种格式都合法时取更接近参考时刻的一种，没有参考时刻时取落在交易时段内的一种
+	ClockAuto ClockFormat = iota
+	// ClockMillis HHMMSSmmm，深市
+	ClockMillis
+	// ClockCentis HHMMSScc，沪市
+	ClockCentis
+)
+
+// String 返回格式名称
+func (f ClockFormat) String() string {
+	switch f {
+	case ClockMillis:
+		return "HHMMSSmmm"
+	case ClockCentis:
+		return "HHMMSScc"
+	}
+	return "auto"
+}
+
+// MarketClockFormat 按股票代码的市场前缀或后缀（SH/SZ）返回逐笔委托时间格式，无法判断时返回 ClockAuto
+func MarketClockFormat(stockCode string) ClockFormat {
+	code := strings.ToUpper(stockCode)
+	switch {
+	case strings.HasPrefix(code, "SH"), strings.HasSuffix(code, ".SH"):
+		return ClockCentis
+	case strings.HasPrefix(code, "SZ"), strings.HasSuffix(code, ".SZ"):
+		return ClockMillis
+	}
+	return ClockAuto
+}
+
+// 交易时段（含集合竞价和盘后）的当日毫秒范围，用于没有参考时刻时判断格式
+const (
+	sessionOpenMillis  = 9 * 3600 * 1000
+	sessionCloseMillis = 15*3600*1000 + 30*60*1000
+)
+
+// ParseClock 将逐笔委托的 DateTime 转换为当日零点起的毫秒数
+//
+// refMillis 为参考时刻（通常为数据帧时间戳）的当日毫秒数，仅 ClockAuto 使用，小于 0 表示没有参考时刻。
+func ParseClock(v int64, format ClockFormat, refMillis int64) (int64, error) {
+	switch format {
+	case ClockMillis:
+		if ms, ok := clockMillis(v, 1000); ok {
+			return ms, nil
+		}
+		return 0, fmt.Errorf("invalid HHMMSSmmm time %d", v)
+	case ClockCentis:
+		if ms, ok := clockMillis(v, 100); ok {
+			return ms, nil
+		}
+		return 0, fmt.Errorf("invalid HHMMSScc time %d", v)
+	}
+
+	milli, okMilli := clockMillis(v, 1000)
+	centi, okCenti := clockMillis(v, 100)
+	switch {
+	case okMilli && !okCenti:
+		return milli, nil
+	case okCenti && !okMilli:
+		return centi, nil
+	case !okMilli && !okCenti:
+		return 0, fmt.Errorf("invalid order time %d", v)
+	}
+
+	if refMillis >= 0 {
+		if absDiff(centi, refMillis) < absDiff(milli, refMillis) {
+			return centi, nil
+		}
+		return milli, nil
+	}
+	if inSession(centi) && !inSession(milli) {
+		return centi, nil
+	}
+	return milli, nil
+}
+
+// clockMillis 解析 HHMMSS 后接 unit 分之一秒的时刻
+func clockMillis(v, unit int64) (int64, bool) {
+	if v < 0 {
+		return 0, false
+	}
+	frac := v % unit
+	hhmmss := v / unit
+	hh, mm, ss := hhmmss/10000, hhmmss/100%100, hhmmss%100
+	if hh > 23 || mm > 59 || ss > 59 {
+		return 0, false
+	}
+	return ((hh*60+mm)*60+ss)*1000 + frac*(1000/unit), true
+}
+
+func inSession(ms int64) bool {
+	return ms >= sessionOpenMillis && ms <= sessionCloseMillis
+}
+
+func absDiff(a, b int64) int64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// OrderType 逐笔委托类型，BA(买入报单)、SA(卖出报单)、BD(买入撤单)、SD(卖出撤单)
+type OrderType [2]byte
+
+// 逐笔委托类型
+var (
+	OrderTypeBuyAdd     = OrderType{'B', 'A'}
+	OrderTypeSellAdd    = OrderType{'S', 'A'}
+	OrderTypeBuyCancel  = OrderType{'B', 'D'}
+	OrderTypeSellCancel = OrderType{'S', 'D'}
+)
+
+// String 返回两字符形式，例如 "BA"
+func (t OrderType) String() string {
+	return string(t[:])
+}
+
+// IsBuy 是否为买方向
+func (t OrderType) IsBuy() bool {
+	return t[0] == 'B'
+}
+
+// IsCancel 是否为撤单
+func (t OrderType) IsCancel() bool {
+	return t[1] == 'D'
+}
+
+// MarshalJSON 按服务器格式编码为字节数组
+func (t OrderType) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]int{int(t[0]), int(t[1])})
+}
+
+// UnmarshalJSON 同时兼容字节数组（[83,65]）和字符串（"SA"）两种格式
+func (t *OrderType) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if len(s) != 2 {
+			return fmt.Errorf("invalid order type %q", s)
+		}
+		t[0], t[1] = s[0], s[1]
+		return nil
+	}
+
+	var arr []int
+	if err := json.Unmarshal(b, &arr); err != nil {
+		return fmt.Errorf("invalid order type: %w", err)
+	}
+	if len(arr) != 2 {
+		return fmt.Errorf("invalid order type length %d", len(arr))
+	}
+	t[0], t[1] = byte(arr[0]), byte(arr[1])
+	return nil
+}
+
+// decodePayload 将 MarketData.Data 解码为指定类型的记录切片，兼容数组和单个对象两种格式
+func decodePayload[T any](md *MarketData, want DataType, out *[]T) error {
+	if md.DataType != want {
+		return fmt.Errorf("data type mismatch: want %d, got %d", want, md.DataType)
+	}
+
+	var raw []byte
+	switch v := md.Data.(type) {
+	case json.RawMessage:
+		raw = v
+	case []byte:
+		raw = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("marshal payload: %w", err)
+		}
+		raw = b
+	}
+
+	if len(raw) > 0 && raw[0] == '{' {
+		var item T
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+		*out = []T{item}
+		return nil
+	}
+
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("decode payload: %w", err)
+	}
+	return nil
+}
+
+// DecodeTransactions 解码逐笔成交数据
+func DecodeTransactions(md *MarketData) ([]Transaction, error) {
+	var records []Transaction
+	err := decodePayload(md, DataTypeTransaction, &records)
+	return records, err
+}
+
+// DecodeBigOrders 解码逐笔大单数据
+func DecodeBigOrders(md *MarketData) ([]BigOrder, error) {
+	var records []BigOrder
+	err := decodePayload(md, DataTypeBigOrder, &records)
+	return records, err
+}
+
+// DecodeZBWT 解码逐笔委托数据
+func DecodeZBWT(md *MarketData) ([]ZBWT, error) {
+	var records []ZBWT
+	err := decodePayload(md, DataTypeZBWT, &records)
+	return records, err
+}
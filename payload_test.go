@@ -0,0 +1,173 @@
+package dtraderhq
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const sampleDir = "examples/stock_collector/stock_data"
+
+// loadSample 读取 examples 中录制的数据帧
+func loadSample(t *testing.T, name string) []*MarketData {
+	t.Helper()
+	f, err := os.Open(filepath.Join(sampleDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var frames []*MarketData
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var md MarketData
+		if err := json.Unmarshal(sc.Bytes(), &md); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		frames = append(frames, &md)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return frames
+}
+
+func hms(h, m, s, ms int64) int64 {
+	return ((h*60+m)*60+s)*1000 + ms
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		name   string
+		v      int64
+		format ClockFormat
+		ref    int64
+		want   int64
+		err    bool
+	}{
+		{"SZ HHMMSSmmm", 111257470, ClockMillis, -1, hms(11, 12, 57, 470), false},
+		{"SZ before 10:00", 93000120, ClockMillis, -1, hms(9, 30, 0, 120), false},
+		{"SH HHMMSScc", 11125987, ClockCentis, -1, hms(11, 12, 59, 870), false},
+		{"SH before 10:00", 9300050, ClockCentis, -1, hms(9, 30, 0, 500), false},
+		{"SH as millis is invalid", 14595999, ClockMillis, -1, 0, true},
+		{"SZ as centis is invalid", 111257470, ClockCentis, -1, 0, true},
+		{"negative", -1, ClockMillis, -1, 0, true},
+		{"auto 9 digits", 111257470, ClockAuto, -1, hms(11, 12, 57, 470), false},
+		{"auto 8 digits SH in session", 11125987, ClockAuto, -1, hms(11, 12, 59, 870), false},
+		{"auto 8 digits SZ in session", 93000120, ClockAuto, -1, hms(9, 30, 0, 120), false},
+		{"auto 7 digits SH in session", 9300050, ClockAuto, -1, hms(9, 30, 0, 500), false},
+		{"auto uses reference", 11125987, ClockAuto, hms(1, 11, 26, 0), hms(1, 11, 25, 987), false},
+		{"auto invalid", 999999999, ClockAuto, -1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseClock(tt.v, tt.format, tt.ref)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("ParseClock(%d) = %d, want error", tt.v, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseClock(%d) = %d, want %d", tt.v, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarketClockFormat(t *testing.T) {
+	tests := map[string]ClockFormat{
+		"SH603065":  ClockCentis,
+		"sh600000":  ClockCentis,
+		"600000.SH": ClockCentis,
+		"SZ002062":  ClockMillis,
+		"000001.sz": ClockMillis,
+		"000001":    ClockAuto,
+	}
+	for code, want := range tests {
+		if got := MarketClockFormat(code); got != want {
+			t.Errorf("MarketClockFormat(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+// 录制数据中每条委托的时刻都不晚于数据帧时间戳太多，也不会早于太多（帧内可能带有积压的记录）
+func TestOrderMillisSamples(t *testing.T) {
+	tests := []struct {
+		file   string
+		format ClockFormat
+	}{
+		{"SH603065_order_20250627.json", ClockCentis},
+		{"SH603166_order_20250627.json", ClockCentis},
+		{"SH603256_order_20250627.json", ClockCentis},
+		{"SZ002062_order_20250627.json", ClockMillis},
+		{"SZ002240_order_20250627.json", ClockMillis},
+		{"SZ002530_order_20250627.json", ClockMillis},
+		{"SZ002930_order_20250627.json", ClockMillis},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			frames := loadSample(t, tt.file)
+			if len(frames) == 0 {
+				t.Fatal("no frames")
+			}
+			for _, md := range frames {
+				if got := MarketClockFormat(md.StockCode); got != tt.format {
+					t.Fatalf("format of %s = %v, want %v", md.StockCode, got, tt.format)
+				}
+				orders, err := DecodeZBWT(md)
+				if err != nil {
+					t.Fatal(err)
+				}
+				times, err := OrderMillis(md, orders)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// 不带市场前缀时按帧时间戳自动判断，结果应相同
+				bare := *md
+				bare.StockCode = md.StockCode[2:]
+				auto, err := OrderMillis(&bare, orders)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				ft := time.Unix(md.Timestamp, 0).In(time.FixedZone("CST", 8*3600))
+				ref := int64(ft.Hour()*3600+ft.Minute()*60+ft.Second()) * 1000
+				for i, ms := range times {
+					if auto[i] != ms {
+						t.Fatalf("DateTime %d: auto %d, market %d", orders[i].DateTime, auto[i], ms)
+					}
+					if d := ms - ref; d > 2000 || d < -5*60*1000 {
+						t.Fatalf("DateTime %d parsed as %s, frame at %s", orders[i].DateTime,
+							time.Duration(ms)*time.Millisecond, ft.Format("15:04:05"))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeZBWTOrderType(t *testing.T) {
+	md := &MarketData{
+		DataType: DataTypeZBWT,
+		Data:     json.RawMessage(`[{"DateTime":111257470,"Index":1,"Price":490,"Type":[83,68],"Volume":2500},{"DateTime":111257480,"Index":2,"Price":490,"Type":"BA","Volume":100}]`),
+	}
+	orders, err := DecodeZBWT(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 || orders[0].Type != OrderTypeSellCancel || orders[1].Type != OrderTypeBuyAdd {
+		t.Fatalf("orders = %+v", orders)
+	}
+	if !orders[0].Type.IsCancel() || orders[0].Type.IsBuy() || orders[0].RealPrice() != 4.90 {
+		t.Fatalf("order 0 = %+v", orders[0])
+	}
+}
@@ -0,0 +1,499 @@
+package dtraderhq
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Pool 多连接客户端池，将股票按哈希分散到 N 个连接上，合并各连接的数据流
+//
+// 股票按 rendezvous 哈希分配到存活的连接，连接断开时其上的股票立即迁移到其余连接，
+// 断开的连接在后台重连，恢复后按哈希把股票迁回。迁移时先订阅新连接再取消旧连接，
+// 期间可能收到重复数据，可用 Deduper 过滤。
+type Pool struct {
+	clients   []*Client
+	dataChan  chan *MarketData
+	errorChan chan error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// moveMu 串行执行断线迁移和重新平衡，迁移发送请求时不持有 mu
+	moveMu sync.Mutex
+
+	mu      sync.Mutex
+	token   string
+	alive   []bool
+	owner   map[string]int // stockCode -> 连接序号
+	started bool
+}
+
+// NewPool 创建包含 size 个连接的客户端池，opts 应用于每个连接
+func NewPool(serverURL string, size int, opts ...Option) *Pool {
+	if size < 1 {
+		size = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		clients: make([]*Client, size),
+		alive:   make([]bool, size),
+		owner:   make(map[string]int),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	for i := range p.clients {
+		p.clients[i] = NewClient(serverURL, opts...)
+	}
+	p.dataChan = make(chan *MarketData, cap(p.clients[0].dataChan))
+	p.errorChan = make(chan error, cap(p.clients[0].errorChan))
+	return p
+}
+
+// Size 返回连接数
+func (p *Pool) Size() int {
+	return len(p.clients)
+}
+
+// Clients 返回池中的全部连接
+func (p *Pool) Clients() []*Client {
+	return append([]*Client(nil), p.clients...)
+}
+
+// Connect 建立全部连接，任一连接失败时关闭已建立的连接并返回错误
+func (p *Pool) Connect() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started {
+		return ErrAlreadyConnected
+	}
+
+	for i, c := range p.clients {
+		if err := c.Connect(); err != nil {
+			for _, opened := range p.clients[:i] {
+				opened.Close()
+			}
+			return fmt.Errorf("pool connection %d: %w", i, err)
+		}
+	}
+
+	p.started = true
+	for i, c := range p.clients {
+		p.alive[i] = true
+		p.wg.Add(2)
+		go p.forward(c)
+		go p.monitor(i)
+	}
+	return nil
+}
+
+// Close 关闭全部连接并停止后台重连
+func (p *Pool) Close() error {
+	p.cancel()
+	for _, c := range p.clients {
+		c.Close()
+	}
+	p.wg.Wait()
+	return nil
+}
+
+// IsConnected 是否至少有一个连接可用
+func (p *Pool) IsConnected() bool {
+	for _, c := range p.clients {
+		if c.IsConnected() {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate 在全部连接上认证，token 同时用于断线重连
+func (p *Pool) Authenticate(token string) error {
+	p.mu.Lock()
+	p.token = token
+	p.mu.Unlock()
+
+	for i, c := range p.clients {
+		if err := c.Authenticate(token); err != nil {
+			return fmt.Errorf("pool connection %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// WaitAuthenticated 等待全部连接认证完成
+func (p *Pool) WaitAuthenticated(ctx context.Context) error {
+	for i, c := range p.clients {
+		if err := c.WaitAuthenticated(ctx); err != nil {
+			return fmt.Errorf("pool connection %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// DataChannel 获取合并后的数据通道
+func (p *Pool) DataChannel() <-chan *MarketData {
+	return p.dataChan
+}
+
+// ErrorChannel 获取合并后的错误通道，连接断开和迁移失败也会在此报告
+func (p *Pool) ErrorChannel() <-chan error {
+	return p.errorChan
+}
+
+// Subscribe 订阅股票数据，已订阅的股票在原连接上更新数据类型
+func (p *Pool) Subscribe(stockCode string, dataTypes []int) error {
+	return p.BatchSubscribe([]SubscribeMessage{{StockCode: stockCode, DataTypes: dataTypes}})
+}
+
+// BatchSubscribe 批量订阅，按股票所属连接分组发送
+//
+// 新股票在持有锁时分配连接，发送请求和等待应答时不持有锁；发送失败时撤销未成功的分配。
+func (p *Pool) BatchSubscribe(subscriptions []SubscribeMessage) error {
+	if len(subscriptions) == 0 {
+		return ErrEmptyBatch
+	}
+	if len(subscriptions) > MaxBatchSize {
+		return fmt.Errorf("%w: %d subscriptions", ErrBatchTooLarge, len(subscriptions))
+	}
+
+	p.mu.Lock()
+	groups := make(map[int][]SubscribeMessage)
+	assigned := make(map[int][]string) // 本次新分配的股票
+	for _, sub := range subscriptions {
+		i, ok := p.owner[sub.StockCode]
+		if !ok {
+			if i = p.pickLocked(sub.StockCode); i < 0 {
+				p.unassignLocked(assigned)
+				p.mu.Unlock()
+				return ErrNoLiveConnection
+			}
+			p.owner[sub.StockCode] = i
+			assigned[i] = append(assigned[i], sub.StockCode)
+		}
+		groups[i] = append(groups[i], sub)
+	}
+	p.mu.Unlock()
+
+	for _, i := range sortedKeys(groups) {
+		if err := p.clients[i].BatchSubscribe(groups[i]); err != nil {
+			p.mu.Lock()
+			for j := range assigned {
+				if j < i {
+					delete(assigned, j)
+				}
+			}
+			p.unassignLocked(assigned)
+			p.mu.Unlock()
+			return fmt.Errorf("pool connection %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// SubscribeAll 订阅任意数量的股票，按每批最多 MaxBatchSize 个分批发送
+func (p *Pool) SubscribeAll(subscriptions []SubscribeMessage) error {
+	for start := 0; start < len(subscriptions); start += MaxBatchSize {
+		end := min(start+MaxBatchSize, len(subscriptions))
+		if err := p.BatchSubscribe(subscriptions[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unsubscribe 取消订阅
+func (p *Pool) Unsubscribe(stockCode string) error {
+	return p.BatchUnsubscribe([]string{stockCode})
+}
+
+// BatchUnsubscribe 批量取消订阅，未订阅的股票被忽略
+func (p *Pool) BatchUnsubscribe(stockCodes []string) error {
+	if len(stockCodes) == 0 {
+		return ErrEmptyBatch
+	}
+	if len(stockCodes) > MaxBatchSize {
+		return fmt.Errorf("%w: %d stock codes", ErrBatchTooLarge, len(stockCodes))
+	}
+
+	p.mu.Lock()
+	groups := make(map[int][]string)
+	for _, stockCode := range stockCodes {
+		if i, ok := p.owner[stockCode]; ok {
+			groups[i] = append(groups[i], stockCode)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, i := range sortedKeys(groups) {
+		if err := p.clients[i].BatchUnsubscribe(groups[i]); err != nil {
+			return fmt.Errorf("pool connection %d: %w", i, err)
+		}
+		p.mu.Lock()
+		p.unassignLocked(map[int][]string{i: groups[i]})
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// GetSubscriptions 获取全部连接上的订阅
+func (p *Pool) GetSubscriptions() map[string][]int {
+	result := make(map[string][]int)
+	for _, c := range p.clients {
+		for stockCode, dataTypes := range c.GetSubscriptions() {
+			result[stockCode] = dataTypes
+		}
+	}
+	return result
+}
+
+// Assignments 返回每只股票所在的连接序号
+func (p *Pool) Assignments() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make(map[string]int, len(p.owner))
+	for stockCode, i := range p.owner {
+		result[stockCode] = i
+	}
+	return result
+}
+
+// pickLocked 按 rendezvous 哈希在存活连接中为股票选择连接，没有存活连接时返回 -1
+func (p *Pool) pickLocked(stockCode string) int {
+	best, bestScore := -1, uint64(0)
+	for i, alive := range p.alive {
+		if !alive {
+			continue
+		}
+		h := fnv.New64a()
+		h.Write([]byte(stockCode))
+		h.Write([]byte{'#'})
+		h.Write([]byte(strconv.Itoa(i)))
+		if score := h.Sum64(); best < 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// forward 将单个连接的数据和错误转发到池的通道
+func (p *Pool) forward(c *Client) {
+	defer p.wg.Done()
+	for {
+		select {
+		case md := <-c.DataChannel():
+			select {
+			case p.dataChan <- md:
+			case <-p.ctx.Done():
+				return
+			}
+		case err := <-c.ErrorChannel():
+			p.reportError(err)
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Pool) reportError(err error) {
+	select {
+	case p.errorChan <- err:
+	case <-p.ctx.Done():
+	}
+}
+
+// monitor 监视连接断开：迁移其上的股票，后台重连，恢复后重新平衡
+func (p *Pool) monitor(i int) {
+	defer p.wg.Done()
+
+	c := p.clients[i]
+	for {
+		select {
+		case <-c.Done():
+		case <-p.ctx.Done():
+			return
+		}
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		p.moveMu.Lock()
+		p.setAlive(i, false)
+		moved, err := p.evacuate(i)
+		p.moveMu.Unlock()
+		if err != nil {
+			p.reportError(fmt.Errorf("pool connection %d: %w, moving subscriptions: %w", i, ErrConnectionClosed, err))
+		} else {
+			c.logger().Info("pool subscriptions moved", slog.Int("connection", i), slog.Int("moved", moved))
+			p.reportError(fmt.Errorf("pool connection %d: %w, moved %d subscriptions", i, ErrConnectionClosed, moved))
+		}
+
+		if err := p.reconnect(c); err != nil {
+			return
+		}
+
+		p.moveMu.Lock()
+		p.setAlive(i, true)
+		err = p.rebalance()
+		p.moveMu.Unlock()
+		if err != nil {
+			p.reportError(fmt.Errorf("pool connection %d restored, rebalancing: %w", i, err))
+		}
+	}
+}
+
+func (p *Pool) setAlive(i int, alive bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.alive[i] = alive
+}
+
+// evacuate 将断开连接上的股票迁移到其余存活连接，没有存活连接时保留在原连接上等待重连恢复
+func (p *Pool) evacuate(i int) (int, error) {
+	p.mu.Lock()
+	groups := make(map[int][]SubscribeMessage)
+	for stockCode, dataTypes := range p.clients[i].GetSubscriptions() {
+		to := p.pickLocked(stockCode)
+		if to < 0 {
+			p.mu.Unlock()
+			return 0, nil
+		}
+		groups[to] = append(groups[to], SubscribeMessage{StockCode: stockCode, DataTypes: dataTypes})
+	}
+	p.mu.Unlock()
+
+	moved := 0
+	var errs []error
+	for _, to := range sortedKeys(groups) {
+		subs := groups[to]
+		sort.Slice(subs, func(a, b int) bool { return subs[a].StockCode < subs[b].StockCode })
+		if err := p.clients[to].SubscribeAll(subs); err != nil {
+			errs = append(errs, fmt.Errorf("pool connection %d: %w", to, err))
+			continue
+		}
+		// 从断开的连接上移除，重连时不再恢复这些股票
+		p.clients[i].forget(subs)
+		p.assign(subs, i, to)
+		moved += len(subs)
+	}
+	return moved, errors.Join(errs...)
+}
+
+// rebalance 将不在哈希首选连接上的股票迁移过去，先订阅新连接再取消旧连接
+func (p *Pool) rebalance() error {
+	type move struct{ from, to int }
+	groups := make(map[move][]SubscribeMessage)
+	p.mu.Lock()
+	for i, c := range p.clients {
+		if !p.alive[i] {
+			continue
+		}
+		for stockCode, dataTypes := range c.GetSubscriptions() {
+			if to := p.pickLocked(stockCode); to != i {
+				m := move{from: i, to: to}
+				groups[m] = append(groups[m], SubscribeMessage{StockCode: stockCode, DataTypes: dataTypes})
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	var errs []error
+	for m, subs := range groups {
+		sort.Slice(subs, func(a, b int) bool { return subs[a].StockCode < subs[b].StockCode })
+		if err := p.clients[m.to].SubscribeAll(subs); err != nil {
+			errs = append(errs, fmt.Errorf("pool connection %d: %w", m.to, err))
+			continue
+		}
+		p.assign(subs, m.from, m.to)
+
+		codes := make([]string, len(subs))
+		for k, sub := range subs {
+			codes[k] = sub.StockCode
+		}
+		for start := 0; start < len(codes); start += MaxBatchSize {
+			end := min(start+MaxBatchSize, len(codes))
+			if err := p.clients[m.from].BatchUnsubscribe(codes[start:end]); err != nil {
+				errs = append(errs, fmt.Errorf("pool connection %d: %w", m.from, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// assign 迁移完成后更新股票所属连接，迁移期间已取消或已改由其他连接订阅的股票保持不变
+func (p *Pool) assign(subs []SubscribeMessage, from, to int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, sub := range subs {
+		if i, ok := p.owner[sub.StockCode]; ok && i == from {
+			p.owner[sub.StockCode] = to
+		}
+	}
+}
+
+// unassignLocked 移除股票的连接分配，期间已改由其他连接订阅的股票保持不变，调用方需持有 mu
+func (p *Pool) unassignLocked(codes map[int][]string) {
+	for i, stockCodes := range codes {
+		for _, stockCode := range stockCodes {
+			if owner, ok := p.owner[stockCode]; ok && owner == i {
+				delete(p.owner, stockCode)
+			}
+		}
+	}
+}
+
+// reconnect 按指数退避重连、认证并恢复单个连接的订阅，直到成功或池关闭
+func (p *Pool) reconnect(c *Client) error {
+	backoff := time.Second
+	for {
+		p.mu.Lock()
+		token := p.token
+		p.mu.Unlock()
+
+		// token 为空时由客户端的 TokenProvider 提供
+		ctx, cancel := context.WithTimeout(p.ctx, 10*time.Second)
+		err := c.Reconnect(ctx, token)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// forget 从本地订阅记录中移除股票，不向服务器发送请求
+func (c *Client) forget(subscriptions []SubscribeMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, sub := range subscriptions {
+		delete(c.subscriptions, sub.StockCode)
+	}
+}
+
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
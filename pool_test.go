@@ -0,0 +1,178 @@
+package dtraderhq
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func connectTestPool(t *testing.T, srv *testServer, size int, opts ...Option) *Pool {
+	t.Helper()
+	p := NewPool(srv.url(), size, opts...)
+	if err := p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	if err := p.Authenticate(""); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := p.WaitAuthenticated(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func poolTestSubscriptions(n int) []SubscribeMessage {
+	subs := make([]SubscribeMessage, n)
+	for i := range subs {
+		subs[i] = SubscribeMessage{StockCode: fmt.Sprintf("SZ%06d", i+1), DataTypes: []int{4}}
+	}
+	return subs
+}
+
+// 断开的连接经 TokenProvider 重新认证，股票先迁走再迁回
+func TestPoolFailoverWithTokenProvider(t *testing.T) {
+	srv := newTestServer(t)
+	srv.ackRequests(nil)
+	var fetched atomic.Int32
+	provider := TokenProviderFunc(func(context.Context) (string, error) {
+		fetched.Add(1)
+		return "token", nil
+	})
+	p := connectTestPool(t, srv, 2, WithTokenProvider(provider), WithAckTimeout(time.Second))
+	if err := p.SubscribeAll(poolTestSubscriptions(20)); err != nil {
+		t.Fatal(err)
+	}
+	before := p.Assignments()
+	onFirst := 0
+	for _, i := range before {
+		if i == 0 {
+			onFirst++
+		}
+	}
+	if onFirst == 0 || onFirst == len(before) {
+		t.Fatalf("assignments not spread: %v", before)
+	}
+
+	srv.dropConnection(0)
+	moved := fmt.Sprintf("moved %d subscriptions", onFirst)
+	for reported := false; !reported; {
+		select {
+		case err := <-p.ErrorChannel():
+			reported = strings.Contains(err.Error(), moved)
+		case <-time.After(3 * time.Second):
+			t.Fatal("subscription move not reported")
+		}
+	}
+
+	waitFor(t, "rebalance", func() bool { return reflect.DeepEqual(p.Assignments(), before) })
+	c := p.Clients()[0]
+	if !c.IsAuthenticated() {
+		t.Fatal("reconnected connection not authenticated")
+	}
+	if got := len(c.GetSubscriptions()); got != onFirst {
+		t.Fatalf("connection 0 has %d subscriptions, want %d", got, onFirst)
+	}
+	if n := len(srv.messages(MessageTypeAuth)); n != 3 || fetched.Load() != 3 {
+		t.Fatalf("auth requests = %d, tokens fetched = %d", n, fetched.Load())
+	}
+}
+
+// 订阅和取消订阅等待应答时不持有池的锁
+func TestPoolRequestsDoNotBlockPool(t *testing.T) {
+	srv := newTestServer(t)
+	p := connectTestPool(t, srv, 2, WithAckTimeout(2*time.Second))
+	release := make(chan struct{})
+	srv.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		if msg["type"] != MessageTypeBatchSubscribe && msg["type"] != MessageTypeBatchUnsubscribe {
+			return false
+		}
+		<-release
+		srv.write(conn, map[string]any{"type": msg["type"], "data": map[string]any{"message": "ok"}})
+		return true
+	})
+
+	for _, op := range []string{MessageTypeBatchSubscribe, MessageTypeBatchUnsubscribe} {
+		sent := len(srv.messages(op))
+		result := make(chan error, 1)
+		go func() {
+			if op == MessageTypeBatchSubscribe {
+				result <- p.Subscribe("SZ000001", []int{4})
+			} else {
+				result <- p.Unsubscribe("SZ000001")
+			}
+		}()
+		waitFor(t, op+" sent", func() bool { return len(srv.messages(op)) > sent })
+
+		done := make(chan struct{})
+		go func() {
+			p.Assignments()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("pool blocked during %s", op)
+		}
+		release <- struct{}{}
+		if err := <-result; err != nil {
+			t.Fatalf("%s = %v", op, err)
+		}
+	}
+	if got := p.Assignments(); len(got) != 0 {
+		t.Fatalf("assignments = %v", got)
+	}
+}
+
+// 迁移等待应答时不持有池的锁
+func TestPoolMoveDoesNotBlockPool(t *testing.T) {
+	srv := newTestServer(t)
+	p := connectTestPool(t, srv, 2, WithTokenProvider(StaticToken("token")), WithAckTimeout(2*time.Second))
+	srv.ackRequests(nil)
+	if err := p.SubscribeAll(poolTestSubscriptions(20)); err != nil {
+		t.Fatal(err)
+	}
+
+	var slow atomic.Bool
+	slow.Store(true)
+	release := make(chan struct{})
+	srv.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		if msg["type"] != MessageTypeBatchSubscribe && msg["type"] != MessageTypeBatchUnsubscribe {
+			return false
+		}
+		if slow.Load() {
+			<-release
+		}
+		srv.write(conn, map[string]any{"type": msg["type"], "data": map[string]any{"message": "ok"}})
+		return true
+	})
+	defer func() {
+		if slow.CompareAndSwap(true, false) {
+			close(release)
+		}
+	}()
+
+	sent := len(srv.messages(MessageTypeBatchSubscribe))
+	srv.dropConnection(0)
+	waitFor(t, "move request", func() bool { return len(srv.messages(MessageTypeBatchSubscribe)) > sent })
+
+	done := make(chan struct{})
+	go func() {
+		p.Assignments()
+		p.Unsubscribe("SH600000")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pool blocked while moving subscriptions")
+	}
+}
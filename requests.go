@@ -0,0 +1,302 @@
+package dtraderhq
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// defaultPendingTTL 未设置 WithAckTimeout 时等待应答的请求保留的时长
+const defaultPendingTTL = 5 * time.Second
+
+// pendingRequest 已发送、尚未收到应答的请求
+type pendingRequest struct {
+	op     string
+	codes  []string
+	sentAt time.Time
+	result chan error // WithAckTimeout 时非空，容量为 1
+	undo   *subscriptionUndo
+}
+
+// subscriptionUndo 请求对本地订阅记录的修改，请求失败时据此恢复
+type subscriptionUndo struct {
+	prev, next map[string][]int // 修改前后的订阅记录，只含涉及的股票，不在 map 中表示未订阅
+}
+
+// WithAckTimeout 订阅、取消订阅和重置订阅等待服务器应答，最长等待 d
+//
+// 服务器返回错误时由调用返回 *RequestError，结果中逐只股票的失败以 *SubscriptionError 返回，
+// 超时未收到应答时返回 ErrAckTimeout。返回错误时 GetSubscriptions 恢复为请求前的记录，
+// 逐只股票失败时只恢复失败的股票；未设置时服务器返回的错误同样恢复本地记录。等待期间数据照常投递，
+// 但在 BackpressureBlock 下不要在读取 DataChannel 的协程中调用，否则只能等到超时。
+func WithAckTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.ackTimeout = d
+	}
+}
+
+// sendRequest 发送订阅类请求并登记为等待应答，设置了 WithAckTimeout 时等待应答；
+// undo 为请求对本地订阅记录的修改，请求失败时恢复
+func (c *Client) sendRequest(msg Message, codes []string, undo *subscriptionUndo) error {
+	req := &pendingRequest{op: msg.Type, codes: codes, sentAt: time.Now(), undo: undo}
+	if c.ackTimeout > 0 {
+		req.result = make(chan error, 1)
+	}
+
+	c.mu.Lock()
+	c.expirePendingLocked(req.sentAt)
+	c.pending = append(c.pending, req)
+	done := c.closeChan
+	c.mu.Unlock()
+
+	if err := c.sendMessage(msg); err != nil {
+		c.removePending(req)
+		c.revertSubscriptions(undo, nil)
+		return err
+	}
+	if req.result == nil {
+		return nil
+	}
+
+	timer := time.NewTimer(c.ackTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-req.result:
+		return err
+	case <-timer.C:
+		c.removePending(req)
+		c.revertSubscriptions(undo, nil)
+		return fmt.Errorf("%w: %s after %s", ErrAckTimeout, msg.Type, c.ackTimeout)
+	case <-done:
+		c.revertSubscriptions(undo, nil)
+		return ErrConnectionClosed
+	}
+}
+
+// updateSubscriptionsLocked 先取消 remove 中的股票，再按 set 订阅，返回恢复所需的记录，调用方需持有写锁
+func (c *Client) updateSubscriptionsLocked(set []SubscribeMessage, remove []string) *subscriptionUndo {
+	affected := append(append([]string(nil), remove...), subscriptionCodes(set)...)
+	undo := &subscriptionUndo{prev: make(map[string][]int), next: make(map[string][]int)}
+	for _, stockCode := range affected {
+		if types, ok := c.subscriptions[stockCode]; ok {
+			undo.prev[stockCode] = types
+		}
+	}
+	for _, stockCode := range remove {
+		delete(c.subscriptions, stockCode)
+	}
+	for _, sub := range set {
+		c.subscriptions[sub.StockCode] = sub.DataTypes
+	}
+	for _, stockCode := range affected {
+		if types, ok := c.subscriptions[stockCode]; ok {
+			undo.next[stockCode] = types
+		}
+	}
+	return undo
+}
+
+// revertSubscriptions 恢复 stockCodes 的本地订阅记录，stockCodes 为空时恢复请求涉及的全部股票；
+// 请求之后又被修改过的股票保持不变
+func (c *Client) revertSubscriptions(undo *subscriptionUndo, stockCodes []string) {
+	if undo == nil {
+		return
+	}
+	if len(stockCodes) == 0 {
+		for stockCode := range undo.prev {
+			stockCodes = append(stockCodes, stockCode)
+		}
+		for stockCode := range undo.next {
+			stockCodes = append(stockCodes, stockCode)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, stockCode := range stockCodes {
+		current, subscribed := c.subscriptions[stockCode]
+		next, wasSet := undo.next[stockCode]
+		if subscribed != wasSet || !slices.Equal(current, next) {
+			continue
+		}
+		if prev, ok := undo.prev[stockCode]; ok {
+			c.subscriptions[stockCode] = prev
+		} else {
+			delete(c.subscriptions, stockCode)
+		}
+	}
+}
+
+// pendingTTL 等待应答的请求保留的时长
+func (c *Client) pendingTTL() time.Duration {
+	if c.ackTimeout > 0 {
+		return c.ackTimeout
+	}
+	return defaultPendingTTL
+}
+
+// expirePendingLocked 丢弃超过保留时长仍未收到应答的请求，认证请求不过期，调用方需持有写锁
+func (c *Client) expirePendingLocked(now time.Time) {
+	ttl := c.pendingTTL()
+	kept := c.pending[:0]
+	for _, req := range c.pending {
+		if req == c.authReq || now.Sub(req.sentAt) <= ttl {
+			kept = append(kept, req)
+		}
+	}
+	clear(c.pending[len(kept):])
+	c.pending = kept
+}
+
+func (c *Client) removePending(req *pendingRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removePendingLocked(req)
+}
+
+// removePendingLocked 调用方需持有写锁
+func (c *Client) removePendingLocked(req *pendingRequest) {
+	for i, r := range c.pending {
+		if r == req {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// takePending 按发送顺序取出最早的匹配请求：op 为空时匹配任意订阅类请求，
+// stockCode 非空时只匹配包含该股票的请求。认证请求由 handleAuthReply 处理，不在此匹配
+func (c *Client) takePending(op, stockCode string) *pendingRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expirePendingLocked(time.Now())
+
+	match := -1
+	for i, req := range c.pending {
+		if req == c.authReq {
+			continue
+		}
+		if (op == "" || req.op == op) && (stockCode == "" || containsCode(req.codes, stockCode)) {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		return nil
+	}
+	req := c.pending[match]
+	c.pending = append(c.pending[:match], c.pending[match+1:]...)
+	return req
+}
+
+// takeErrorPending 取出错误消息所属的请求：按标明的请求类型和股票匹配，
+// 两者都没有标明时只在恰有一个未应答的订阅类请求时匹配该请求
+func (c *Client) takeErrorPending(serverErr *ServerError) *pendingRequest {
+	stockCode, _ := serverErr.Context["stock_code"].(string)
+	op := replyOp(serverErr.Context)
+	if op != "" || stockCode != "" {
+		return c.takePending(op, stockCode)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expirePendingLocked(time.Now())
+	var match *pendingRequest
+	for _, req := range c.pending {
+		if req == c.authReq {
+			continue
+		}
+		if match != nil {
+			return nil
+		}
+		match = req
+	}
+	if match != nil {
+		c.removePendingLocked(match)
+	}
+	return match
+}
+
+// replyOp 返回 success、error 等通用应答在 data 中标明的请求类型，没有标明时为空
+func replyOp(data map[string]interface{}) string {
+	for _, key := range []string{"op", "type", "action"} {
+		if op, _ := data[key].(string); op != "" {
+			return op
+		}
+	}
+	return ""
+}
+
+// subscriptionCodes 返回订阅涉及的股票代码
+func subscriptionCodes(subscriptions []SubscribeMessage) []string {
+	codes := make([]string, len(subscriptions))
+	for i, sub := range subscriptions {
+		codes[i] = sub.StockCode
+	}
+	return codes
+}
+
+func containsCode(codes []string, stockCode string) bool {
+	for _, code := range codes {
+		if code == stockCode {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveReply 将订阅类应答交给最早的 op 请求，恢复失败股票的本地记录，
+// 请求在等待应答时返回 true，errs 由调用返回
+func (c *Client) resolveReply(op string, errs []error) bool {
+	req := c.takePending(op, "")
+	if req == nil {
+		return false
+	}
+	var failed []string
+	for _, err := range errs {
+		var subErr *SubscriptionError
+		if errors.As(err, &subErr) && subErr.StockCode != "" {
+			failed = append(failed, subErr.StockCode)
+		}
+	}
+	if len(failed) > 0 {
+		c.revertSubscriptions(req.undo, failed)
+	}
+	if req.result == nil {
+		return false
+	}
+	req.result <- errors.Join(errs...)
+	return true
+}
+
+// routeServerError 将错误消息匹配到其指向的未应答请求：请求在等待应答时由调用返回，
+// 否则以 *RequestError 发送到错误通道；无法确定所属请求时原样发送 *ServerError，不改动本地订阅记录
+func (c *Client) routeServerError(serverErr *ServerError, done chan struct{}) {
+	var err error = serverErr
+	if req := c.takeErrorPending(serverErr); req != nil {
+		c.revertSubscriptions(req.undo, nil)
+		reqErr := &RequestError{Op: req.op, StockCodes: req.codes, Err: serverErr}
+		c.logger().Warn("request failed",
+			slog.String("op", req.op),
+			slog.Int("stocks", len(req.codes)),
+			slog.String("kind", string(serverErr.Kind())),
+			slog.Any("error", serverErr))
+		if req.result != nil {
+			req.result <- reqErr
+			return
+		}
+		err = reqErr
+	} else {
+		c.logger().Warn("server error",
+			slog.String("code", serverErr.Code),
+			slog.String("kind", string(serverErr.Kind())),
+			slog.String("message", serverErr.Message))
+	}
+
+	select {
+	case c.errorChan <- err:
+	case <-done:
+	}
+}
@@ -0,0 +1,190 @@
+package dtraderhq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SessionRunner 按交易日历运行客户端：开盘前自动连接、认证并订阅，收盘后断开并执行收尾
+type SessionRunner struct {
+	Calendar      *Calendar
+	Market        Market
+	URL           string
+	Token         string // 为空时由客户端的 TokenProvider 提供
+	Subscriptions []SubscribeMessage
+
+	ConnectBefore time.Duration // 开盘前提前连接的时间，默认5分钟
+	CloseAfter    time.Duration // 收盘后延迟断开的时间，默认1分钟
+	AuthTimeout   time.Duration // 等待认证完成的超时时间，默认10秒
+	RetryInterval time.Duration // 连接失败后的重试间隔，默认10秒
+
+	// NewClient 创建客户端，为空时使用 NewClient(URL)
+	NewClient func() *Client
+	// Handle 在交易时段内消费数据，ctx 在断开连接前取消
+	Handle func(ctx context.Context, client *Client)
+	// Finalize 每个交易日断开连接后调用，day 为交易日零点
+	Finalize func(day time.Time) error
+	// OnError 某个交易日连接失败或收尾出错时调用，之后继续运行下一个交易日；为空时忽略这些错误
+	OnError func(day time.Time, err error)
+
+	// 测试用：now 默认 time.Now，sleep 默认按实际时间等待到指定时刻
+	now   func() time.Time
+	sleep func(ctx context.Context, t time.Time) error
+}
+
+func (r *SessionRunner) durationOr(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// Run 持续按交易日运行，直到 ctx 取消
+//
+// 某个交易日失败（例如一直连接不上）时交给 OnError，跳过该交易日剩余的时间，继续运行下一个交易日。
+func (r *SessionRunner) Run(ctx context.Context) error {
+	if r.Calendar == nil {
+		return errors.New("calendar is nil")
+	}
+
+	from := r.clock()
+	for {
+		openAt, closeAt := r.Calendar.NextSession(r.Market, from)
+		if openAt.IsZero() {
+			return errors.New("no trading day found within one year")
+		}
+
+		err := r.RunSession(ctx, openAt, closeAt)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && r.OnError != nil {
+			r.OnError(startOfDay(openAt.In(ShanghaiLocation)), err)
+		}
+
+		// 从本交易日断开时刻之后查找下一个交易日，避免失败后重复运行同一天
+		from = r.clock()
+		if end := closeAt.Add(r.durationOr(r.CloseAfter, time.Minute)); from.Before(end) {
+			from = end
+		}
+	}
+}
+
+func (r *SessionRunner) clock() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// sleepUntil 等待到指定时刻，ctx 取消时提前返回
+func (r *SessionRunner) sleepUntil(ctx context.Context, t time.Time) error {
+	if r.sleep != nil {
+		return r.sleep(ctx, t)
+	}
+	d := t.Sub(r.clock())
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RunSession 运行单个交易日：在 openAt 前连接，在 closeAt 后断开
+func (r *SessionRunner) RunSession(ctx context.Context, openAt, closeAt time.Time) error {
+	connectAt := openAt.Add(-r.durationOr(r.ConnectBefore, 5*time.Minute))
+	disconnectAt := closeAt.Add(r.durationOr(r.CloseAfter, time.Minute))
+
+	if err := r.sleepUntil(ctx, connectAt); err != nil {
+		return err
+	}
+
+	client, err := r.connect(ctx, disconnectAt)
+	if err != nil {
+		return err
+	}
+
+	sessionCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	if r.Handle != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Handle(sessionCtx, client)
+		}()
+	}
+
+	r.sleepUntil(ctx, disconnectAt)
+	cancel()
+	wg.Wait()
+	client.Close()
+
+	if r.Finalize != nil {
+		if err := r.Finalize(startOfDay(openAt.In(ShanghaiLocation))); err != nil {
+			return fmt.Errorf("finalize session: %w", err)
+		}
+	}
+	return ctx.Err()
+}
+
+// connect 连接、认证并订阅，失败时按 RetryInterval 重试直到 deadline
+func (r *SessionRunner) connect(ctx context.Context, deadline time.Time) (*Client, error) {
+	for {
+		client, err := r.connectOnce(ctx)
+		if err == nil {
+			return client, nil
+		}
+
+		next := r.clock().Add(r.durationOr(r.RetryInterval, 10*time.Second))
+		if next.After(deadline) {
+			return nil, fmt.Errorf("session connect failed: %w", err)
+		}
+		if err := r.sleepUntil(ctx, next); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *SessionRunner) connectOnce(ctx context.Context) (*Client, error) {
+	var client *Client
+	if r.NewClient != nil {
+		client = r.NewClient()
+	} else {
+		client = NewClient(r.URL)
+	}
+
+	if err := client.Connect(); err != nil {
+		// 释放未连接成功的客户端，如注销其运行指标
+		client.Close()
+		return nil, err
+	}
+
+	if err := client.Authenticate(r.Token); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	authCtx, cancel := context.WithTimeout(ctx, r.durationOr(r.AuthTimeout, 10*time.Second))
+	defer cancel()
+	if err := client.WaitAuthenticated(authCtx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("wait authenticated: %w", err)
+	}
+
+	if err := client.SubscribeAll(r.Subscriptions); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
@@ -0,0 +1,113 @@
+package dtraderhq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 供 SessionRunner 使用的时钟，等待时直接前进到目标时刻
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) SleepUntil(ctx context.Context, t time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t.After(f.now) {
+		f.now = t
+	}
+	return nil
+}
+
+// 某个交易日一直连接不上时，Run 应报告错误并继续运行后续交易日
+func TestSessionRunnerContinuesAfterFailedDay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cal := NewCalendar()
+	cal.AddHoliday(cst("2025-06-30 00:00"), "test")
+
+	clock := &fakeClock{now: cst("2025-06-27 09:00")}
+	var days []string
+	attempts := 0
+	runner := &SessionRunner{
+		Calendar:      cal,
+		Market:        MarketSH,
+		RetryInterval: time.Hour,
+		NewClient: func() *Client {
+			attempts++
+			return NewClient("ws://127.0.0.1:1")
+		},
+		OnError: func(day time.Time, err error) {
+			days = append(days, day.Format("2006-01-02"))
+			if len(days) == 3 {
+				cancel()
+			}
+		},
+		now:   clock.Now,
+		sleep: clock.SleepUntil,
+	}
+
+	if err := runner.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run = %v, want context.Canceled", err)
+	}
+	want := []string{"2025-06-27", "2025-07-01", "2025-07-02"}
+	if len(days) != len(want) {
+		t.Fatalf("failed days = %v, want %v", days, want)
+	}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Fatalf("failed days = %v, want %v", days, want)
+		}
+	}
+	// 每个交易日从 9:25 起每小时重试一次，直到 15:01 断开
+	if attempts != 3*6 {
+		t.Fatalf("connect attempts = %d, want %d", attempts, 3*6)
+	}
+}
+
+func TestSessionRunnerRunSession(t *testing.T) {
+	srv := newTestServer(t)
+
+	var handled, finalized bool
+	runner := &SessionRunner{
+		URL:           srv.url(),
+		Token:         "token",
+		Subscriptions: []SubscribeMessage{{StockCode: "SZ000001", DataTypes: []int{DataTypeTransaction}}},
+		ConnectBefore: time.Millisecond,
+		CloseAfter:    time.Millisecond,
+		Handle: func(ctx context.Context, client *Client) {
+			handled = client.IsAuthenticated()
+			<-ctx.Done()
+		},
+		Finalize: func(day time.Time) error {
+			finalized = true
+			return errors.New("flush failed")
+		},
+	}
+
+	openAt := time.Now()
+	err := runner.RunSession(context.Background(), openAt, openAt.Add(100*time.Millisecond))
+	if err == nil || err.Error() != "finalize session: flush failed" {
+		t.Fatalf("RunSession = %v", err)
+	}
+	if !handled || !finalized {
+		t.Fatalf("handled = %v, finalized = %v", handled, finalized)
+	}
+	if n := len(srv.messages(MessageTypeBatchSubscribe)); n != 1 {
+		t.Fatalf("batch_subscribe messages = %d, want 1", n)
+	}
+}
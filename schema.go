@@ -0,0 +1,217 @@
+package dtraderhq
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// SchemaMode 发现字段变化时的处理方式
+type SchemaMode int
+
+const (
+	// SchemaLenient 报告字段变化，数据帧照常投递（默认）
+	SchemaLenient SchemaMode = iota
+	// SchemaStrict 报告字段变化，字段与定义不一致的数据帧不再投递
+	SchemaStrict
+)
+
+// ParseSchemaMode 解析字段检查方式名称：lenient 或 strict
+func ParseSchemaMode(s string) (SchemaMode, error) {
+	switch s {
+	case "", "lenient":
+		return SchemaLenient, nil
+	case "strict":
+		return SchemaStrict, nil
+	}
+	return 0, fmt.Errorf("unknown schema mode %q", s)
+}
+
+// SchemaDrift 数据帧字段与类型定义的差异
+type SchemaDrift struct {
+	DataType  DataType
+	StockCode string   // 发现差异的股票
+	Unknown   []string // 定义中没有的字段
+	Missing   []string // 定义中有、本会话同一市场的数据中出现过但本帧缺少的字段
+}
+
+// String 返回差异的文字描述
+func (d SchemaDrift) String() string {
+	var parts []string
+	if len(d.Unknown) > 0 {
+		parts = append(parts, "unknown fields "+strings.Join(d.Unknown, ","))
+	}
+	if len(d.Missing) > 0 {
+		parts = append(parts, "missing fields "+strings.Join(d.Missing, ","))
+	}
+	return fmt.Sprintf("data type %d (%s): %s", d.DataType, d.StockCode, strings.Join(parts, "; "))
+}
+
+// SchemaDriftError 字段变化，errors.Is(err, ErrSchemaDrift) 为真
+type SchemaDriftError struct {
+	SchemaDrift
+	Strict bool // 严格模式下该数据类型字段不一致的数据帧不再投递
+}
+
+// Error 实现 error 接口
+func (e *SchemaDriftError) Error() string {
+	if e.Strict {
+		return fmt.Sprintf("%v: %s, frames rejected", ErrSchemaDrift, e.SchemaDrift)
+	}
+	return fmt.Sprintf("%v: %s", ErrSchemaDrift, e.SchemaDrift)
+}
+
+// Unwrap 返回 ErrSchemaDrift
+func (e *SchemaDriftError) Unwrap() error {
+	return ErrSchemaDrift
+}
+
+// schemaFields 各开放数据类型的字段定义，取自记录结构体的 json 标签
+var schemaFields = map[DataType]map[string]bool{
+	DataTypeTransaction: jsonFields(Transaction{}),
+	DataTypeBigOrder:    jsonFields(BigOrder{}),
+	DataTypeZBWT:        jsonFields(ZBWT{}),
+}
+
+func jsonFields(v any) map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		if name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// SchemaChecker 检查数据帧的字段是否与 Transaction、BigOrder、ZBWT 的定义一致，
+// 每个字段的差异在一次会话（到下次 Reset 为止）中只报告一次
+//
+// 沪深两市推送的字段不完全相同，定义中的字段只有在本会话同一市场、同一数据类型的数据中出现过之后，
+// 再缺少时才作为差异报告；定义中没有的字段总是报告。
+type SchemaChecker struct {
+	mu       sync.Mutex
+	reported map[string]bool // "类型/unknown|missing/字段"
+	seen     map[string]bool // "类型/市场/字段"，本会话出现过的定义字段
+}
+
+// NewSchemaChecker 创建字段检查器
+func NewSchemaChecker() *SchemaChecker {
+	return &SchemaChecker{reported: make(map[string]bool), seen: make(map[string]bool)}
+}
+
+// Check 检查数据帧，返回本帧的字段差异和其中本会话首次出现的部分，没有时分别为 nil
+//
+// 未开放的数据类型不检查。
+func (s *SchemaChecker) Check(md *MarketData) (drift, first *SchemaDrift) {
+	expected, ok := schemaFields[md.DataType]
+	if !ok {
+		return nil, nil
+	}
+
+	var records []map[string]interface{}
+	switch v := md.Data.(type) {
+	case map[string]interface{}:
+		records = append(records, v)
+	case []interface{}:
+		for _, item := range v {
+			if record, ok := item.(map[string]interface{}); ok {
+				records = append(records, record)
+			}
+		}
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	market := MarketOf(md.StockCode)
+	unknown := make(map[string]bool)
+	missing := make(map[string]bool)
+	for _, record := range records {
+		for field := range expected {
+			key := fmt.Sprintf("%d/%s/%s", md.DataType, market, field)
+			if _, ok := record[field]; ok {
+				s.seen[key] = true
+			} else if s.seen[key] {
+				missing[field] = true
+			}
+		}
+		for field := range record {
+			if !expected[field] {
+				unknown[field] = true
+			}
+		}
+	}
+	if len(unknown) == 0 && len(missing) == 0 {
+		return nil, nil
+	}
+
+	drift = &SchemaDrift{DataType: md.DataType, StockCode: md.StockCode, Unknown: sortedKeys(unknown), Missing: sortedKeys(missing)}
+	fresh := SchemaDrift{DataType: md.DataType, StockCode: md.StockCode}
+	for _, field := range drift.Unknown {
+		if key := fmt.Sprintf("%d/unknown/%s", md.DataType, field); !s.reported[key] {
+			s.reported[key] = true
+			fresh.Unknown = append(fresh.Unknown, field)
+		}
+	}
+	for _, field := range drift.Missing {
+		if key := fmt.Sprintf("%d/missing/%s", md.DataType, field); !s.reported[key] {
+			s.reported[key] = true
+			fresh.Missing = append(fresh.Missing, field)
+		}
+	}
+	if len(fresh.Unknown) == 0 && len(fresh.Missing) == 0 {
+		return drift, nil
+	}
+	return drift, &fresh
+}
+
+// Reset 开始新的会话，之后再次发现的差异会重新报告
+func (s *SchemaChecker) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reported = make(map[string]bool)
+	s.seen = make(map[string]bool)
+}
+
+// WithSchemaCheck 检查收到的数据帧字段，差异以 *SchemaDriftError 发送到 ErrorChannel，
+// 每次认证成功后对每个字段的差异只报告一次；严格模式下字段不一致的数据帧不再投递
+func WithSchemaCheck(mode SchemaMode) Option {
+	return func(c *Client) {
+		c.schema = NewSchemaChecker()
+		c.schemaMode = mode
+	}
+}
+
+// checkSchema 检查数据帧字段并报告首次出现的差异，返回数据帧是否可以投递
+func (c *Client) checkSchema(md *MarketData, done chan struct{}) bool {
+	drift, first := c.schema.Check(md)
+	strict := c.schemaMode == SchemaStrict
+	if first != nil {
+		c.metrics.observeSchemaDrift(first)
+		c.logger().Warn("schema drift",
+			slog.String("stock", first.StockCode),
+			slog.Int("data_type", first.DataType),
+			slog.Any("unknown", first.Unknown),
+			slog.Any("missing", first.Missing),
+			slog.Bool("strict", strict))
+		select {
+		case c.errorChan <- &SchemaDriftError{SchemaDrift: *first, Strict: strict}:
+		case <-done:
+		}
+	}
+	if drift != nil && strict {
+		c.metrics.observeRejected(md.DataType)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,206 @@
+package dtraderhq
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchemaCheckerCheck(t *testing.T) {
+	s := NewSchemaChecker()
+	frame := func(records ...map[string]any) *MarketData {
+		data := make([]any, len(records))
+		for i, r := range records {
+			data[i] = r
+		}
+		return &MarketData{StockCode: "SZ000001", DataType: int(DataTypeTransaction), Data: data}
+	}
+	full := map[string]any{"OrderPackId": 1.0, "Price": 1.0, "Time": 1.0, "Volume": 1.0}
+	renamed := map[string]any{"PackId": 1.0, "Price": 1.0, "Time": 1.0, "Volume": 1.0}
+
+	if drift, first := s.Check(frame(full)); drift != nil || first != nil {
+		t.Fatalf("matching frame = %v, %v", drift, first)
+	}
+	drift, first := s.Check(frame(full, renamed))
+	want := &SchemaDrift{DataType: DataTypeTransaction, StockCode: "SZ000001", Unknown: []string{"PackId"}, Missing: []string{"OrderPackId"}}
+	if !reflect.DeepEqual(drift, want) || !reflect.DeepEqual(first, want) {
+		t.Fatalf("drift = %v, first = %v", drift, first)
+	}
+	// 已报告过的差异只在 drift 中返回
+	if drift, first = s.Check(frame(renamed)); drift == nil || first != nil {
+		t.Fatalf("repeated drift = %v, first = %v", drift, first)
+	}
+	s.Reset()
+	if _, first = s.Check(frame(renamed)); first == nil {
+		t.Fatal("drift not reported after Reset")
+	}
+
+	// 未开放的数据类型不检查
+	if drift, _ := s.Check(&MarketData{DataType: 1, Data: []any{map[string]any{"x": 1.0}}}); drift != nil {
+		t.Fatalf("closed data type checked: %v", drift)
+	}
+	if got := want.String(); got != "data type 4 (SZ000001): unknown fields PackId; missing fields OrderPackId" {
+		t.Fatalf("String() = %q", got)
+	}
+}
+
+// 定义中的字段在同一市场出现过之后才按缺少报告
+func TestSchemaCheckerMissingAfterSeen(t *testing.T) {
+	s := NewSchemaChecker()
+	frame := func(stockCode string, record map[string]any) *MarketData {
+		return &MarketData{StockCode: stockCode, DataType: int(DataTypeZBWT), Data: []any{record}}
+	}
+	full := map[string]any{"Index": 1.0, "DateTime": 1.0, "Price": 1.0, "Volume": 1.0, "Type": []any{83.0, 65.0}}
+	noIndex := map[string]any{"DateTime": 1.0, "Price": 1.0, "Volume": 1.0, "Type": []any{83.0, 65.0}}
+
+	if drift, _ := s.Check(frame("SH600000", full)); drift != nil {
+		t.Fatalf("full frame = %v", drift)
+	}
+	// 深市没有出现过 Index，缺少时不是差异
+	if drift, _ := s.Check(frame("SZ000001", noIndex)); drift != nil {
+		t.Fatalf("field never seen in SZ reported missing: %v", drift)
+	}
+	drift, _ := s.Check(frame("SH600000", noIndex))
+	if want := []string{"Index"}; drift == nil || !reflect.DeepEqual(drift.Missing, want) {
+		t.Fatalf("drift = %v, want missing %v", drift, want)
+	}
+	s.Reset()
+	if drift, _ := s.Check(frame("SH600000", noIndex)); drift != nil {
+		t.Fatalf("seen fields kept after Reset: %v", drift)
+	}
+}
+
+func TestParseSchemaMode(t *testing.T) {
+	for s, want := range map[string]SchemaMode{"": SchemaLenient, "lenient": SchemaLenient, "strict": SchemaStrict} {
+		if got, err := ParseSchemaMode(s); err != nil || got != want {
+			t.Errorf("ParseSchemaMode(%q) = %v, %v", s, got, err)
+		}
+	}
+	if _, err := ParseSchemaMode("loose"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func driftFrame() map[string]any {
+	return map[string]any{"type": MessageTypeData, "data": map[string]any{
+		"stock_code": "SZ000001",
+		"data_type":  4,
+		"data":       []any{map[string]any{"PackId": 1, "Price": 1000, "Time": 1, "Volume": 100}},
+	}}
+}
+
+func nextSchemaDrift(t *testing.T, c *Client) *SchemaDriftError {
+	t.Helper()
+	for {
+		select {
+		case err := <-c.ErrorChannel():
+			var driftErr *SchemaDriftError
+			if errors.As(err, &driftErr) {
+				return driftErr
+			}
+		case <-time.After(200 * time.Millisecond):
+			return nil
+		}
+	}
+}
+
+// 字段差异在每次认证成功后重新报告一次
+func TestSchemaDriftReportedOncePerSession(t *testing.T) {
+	srv := newTestServer(t)
+	c := connectTestClient(t, srv, WithSchemaCheck(SchemaLenient))
+
+	srv.broadcast(driftFrame())
+	srv.broadcast(driftFrame())
+	if err := nextSchemaDrift(t, c); err == nil || err.Strict || !errors.Is(err, ErrSchemaDrift) {
+		t.Fatalf("first drift = %v", err)
+	}
+	if err := nextSchemaDrift(t, c); err != nil {
+		t.Fatalf("drift reported twice: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-c.DataChannel():
+		case <-time.After(3 * time.Second):
+			t.Fatal("lenient mode did not deliver the frame")
+		}
+	}
+
+	// 重新认证开始新的会话
+	if err := c.Authenticate("token"); err != nil {
+		t.Fatal(err)
+	}
+	// 服务器按顺序回复，收到 pong 时认证应答已经处理
+	if _, err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	srv.broadcast(driftFrame())
+	if err := nextSchemaDrift(t, c); err == nil {
+		t.Fatal("drift not reported after re-authentication")
+	}
+}
+
+func TestSchemaStrictRejectsFrames(t *testing.T) {
+	srv := newTestServer(t)
+	c := connectTestClient(t, srv, WithSchemaCheck(SchemaStrict))
+	srv.broadcast(driftFrame())
+	if err := nextSchemaDrift(t, c); err == nil || !err.Strict {
+		t.Fatalf("drift = %v", err)
+	}
+	select {
+	case md := <-c.DataChannel():
+		t.Fatalf("strict mode delivered %v", md)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// sampleLines 读取 examples 中录制的数据帧的前 n 行原始 JSON
+func sampleLines(t *testing.T, name string, n int) []json.RawMessage {
+	t.Helper()
+	f, err := os.Open(filepath.Join(sampleDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var lines []json.RawMessage
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for len(lines) < n && sc.Scan() {
+		lines = append(lines, json.RawMessage(append([]byte(nil), sc.Bytes()...)))
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+// 严格模式下录制的沪深两市数据帧都不应被拒绝
+func TestSchemaStrictAcceptsSamples(t *testing.T) {
+	srv := newTestServer(t)
+	c := connectTestClient(t, srv, WithSchemaCheck(SchemaStrict))
+
+	sent := 0
+	for _, stock := range []string{"SH603065", "SZ002062"} {
+		for _, kind := range []string{"transaction", "detail", "order"} {
+			for _, line := range sampleLines(t, stock+"_"+kind+"_20250627.json", 10) {
+				srv.broadcast(map[string]any{"type": MessageTypeData, "data": line})
+				sent++
+			}
+		}
+	}
+	for i := 0; i < sent; i++ {
+		select {
+		case <-c.DataChannel():
+		case err := <-c.ErrorChannel():
+			t.Fatalf("frame %d: %v", i, err)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("received %d of %d frames", i, sent)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package dtraderhq
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testServer 模拟行情服务器：默认对认证回复成功、对 ping 回复 pong，handle 返回 true 时跳过默认处理
+type testServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	writeMu  sync.Mutex
+	conns    []*websocket.Conn
+	received []map[string]any
+	handle   func(conn *websocket.Conn, msg map[string]any) bool
+	subs     map[string][]int // ackRequests 接受的订阅
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	s := &testServer{}
+	upgrader := websocket.Upgrader{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		for {
+			_, b, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg map[string]any
+			if err := json.Unmarshal(b, &msg); err != nil {
+				continue
+			}
+			s.mu.Lock()
+			s.received = append(s.received, msg)
+			handle := s.handle
+			s.mu.Unlock()
+
+			if handle != nil && handle(conn, msg) {
+				continue
+			}
+			switch msg["type"] {
+			case MessageTypePing:
+				s.write(conn, map[string]any{"type": MessageTypePong})
+			case MessageTypeAuth:
+				s.write(conn, map[string]any{"type": MessageTypeAuth, "data": map[string]any{"message": "认证成功"}})
+			}
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) url() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func (s *testServer) setHandler(h func(conn *websocket.Conn, msg map[string]any) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handle = h
+}
+
+// write 向连接发送 JSON 消息，可在 handle 中调用
+func (s *testServer) write(conn *websocket.Conn, v any) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	conn.WriteJSON(v)
+}
+
+// broadcast 向全部连接发送 JSON 消息
+func (s *testServer) broadcast(v any) {
+	s.mu.Lock()
+	conns := append([]*websocket.Conn(nil), s.conns...)
+	s.mu.Unlock()
+	for _, conn := range conns {
+		s.write(conn, v)
+	}
+}
+
+// dropConnections 断开全部连接，模拟网络中断
+func (s *testServer) dropConnections() {
+	s.mu.Lock()
+	conns := s.conns
+	s.conns = nil
+	s.mu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// dropConnection 断开按建立顺序排在第 i 个的连接
+func (s *testServer) dropConnection(i int) {
+	s.mu.Lock()
+	conn := s.conns[i]
+	s.conns = append(s.conns[:i:i], s.conns[i+1:]...)
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// messages 返回收到的指定类型的消息
+func (s *testServer) messages(msgType string) []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []map[string]any
+	for _, msg := range s.received {
+		if msg["type"] == msgType {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// waitFor 等待条件成立，超时时测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// connectTestClient 连接测试服务器并完成认证
+func connectTestClient(t *testing.T, srv *testServer, opts ...Option) *Client {
+	t.Helper()
+	c := NewClient(srv.url(), opts...)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.Authenticate("token"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := c.WaitAuthenticated(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// ackRequests 对订阅类请求回复同类型的应答；reject 返回非空时改为回复该错误信息
+//
+// 接受的请求按服务器的语义记录在 subscriptions 中：订阅请求与股票已有的数据类型合并，
+// 取消订阅删除整只股票，重置替换全部订阅。
+func (s *testServer) ackRequests(reject func(msg map[string]any) string) {
+	s.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		switch msg["type"] {
+		case MessageTypeSubscribe, MessageTypeBatchSubscribe, MessageTypeUnsubscribe, MessageTypeBatchUnsubscribe, MessageTypeReset:
+		default:
+			return false
+		}
+		if reject != nil {
+			if reason := reject(msg); reason != "" {
+				s.write(conn, map[string]any{"type": MessageTypeError, "error": reason, "data": map[string]any{"code": "INVALID_REQUEST", "op": msg["type"]}})
+				return true
+			}
+		}
+		s.apply(msg)
+		s.write(conn, map[string]any{"type": msg["type"], "data": map[string]any{"message": "ok"}})
+		return true
+	})
+}
+
+func (s *testServer) apply(msg map[string]any) {
+	data, _ := msg["data"].(map[string]any)
+	var subs []any
+	switch msg["type"] {
+	case MessageTypeSubscribe:
+		subs = []any{data}
+	case MessageTypeBatchSubscribe, MessageTypeReset:
+		subs, _ = data["subscriptions"].([]any)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subs == nil || msg["type"] == MessageTypeReset {
+		s.subs = make(map[string][]int)
+	}
+	if msg["type"] == MessageTypeUnsubscribe || msg["type"] == MessageTypeBatchUnsubscribe {
+		for _, code := range requestCodes(msg) {
+			delete(s.subs, code)
+		}
+		return
+	}
+	for _, item := range subs {
+		sub, _ := item.(map[string]any)
+		code, _ := sub["stock_code"].(string)
+		types, _ := sub["data_types"].([]any)
+		for _, t := range types {
+			if dt := int(t.(float64)); !containsTypes(s.subs[code], []int{dt}) {
+				s.subs[code] = append(s.subs[code], dt)
+			}
+		}
+	}
+}
+
+// checkSubscriptions 等待服务器端的订阅与客户端的本地记录一致，数据类型按集合比较
+func (s *testServer) checkSubscriptions(t *testing.T, c *Client) {
+	t.Helper()
+	local := c.GetSubscriptions()
+	deadline := time.Now().Add(3 * time.Second)
+	for !s.hasSubscriptions(local) {
+		if time.Now().After(deadline) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			t.Fatalf("server subscriptions %v, local %v", s.subs, local)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (s *testServer) hasSubscriptions(want map[string][]int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(want) != len(s.subs) {
+		return false
+	}
+	for code, types := range want {
+		if !sameTypes(types, s.subs[code]) {
+			return false
+		}
+	}
+	return true
+}
+
+// requestCodes 返回订阅类请求涉及的股票代码
+func requestCodes(msg map[string]any) []string {
+	data, _ := msg["data"].(map[string]any)
+	var codes []string
+	if code, ok := data["stock_code"].(string); ok {
+		codes = append(codes, code)
+	}
+	if list, ok := data["subscriptions"].([]any); ok {
+		for _, item := range list {
+			sub, _ := item.(map[string]any)
+			code, _ := sub["stock_code"].(string)
+			codes = append(codes, code)
+		}
+	}
+	if list, ok := data["stock_codes"].([]any); ok {
+		for _, item := range list {
+			code, _ := item.(string)
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
@@ -0,0 +1,553 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"time"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+)
+
+// 二进制格式
+//
+// 文件以 8 字节文件头开始（"DTHQBIN" + 版本号），随后是若干数据块，每个数据块对应一帧数据：
+//
+//	4 字节同步标记 | uvarint 块长度 | 块内容 | CRC32(IEEE, 小端, 仅覆盖块内容)
+//
+// 块内容：
+//
+//	byte    标志位（bit0: 含接收时间, bit1: 原始 JSON）
+//	uvarint 数据类型
+//	uvarint 股票代码长度 | 股票代码
+//	varint  帧时间戳（秒）
+//	varint  接收时间相对帧时间戳的毫秒差（仅当 bit0 置位）
+//	uvarint 记录数（原始 JSON 时为字节数）| 记录
+//
+// 逐笔成交、逐笔大单、逐笔委托按字段与块内上一条记录做差分，再以 zigzag varint 编码；
+// 其他数据类型以原始 JSON 保存。各数据块相互独立，可单独校验；
+// 写入中断留下的残缺块或损坏的块由读取器跳过，从下一个同步标记处继续读取。
+const (
+	binaryMagic   = "DTHQBIN"
+	binaryVersion = 1
+
+	flagReceivedAt = 1 << 0
+	flagRawJSON    = 1 << 1
+
+	maxBlockSize = 64 << 20
+)
+
+// blockMarker 数据块同步标记，首字节不在后续字节中重复出现，便于逐字节查找
+var blockMarker = [4]byte{0xD7, 'D', 'T', 'B'}
+
+// errCorruptBlock 数据块长度或校验不合法，读取器跳过该块
+var errCorruptBlock = errors.New("store: corrupt block")
+
+// ErrBadHeader 文件头不合法
+var ErrBadHeader = errors.New("store: invalid binary file header")
+
+// BinaryWriter 二进制格式写入器
+type BinaryWriter struct {
+	w   io.Writer
+	bw  *bufio.Writer
+	buf []byte
+}
+
+// NewBinaryWriter 创建二进制写入器
+//
+// w 为已有内容的文件（追加模式）时不重复写入文件头；Close 时会关闭实现了 io.Closer 的 w。
+func NewBinaryWriter(w io.Writer) (*BinaryWriter, error) {
+	bw := &BinaryWriter{w: w, bw: bufio.NewWriter(w)}
+
+	needHeader := true
+	if f, ok := w.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+			needHeader = false
+		}
+	}
+
+	if needHeader {
+		header := append([]byte(binaryMagic), binaryVersion)
+		if _, err := bw.bw.Write(header); err != nil {
+			return nil, fmt.Errorf("write header: %w", err)
+		}
+	}
+	return bw, nil
+}
+
+// Write 编码并写入一帧数据
+func (bw *BinaryWriter) Write(md *dtraderhq.MarketData) error {
+	frame, err := dtraderhq.DecodeFrame(md)
+	if err != nil {
+		return err
+	}
+
+	b := bw.buf[:0]
+
+	var flags byte
+	if !md.ReceivedAt.IsZero() {
+		flags |= flagReceivedAt
+	}
+	typed := md.DataType == dtraderhq.DataTypeTransaction ||
+		md.DataType == dtraderhq.DataTypeBigOrder ||
+		md.DataType == dtraderhq.DataTypeZBWT
+	if !typed {
+		flags |= flagRawJSON
+	}
+
+	if md.DataType < 0 {
+		return fmt.Errorf("store: invalid data type %d", md.DataType)
+	}
+	b = append(b, flags)
+	b = binary.AppendUvarint(b, uint64(md.DataType))
+	b = binary.AppendUvarint(b, uint64(len(md.StockCode)))
+	b = append(b, md.StockCode...)
+	b = binary.AppendVarint(b, md.Timestamp)
+	if flags&flagReceivedAt != 0 {
+		b = binary.AppendVarint(b, md.ReceivedAt.UnixMilli()-md.Timestamp*1000)
+	}
+
+	switch {
+	case !typed:
+		raw, err := json.Marshal(md.Data)
+		if err != nil {
+			return fmt.Errorf("marshal data: %w", err)
+		}
+		b = binary.AppendUvarint(b, uint64(len(raw)))
+		b = append(b, raw...)
+	case md.DataType == dtraderhq.DataTypeTransaction:
+		b = appendTransactions(b, frame.Transactions)
+	case md.DataType == dtraderhq.DataTypeBigOrder:
+		b = appendBigOrders(b, frame.BigOrders)
+	case md.DataType == dtraderhq.DataTypeZBWT:
+		b = appendZBWT(b, frame.Orders)
+	}
+
+	var head [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(head[:], uint64(len(b)))
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], crc32.ChecksumIEEE(b))
+
+	if _, err := bw.bw.Write(blockMarker[:]); err != nil {
+		return err
+	}
+	if _, err := bw.bw.Write(head[:n]); err != nil {
+		return err
+	}
+	if _, err := bw.bw.Write(b); err != nil {
+		return err
+	}
+	if _, err := bw.bw.Write(sum[:]); err != nil {
+		return err
+	}
+
+	bw.buf = b
+	return nil
+}
+
+func appendTransactions(b []byte, records []dtraderhq.Transaction) []byte {
+	b = binary.AppendUvarint(b, uint64(len(records)))
+	var prev dtraderhq.Transaction
+	for _, t := range records {
+		b = binary.AppendVarint(b, t.OrderPackId-prev.OrderPackId)
+		b = binary.AppendVarint(b, t.Price-prev.Price)
+		b = binary.AppendVarint(b, t.Time-prev.Time)
+		b = binary.AppendVarint(b, t.Volume)
+		prev = t
+	}
+	return b
+}
+
+func appendBigOrders(b []byte, records []dtraderhq.BigOrder) []byte {
+	b = binary.AppendUvarint(b, uint64(len(records)))
+	var prev dtraderhq.BigOrder
+	for _, o := range records {
+		b = binary.AppendVarint(b, o.OrderPackId-prev.OrderPackId)
+		b = binary.AppendVarint(b, o.BuyOrderPackId-o.OrderPackId)
+		b = binary.AppendVarint(b, o.SellOrderPackId-o.OrderPackId)
+		b = binary.AppendVarint(b, int64(o.BuyFlag))
+		b = binary.AppendVarint(b, int64(o.SellFlag))
+		b = binary.AppendVarint(b, o.BuyPrice-prev.BuyPrice)
+		b = binary.AppendVarint(b, o.SellPrice-o.BuyPrice)
+		b = binary.AppendVarint(b, o.BuyVol)
+		b = binary.AppendVarint(b, o.SellVol)
+		prev = o
+	}
+	return b
+}
+
+func appendZBWT(b []byte, records []dtraderhq.ZBWT) []byte {
+	b = binary.AppendUvarint(b, uint64(len(records)))
+	var prev dtraderhq.ZBWT
+	for _, z := range records {
+		b = binary.AppendVarint(b, z.Index-prev.Index)
+		b = binary.AppendVarint(b, z.DateTime-prev.DateTime)
+		b = binary.AppendVarint(b, z.Price-prev.Price)
+		b = binary.AppendVarint(b, z.Volume)
+		b = append(b, z.Type[0], z.Type[1])
+		prev = z
+	}
+	return b
+}
+
+// Flush 刷新缓冲
+func (bw *BinaryWriter) Flush() error {
+	return bw.bw.Flush()
+}
+
+// Close 刷新缓冲并关闭底层文件
+func (bw *BinaryWriter) Close() error {
+	if err := bw.bw.Flush(); err != nil {
+		return err
+	}
+	if c, ok := bw.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// BinaryReader 二进制格式读取器，按帧迭代
+//
+//	r, err := store.NewBinaryReader(f)
+//	for r.Next() {
+//	    frame := r.Frame()
+//	}
+//	if err := r.Err(); err != nil { ... }
+//
+// 长度或校验不合法的数据块（例如写入中断留下的残缺块）被跳过，
+// 读取器从下一个同步标记处继续，跳过的损坏区域数由 Corrupted 返回。
+type BinaryReader struct {
+	src       blockSource
+	frame     *dtraderhq.DecodedFrame
+	err       error
+	buf       []byte
+	corrupted int
+}
+
+// NewBinaryReader 创建二进制读取器并校验文件头
+func NewBinaryReader(r io.Reader) (*BinaryReader, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(binaryMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if string(header[:len(binaryMagic)]) != binaryMagic {
+		return nil, ErrBadHeader
+	}
+	if header[len(binaryMagic)] != binaryVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrBadHeader, header[len(binaryMagic)])
+	}
+
+	return &BinaryReader{src: blockSource{br: br}}, nil
+}
+
+// Next 读取下一帧，到达文件末尾或出错时返回 false
+func (r *BinaryReader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+
+	payload, err := r.readBlock()
+	if err != nil {
+		if err != io.EOF {
+			r.err = fmt.Errorf("read block: %w", err)
+		}
+		return false
+	}
+
+	frame, err := decodeBlock(payload)
+	if err != nil {
+		r.err = err
+		return false
+	}
+	r.frame = frame
+	return true
+}
+
+// readBlock 读取下一个校验通过的数据块内容，块损坏时退回标记之后读到的字节，从中查找下一个标记
+func (r *BinaryReader) readBlock() ([]byte, error) {
+	for {
+		skipped, err := r.src.seekMarker()
+		if skipped > 0 {
+			r.corrupted++
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		taken := append(r.buf[:0], blockMarker[1:]...)
+		size, taken, err := r.src.readUvarint(taken)
+		if err == nil && size > maxBlockSize {
+			err = errCorruptBlock
+		}
+		if err == nil {
+			start := len(taken)
+			taken = slices.Grow(taken, int(size)+4)[:start+int(size)+4]
+			var n int
+			n, err = io.ReadFull(&r.src, taken[start:])
+			taken = taken[:start+n]
+			if err == nil {
+				payload, sum := taken[start:start+int(size)], taken[start+int(size):]
+				if crc32.ChecksumIEEE(payload) == binary.LittleEndian.Uint32(sum) {
+					r.buf = taken
+					return payload, nil
+				}
+				err = errCorruptBlock
+			}
+		}
+		r.buf = taken
+
+		if err != errCorruptBlock && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		r.src.unread(taken)
+	}
+}
+
+// Corrupted 返回已跳过的损坏区域数
+func (r *BinaryReader) Corrupted() int {
+	return r.corrupted
+}
+
+// Frame 返回当前帧，MarketData.Data 为对应的类型化记录切片
+func (r *BinaryReader) Frame() *dtraderhq.DecodedFrame {
+	return r.frame
+}
+
+// Err 返回迭代过程中的错误，正常结束时为 nil
+func (r *BinaryReader) Err() error {
+	return r.err
+}
+
+// blockSource 带退回缓冲的字节来源，退回的字节先于底层读取器读出
+type blockSource struct {
+	br      *bufio.Reader
+	pending []byte
+}
+
+func (s *blockSource) Read(p []byte) (int, error) {
+	if len(s.pending) > 0 {
+		n := copy(p, s.pending)
+		s.pending = s.pending[n:]
+		return n, nil
+	}
+	return s.br.Read(p)
+}
+
+func (s *blockSource) ReadByte() (byte, error) {
+	if len(s.pending) > 0 {
+		b := s.pending[0]
+		s.pending = s.pending[1:]
+		return b, nil
+	}
+	return s.br.ReadByte()
+}
+
+func (s *blockSource) unread(b []byte) {
+	s.pending = append(append([]byte(nil), b...), s.pending...)
+}
+
+// seekMarker 读到下一个同步标记之后，返回标记前跳过的字节数，没有更多标记时返回 io.EOF
+func (s *blockSource) seekMarker() (skipped int, err error) {
+	matched := 0
+	for matched < len(blockMarker) {
+		b, err := s.ReadByte()
+		if err != nil {
+			return skipped + matched, err
+		}
+		switch {
+		case b == blockMarker[matched]:
+			matched++
+		case b == blockMarker[0]:
+			skipped += matched
+			matched = 1
+		default:
+			skipped += matched + 1
+			matched = 0
+		}
+	}
+	return skipped, nil
+}
+
+// readUvarint 读取 uvarint，读到的字节追加到 taken
+func (s *blockSource) readUvarint(taken []byte) (uint64, []byte, error) {
+	start := len(taken)
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		b, err := s.ReadByte()
+		if err != nil {
+			return 0, taken, err
+		}
+		taken = append(taken, b)
+		if b < 0x80 {
+			v, n := binary.Uvarint(taken[start:])
+			if n <= 0 {
+				return 0, taken, errCorruptBlock
+			}
+			return v, taken, nil
+		}
+	}
+	return 0, taken, errCorruptBlock
+}
+
+// blockDecoder 逐字段解析块内容
+type blockDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *blockDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = errors.New("store: corrupt varint")
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *blockDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errors.New("store: corrupt uvarint")
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *blockDecoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.b) {
+		d.err = errors.New("store: truncated block")
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+// count 读取记录数，每条记录至少 minSize 字节
+func (d *blockDecoder) count(minSize int) int {
+	n := d.uvarint()
+	if n > uint64(len(d.b)/minSize) {
+		d.err = errors.New("store: record count exceeds block size")
+		return 0
+	}
+	return int(n)
+}
+
+func decodeBlock(payload []byte) (*dtraderhq.DecodedFrame, error) {
+	d := &blockDecoder{b: payload}
+
+	head := d.bytes(1)
+	dataType := d.uvarint()
+	if d.err == nil && dataType > math.MaxInt32 {
+		d.err = fmt.Errorf("store: invalid data type %d", dataType)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	flags := head[0]
+
+	md := &dtraderhq.MarketData{DataType: int(dataType)}
+	md.StockCode = string(d.bytes(int(d.uvarint())))
+	md.Timestamp = d.varint()
+	if flags&flagReceivedAt != 0 {
+		md.ReceivedAt = time.UnixMilli(md.Timestamp*1000 + d.varint())
+	}
+
+	frame := &dtraderhq.DecodedFrame{MarketData: md}
+	switch {
+	case flags&flagRawJSON != 0:
+		raw := d.bytes(int(d.uvarint()))
+		if d.err == nil {
+			md.Data = json.RawMessage(append([]byte(nil), raw...))
+		}
+	case md.DataType == dtraderhq.DataTypeTransaction:
+		frame.Transactions = decodeTransactions(d)
+		md.Data = frame.Transactions
+	case md.DataType == dtraderhq.DataTypeBigOrder:
+		frame.BigOrders = decodeBigOrders(d)
+		md.Data = frame.BigOrders
+	case md.DataType == dtraderhq.DataTypeZBWT:
+		frame.Orders = decodeZBWT(d)
+		md.Data = frame.Orders
+	default:
+		return nil, fmt.Errorf("store: unknown typed data type %d", dataType)
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return frame, nil
+}
+
+func decodeTransactions(d *blockDecoder) []dtraderhq.Transaction {
+	records := make([]dtraderhq.Transaction, d.count(4))
+	var prev dtraderhq.Transaction
+	for i := range records {
+		t := dtraderhq.Transaction{
+			OrderPackId: prev.OrderPackId + d.varint(),
+			Price:       prev.Price + d.varint(),
+			Time:        prev.Time + d.varint(),
+			Volume:      d.varint(),
+		}
+		records[i] = t
+		prev = t
+	}
+	return records
+}
+
+func decodeBigOrders(d *blockDecoder) []dtraderhq.BigOrder {
+	records := make([]dtraderhq.BigOrder, d.count(9))
+	var prev dtraderhq.BigOrder
+	for i := range records {
+		var o dtraderhq.BigOrder
+		o.OrderPackId = prev.OrderPackId + d.varint()
+		o.BuyOrderPackId = o.OrderPackId + d.varint()
+		o.SellOrderPackId = o.OrderPackId + d.varint()
+		o.BuyFlag = int(d.varint())
+		o.SellFlag = int(d.varint())
+		o.BuyPrice = prev.BuyPrice + d.varint()
+		o.SellPrice = o.BuyPrice + d.varint()
+		o.BuyVol = d.varint()
+		o.SellVol = d.varint()
+		records[i] = o
+		prev = o
+	}
+	return records
+}
+
+func decodeZBWT(d *blockDecoder) []dtraderhq.ZBWT {
+	records := make([]dtraderhq.ZBWT, d.count(6))
+	var prev dtraderhq.ZBWT
+	for i := range records {
+		var z dtraderhq.ZBWT
+		z.Index = prev.Index + d.varint()
+		z.DateTime = prev.DateTime + d.varint()
+		z.Price = prev.Price + d.varint()
+		z.Volume = d.varint()
+		if t := d.bytes(2); t != nil {
+			z.Type = dtraderhq.OrderType{t[0], t[1]}
+		}
+		records[i] = z
+		prev = z
+	}
+	return records
+}
@@ -0,0 +1,221 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+)
+
+const sampleDir = "../examples/stock_collector/stock_data"
+
+// loadSample 读取 examples 中录制的数据帧
+func loadSample(t *testing.T, name string) []*dtraderhq.MarketData {
+	t.Helper()
+	f, err := os.Open(filepath.Join(sampleDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var frames []*dtraderhq.MarketData
+	r := NewJSONLReader(f)
+	for r.Next() {
+		frames = append(frames, r.Frame().MarketData)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return frames
+}
+
+func writeBinary(t *testing.T, frames []*dtraderhq.MarketData) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewBinaryWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, md := range frames {
+		if err := w.Write(md); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readBinary(t *testing.T, b []byte) ([]*dtraderhq.MarketData, *BinaryReader) {
+	t.Helper()
+	r, err := NewBinaryReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frames []*dtraderhq.MarketData
+	for r.Next() {
+		frames = append(frames, r.Frame().MarketData)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return frames, r
+}
+
+func TestBinaryRoundTripSamples(t *testing.T) {
+	for _, name := range []string{
+		"SZ002240_transaction_20250627.json",
+		"SZ002240_detail_20250627.json",
+		"SZ002240_order_20250627.json",
+		"SH603065_order_20250627.json",
+	} {
+		t.Run(name, func(t *testing.T) {
+			want := loadSample(t, name)
+			b := writeBinary(t, want)
+			got, r := readBinary(t, b)
+			if r.Corrupted() != 0 {
+				t.Fatalf("corrupted = %d", r.Corrupted())
+			}
+			if len(got) != len(want) {
+				t.Fatalf("read %d frames, wrote %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i].StockCode != want[i].StockCode || got[i].DataType != want[i].DataType ||
+					got[i].Timestamp != want[i].Timestamp || !reflect.DeepEqual(got[i].Data, want[i].Data) {
+					t.Fatalf("frame %d = %+v, want %+v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBinaryDataType(t *testing.T) {
+	received := time.UnixMilli(1750993981250)
+	md := &dtraderhq.MarketData{
+		StockCode:  "SZ000001",
+		DataType:   300,
+		Timestamp:  1750993981,
+		Data:       json.RawMessage(`{"a":1}`),
+		ReceivedAt: received,
+	}
+	got, _ := readBinary(t, writeBinary(t, []*dtraderhq.MarketData{md}))
+	if len(got) != 1 || got[0].DataType != 300 || !got[0].ReceivedAt.Equal(received) {
+		t.Fatalf("frames = %+v", got)
+	}
+	if raw, _ := got[0].Data.(json.RawMessage); string(raw) != `{"a":1}` {
+		t.Fatalf("data = %s", raw)
+	}
+
+	w, err := NewBinaryWriter(&bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(&dtraderhq.MarketData{DataType: -1, Data: json.RawMessage(`[]`)}); err == nil {
+		t.Fatal("negative data type accepted")
+	}
+}
+
+func TestBinaryReaderResync(t *testing.T) {
+	frames := loadSample(t, "SZ002240_transaction_20250627.json")[:3]
+	header := len(binaryMagic) + 1
+	blocks := func(b []byte) [][]byte {
+		// 按同步标记切分数据块
+		var out [][]byte
+		rest := b[header:]
+		for len(rest) > 0 {
+			next := bytes.Index(rest[1:], blockMarker[:])
+			if next < 0 {
+				out = append(out, rest)
+				break
+			}
+			out = append(out, rest[:next+1])
+			rest = rest[next+1:]
+		}
+		return out
+	}
+
+	b := writeBinary(t, frames)
+	parts := blocks(b)
+	if len(parts) != 3 {
+		t.Fatalf("split into %d blocks", len(parts))
+	}
+	join := func(chunks ...[]byte) []byte {
+		out := append([]byte(nil), b[:header]...)
+		for _, c := range chunks {
+			out = append(out, c...)
+		}
+		return out
+	}
+	flipped := append([]byte(nil), parts[1]...)
+	flipped[len(flipped)/2] ^= 0xFF
+
+	tests := []struct {
+		name      string
+		data      []byte
+		want      []int // 读出的帧依次对应的原始帧序号
+		corrupted int
+	}{
+		{"checksum mismatch", join(parts[0], flipped, parts[2]), []int{0, 2}, 1},
+		{"torn block then append", join(parts[0], parts[1][:len(parts[1])-6], parts[2]), []int{0, 2}, 1},
+		{"torn block at end", join(parts[0], parts[1], parts[2][:10]), []int{0, 1}, 1},
+		{"garbage between blocks", join(parts[0], []byte("garbage\xd7D"), parts[1], parts[2]), []int{0, 1, 2}, 1},
+		{"bad length", join(parts[0], append(blockMarker[:], 0xFF, 0xFF, 0xFF, 0xFF, 0x7F), parts[2]), []int{0, 2}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, r := readBinary(t, tt.data)
+			if r.Corrupted() != tt.corrupted {
+				t.Fatalf("corrupted = %d, want %d", r.Corrupted(), tt.corrupted)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("read %d frames, want %d", len(got), len(tt.want))
+			}
+			for i, idx := range tt.want {
+				if !reflect.DeepEqual(got[i].Data, frames[idx].Data) {
+					t.Fatalf("frame %d does not match original frame %d", i, idx)
+				}
+			}
+		})
+	}
+}
+
+// 写入中断后以追加模式重新打开文件继续写入，读取时跳过残缺块
+func TestBinaryWriterAppendAfterTornWrite(t *testing.T) {
+	frames := loadSample(t, "SZ002240_order_20250627.json")[:2]
+	path := filepath.Join(t.TempDir(), "SZ002240_order_20250627.dtb")
+
+	b := writeBinary(t, frames[:1])
+	if err := os.WriteFile(path, b[:len(b)-3], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewBinaryWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(frames[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, r := readBinary(t, data)
+	if len(got) != 1 || r.Corrupted() != 1 || !reflect.DeepEqual(got[0].Data, frames[1].Data) {
+		t.Fatalf("read %d frames, corrupted = %d", len(got), r.Corrupted())
+	}
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+)
+
+// Envelope 逐行 JSON 文件中每行的结构
+type Envelope struct {
+	Data      json.RawMessage `json:"data"`
+	DataType  int             `json:"data_type"`
+	StockCode string          `json:"stock_code"`
+	Timestamp int64           `json:"timestamp"`
+}
+
+// JSONLWriter 逐行 JSON 写入器，每帧数据写为一行
+type JSONLWriter struct {
+	w  io.Writer
+	bw *bufio.Writer
+}
+
+// NewJSONLWriter 创建逐行 JSON 写入器，Close 时会关闭实现了 io.Closer 的 w
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	return &JSONLWriter{w: w, bw: bufio.NewWriter(w)}
+}
+
+// Write 写入一帧数据
+func (jw *JSONLWriter) Write(md *dtraderhq.MarketData) error {
+	data, err := json.Marshal(md.Data)
+	if err != nil {
+		return fmt.Errorf("marshal data: %w", err)
+	}
+
+	ts := md.Timestamp
+	if ts == 0 {
+		ts = time.Now().Unix()
+	}
+
+	line, err := json.Marshal(Envelope{
+		Data:      data,
+		DataType:  md.DataType,
+		StockCode: md.StockCode,
+		Timestamp: ts,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal envelope: %w", err)
+	}
+
+	if _, err := jw.bw.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write line: %w", err)
+	}
+	return nil
+}
+
+// Flush 刷新缓冲
+func (jw *JSONLWriter) Flush() error {
+	return jw.bw.Flush()
+}
+
+// Close 刷新缓冲并关闭底层文件
+func (jw *JSONLWriter) Close() error {
+	if err := jw.bw.Flush(); err != nil {
+		return err
+	}
+	if c, ok := jw.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// maxLineSize 单行最大长度，逐笔委托的批量推送可能很长
+const maxLineSize = 16 << 20
+
+// JSONLReader 逐行 JSON 读取器，按帧迭代
+//
+// 无法解析的行（例如采集进程中断留下的半行）被跳过，跳过的行数由 Corrupted 返回；
+// 只有读取失败时才以 Err 返回错误。
+type JSONLReader struct {
+	scanner   *bufio.Scanner
+	frame     *dtraderhq.DecodedFrame
+	err       error
+	line      int
+	corrupted int
+}
+
+// NewJSONLReader 创建逐行 JSON 读取器
+func NewJSONLReader(r io.Reader) *JSONLReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &JSONLReader{scanner: scanner}
+}
+
+// Next 读取下一帧，跳过空行和无法解析的行，到达文件末尾或读取出错时返回 false
+func (r *JSONLReader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		frame, err := decodeLine(line)
+		if err != nil {
+			r.corrupted++
+			continue
+		}
+		r.frame = frame
+		return true
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		r.err = fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	return false
+}
+
+// Corrupted 返回已跳过的无法解析的行数
+func (r *JSONLReader) Corrupted() int {
+	return r.corrupted
+}
+
+// Frame 返回当前帧，开放数据类型的 MarketData.Data 为对应的类型化记录切片
+func (r *JSONLReader) Frame() *dtraderhq.DecodedFrame {
+	return r.frame
+}
+
+// Err 返回迭代过程中的错误，正常结束时为 nil
+func (r *JSONLReader) Err() error {
+	return r.err
+}
+
+func decodeLine(line []byte) (*dtraderhq.DecodedFrame, error) {
+	var env Envelope
+	if err := json.Unmarshal(line, &env); err != nil {
+		return nil, fmt.Errorf("decode envelope: %w", err)
+	}
+
+	md := &dtraderhq.MarketData{
+		StockCode: env.StockCode,
+		DataType:  env.DataType,
+		Data:      env.Data,
+		Timestamp: env.Timestamp,
+	}
+	frame, err := dtraderhq.DecodeFrame(md)
+	if err != nil {
+		return nil, err
+	}
+
+	switch md.DataType {
+	case dtraderhq.DataTypeTransaction:
+		md.Data = frame.Transactions
+	case dtraderhq.DataTypeBigOrder:
+		md.Data = frame.BigOrders
+	case dtraderhq.DataTypeZBWT:
+		md.Data = frame.Orders
+	}
+	return frame, nil
+}
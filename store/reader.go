@@ -0,0 +1,269 @@
+package store
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+)
+
+// FrameIterator 按帧迭代的读取器
+type FrameIterator interface {
+	Next() bool
+	Frame() *dtraderhq.DecodedFrame
+	Err() error
+}
+
+// corruptionCounter 跳过损坏数据继续读取的迭代器
+type corruptionCounter interface {
+	Corrupted() int
+}
+
+// ReaderOptions 目录读取选项，零值表示读取全部数据
+type ReaderOptions struct {
+	StockCodes []string  // 只读取这些股票，为空表示全部
+	DataTypes  []int     // 只读取这些数据类型，为空表示全部
+	From       time.Time // 帧时间戳下限（含），零值表示不限
+	To         time.Time // 帧时间戳上限（不含），零值表示不限
+	Dedup      bool      // 按记录序号去重
+}
+
+// ListFiles 列出目录中符合命名规则的数据文件，按文件名排序
+func ListFiles(dir string) ([]FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read data dir: %w", err)
+	}
+
+	var files []FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if info, ok := ParseFileName(filepath.Join(dir, entry.Name())); ok {
+			files = append(files, info)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// Reader 将目录中多个股票、多个数据类型的文件合并为按帧时间戳排序的单一迭代器
+//
+// 各文件内部假定按时间顺序写入，Reader 只做多路归并，不做全局排序；
+// 时间戳相同的帧按文件名顺序输出。
+type Reader struct {
+	files   []FileInfo
+	closers []io.Closer
+	sources []*source
+	heap    sourceHeap
+	opts    ReaderOptions
+	dedup   *dtraderhq.Deduper
+	frame   *dtraderhq.DecodedFrame
+	err     error
+}
+
+// OpenDir 打开数据目录，按选项筛选文件
+func OpenDir(dir string, opts ReaderOptions) (*Reader, error) {
+	all, err := ListFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Reader{opts: opts}
+	if opts.Dedup {
+		r.dedup = dtraderhq.NewDeduper(0)
+	}
+
+	for _, info := range all {
+		if !r.wantFile(info) {
+			continue
+		}
+		if err := r.addFile(info); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *Reader) wantFile(info FileInfo) bool {
+	if len(r.opts.StockCodes) > 0 && !containsString(r.opts.StockCodes, info.StockCode) {
+		return false
+	}
+	if len(r.opts.DataTypes) > 0 && !containsInt(r.opts.DataTypes, info.DataType) {
+		return false
+	}
+	// 文件日期覆盖 [Date, Date+1天)，与时间窗无交集时跳过
+	if !r.opts.To.IsZero() && !info.Date.Before(r.opts.To) {
+		return false
+	}
+	if !r.opts.From.IsZero() && !info.Date.AddDate(0, 0, 1).After(r.opts.From) {
+		return false
+	}
+	return true
+}
+
+func (r *Reader) addFile(info FileInfo) error {
+	f, err := os.Open(info.Path)
+	if err != nil {
+		return fmt.Errorf("open data file: %w", err)
+	}
+	r.closers = append(r.closers, f)
+	r.files = append(r.files, info)
+
+	var it FrameIterator
+	if info.Format == FormatBinary {
+		br, err := NewBinaryReader(f)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", info.Path, err)
+		}
+		it = br
+	} else {
+		it = NewJSONLReader(f)
+	}
+
+	src := &source{it: it, info: info, order: len(r.files)}
+	r.sources = append(r.sources, src)
+	if !r.advance(src) {
+		return r.err
+	}
+	heap.Push(&r.heap, src)
+	return nil
+}
+
+// advance 读取来源中下一个落在时间窗内的帧
+func (r *Reader) advance(src *source) bool {
+	for src.it.Next() {
+		frame := src.it.Frame()
+		ts := time.Unix(frame.Timestamp, 0)
+		if !r.opts.From.IsZero() && ts.Before(r.opts.From) {
+			continue
+		}
+		if !r.opts.To.IsZero() && !ts.Before(r.opts.To) {
+			continue
+		}
+		src.frame = frame
+		return true
+	}
+	if err := src.it.Err(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("%s: %w", src.info.Path, err)
+	}
+	return false
+}
+
+// Files 返回参与读取的文件
+func (r *Reader) Files() []FileInfo {
+	return r.files
+}
+
+// Corrupted 返回各文件中已跳过的损坏数据块和无法解析的行数之和
+func (r *Reader) Corrupted() int {
+	n := 0
+	for _, src := range r.sources {
+		if c, ok := src.it.(corruptionCounter); ok {
+			n += c.Corrupted()
+		}
+	}
+	return n
+}
+
+// Next 读取下一帧，所有文件读完或出错时返回 false
+func (r *Reader) Next() bool {
+	for r.err == nil && r.heap.Len() > 0 {
+		src := r.heap[0]
+		frame := src.frame
+
+		if r.advance(src) {
+			heap.Fix(&r.heap, 0)
+		} else {
+			heap.Pop(&r.heap)
+		}
+
+		if r.dedup != nil {
+			if frame = r.dedup.Filter(frame); frame == nil {
+				continue
+			}
+		}
+		r.frame = frame
+		return true
+	}
+	return false
+}
+
+// Frame 返回当前帧
+func (r *Reader) Frame() *dtraderhq.DecodedFrame {
+	return r.frame
+}
+
+// Err 返回迭代过程中的错误，正常结束时为 nil
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Close 关闭所有文件
+func (r *Reader) Close() error {
+	var firstErr error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	r.closers = nil
+	return firstErr
+}
+
+// source 参与归并的单个文件
+type source struct {
+	it    FrameIterator
+	info  FileInfo
+	order int
+	frame *dtraderhq.DecodedFrame
+}
+
+// sourceHeap 按当前帧时间戳排序的小顶堆
+type sourceHeap []*source
+
+func (h sourceHeap) Len() int { return len(h) }
+func (h sourceHeap) Less(i, j int) bool {
+	if h[i].frame.Timestamp != h[j].frame.Timestamp {
+		return h[i].frame.Timestamp < h[j].frame.Timestamp
+	}
+	return h[i].order < h[j].order
+}
+func (h sourceHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *sourceHeap) Push(x interface{}) { *h = append(*h, x.(*source)) }
+func (h *sourceHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
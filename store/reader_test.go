@@ -0,0 +1,242 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+)
+
+func readAll(t *testing.T, r *Reader) []*dtraderhq.DecodedFrame {
+	t.Helper()
+	var frames []*dtraderhq.DecodedFrame
+	for r.Next() {
+		frames = append(frames, r.Frame())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return frames
+}
+
+func TestParseFileName(t *testing.T) {
+	tests := []struct {
+		path     string
+		ok       bool
+		code     string
+		dataType int
+		format   Format
+	}{
+		{"data/SZ002240_transaction_20250627.json", true, "SZ002240", dtraderhq.DataTypeTransaction, FormatJSONL},
+		{"SH603065_order_20250627.dtb", true, "SH603065", dtraderhq.DataTypeZBWT, FormatBinary},
+		{"SH603065_type300_20250627.dtb", true, "SH603065", 300, FormatBinary},
+		{"SH603065_order_20250627.txt", false, "", 0, ""},
+		{"SH603065_unknown_20250627.json", false, "", 0, ""},
+		{"SH603065_order_2025.json", false, "", 0, ""},
+	}
+	for _, tt := range tests {
+		info, ok := ParseFileName(tt.path)
+		if ok != tt.ok {
+			t.Errorf("ParseFileName(%q) ok = %v", tt.path, ok)
+			continue
+		}
+		if ok && (info.StockCode != tt.code || info.DataType != tt.dataType || info.Format != tt.format ||
+			info.Date.Format("20060102") != "20250627") {
+			t.Errorf("ParseFileName(%q) = %+v", tt.path, info)
+		}
+	}
+
+	date := time.Date(2025, 6, 27, 0, 0, 0, 0, dtraderhq.ShanghaiLocation)
+	if got := FileName("SZ002240", dtraderhq.DataTypeBigOrder, date, FormatBinary); got != "SZ002240_detail_20250627.dtb" {
+		t.Errorf("FileName = %q", got)
+	}
+}
+
+func TestOpenDirMergesSamples(t *testing.T) {
+	files, err := ListFiles(sampleDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 0
+	for _, info := range files {
+		want += len(loadSample(t, filepath.Base(info.Path)))
+	}
+
+	r, err := OpenDir(sampleDir, ReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	frames := readAll(t, r)
+	if len(r.Files()) != len(files) || len(frames) != want {
+		t.Fatalf("read %d frames from %d files, want %d from %d", len(frames), len(r.Files()), want, len(files))
+	}
+	for i := 1; i < len(frames); i++ {
+		if frames[i].Timestamp < frames[i-1].Timestamp {
+			t.Fatalf("frame %d at %d before frame %d at %d", i, frames[i].Timestamp, i-1, frames[i-1].Timestamp)
+		}
+	}
+}
+
+func TestOpenDirFilters(t *testing.T) {
+	all := loadSample(t, "SZ002240_transaction_20250627.json")
+	from := time.Unix(all[len(all)/4].Timestamp, 0)
+	to := time.Unix(all[len(all)*3/4].Timestamp, 0)
+	want := 0
+	for _, md := range all {
+		if ts := time.Unix(md.Timestamp, 0); !ts.Before(from) && ts.Before(to) {
+			want++
+		}
+	}
+
+	r, err := OpenDir(sampleDir, ReaderOptions{
+		StockCodes: []string{"SZ002240"},
+		DataTypes:  []int{dtraderhq.DataTypeTransaction},
+		From:       from,
+		To:         to,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if len(r.Files()) != 1 {
+		t.Fatalf("files = %+v", r.Files())
+	}
+	if frames := readAll(t, r); len(frames) != want || want == 0 {
+		t.Fatalf("read %d frames, want %d", len(frames), want)
+	}
+}
+
+// 同一份数据分别以 JSON 和二进制格式落盘后合并读取，去重后每条记录只出现一次
+func TestOpenDirDedup(t *testing.T) {
+	const name = "SZ002240_transaction_20250627.json"
+	frames := loadSample(t, name)
+	total, unique := 0, make(map[int64]struct{})
+	for _, md := range frames {
+		for _, tr := range md.Data.([]dtraderhq.Transaction) {
+			total++
+			unique[tr.OrderPackId] = struct{}{}
+		}
+	}
+
+	dir := t.TempDir()
+	b, err := os.ReadFile(filepath.Join(sampleDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecorder(dir, FormatBinary)
+	for _, md := range frames {
+		if err := rec.Write(md); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dedup := range []bool{false, true} {
+		r, err := OpenDir(dir, ReaderOptions{Dedup: dedup})
+		if err != nil {
+			t.Fatal(err)
+		}
+		records := 0
+		for _, frame := range readAll(t, r) {
+			records += len(frame.Transactions)
+		}
+		r.Close()
+
+		want := 2 * total
+		if dedup {
+			want = len(unique)
+		}
+		if records != want {
+			t.Errorf("dedup=%v: read %d records, want %d", dedup, records, want)
+		}
+	}
+}
+
+// 序号每个交易日重新编号，跨日读取时去重不丢弃后一天的记录
+func TestOpenDirDedupAcrossDays(t *testing.T) {
+	dir := t.TempDir()
+	rec := NewRecorder(dir, FormatJSONL)
+	for _, date := range []time.Time{
+		time.Date(2025, 6, 26, 9, 30, 0, 0, dtraderhq.ShanghaiLocation),
+		time.Date(2025, 6, 27, 9, 30, 0, 0, dtraderhq.ShanghaiLocation),
+	} {
+		for id := int64(1); id <= 2; id++ {
+			md := &dtraderhq.MarketData{
+				StockCode: "SZ000001",
+				DataType:  dtraderhq.DataTypeTransaction,
+				Timestamp: date.Unix() + id,
+				Data:      []dtraderhq.Transaction{{OrderPackId: id, Time: date.Unix() + id}},
+			}
+			if err := rec.Write(md); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenDir(dir, ReaderOptions{Dedup: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(r.Files()) != 2 {
+		t.Fatalf("files = %+v", r.Files())
+	}
+	if frames := readAll(t, r); len(frames) != 4 {
+		t.Fatalf("read %d frames, want 4", len(frames))
+	}
+}
+
+// 无法解析的行被跳过并计数，不影响其余数据
+func TestJSONLReaderSkipsBadLines(t *testing.T) {
+	const name = "SZ002240_transaction_20250627.json"
+	b, err := os.ReadFile(filepath.Join(sampleDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := len(loadSample(t, name))
+
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	var content []byte
+	content = append(content, lines[0]...)
+	content = append(content, "not json\n"...)
+	for _, line := range lines[1:] {
+		content = append(content, line...)
+	}
+	content = append(content, `{"data":[{"OrderPackId":`...) // 写入中断留下的半行
+
+	r := NewJSONLReader(bytes.NewReader(content))
+	n := 0
+	for r.Next() {
+		n++
+	}
+	if r.Err() != nil || n != want || r.Corrupted() != 2 {
+		t.Fatalf("read %d frames, corrupted = %d, err = %v", n, r.Corrupted(), r.Err())
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dr, err := OpenDir(dir, ReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dr.Close()
+	if frames := readAll(t, dr); len(frames) != want || dr.Corrupted() != 2 {
+		t.Fatalf("OpenDir read %d frames, corrupted = %d", len(frames), dr.Corrupted())
+	}
+}
@@ -0,0 +1,270 @@
+// Package store 提供行情数据的落盘与回读
+//
+// 文件按 {股票代码}_{数据类型}_{日期} 命名，例如 SZ002240_transaction_20250627.json，
+// 支持逐行 JSON（与 stock_collector 示例的输出一致）和紧凑的二进制格式。
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dtraderhq "github.com/DTrader-store/level2-client-go"
+)
+
+// Format 存储格式
+type Format string
+
+// 支持的存储格式
+const (
+	FormatJSONL  Format = "jsonl"
+	FormatBinary Format = "binary"
+)
+
+// Ext 返回存储格式对应的文件扩展名
+func (f Format) Ext() string {
+	if f == FormatBinary {
+		return ".dtb"
+	}
+	return ".json"
+}
+
+// Sink 数据落盘目标
+type Sink interface {
+	Write(md *dtraderhq.MarketData) error
+	Flush() error
+	Close() error
+}
+
+// DataTypeName 数据类型在文件名中的名称
+func DataTypeName(dataType int) string {
+	switch dataType {
+	case dtraderhq.DataTypeTransaction:
+		return "transaction" // 逐笔成交
+	case dtraderhq.DataTypeBigOrder:
+		return "detail" // 逐笔大单
+	case dtraderhq.DataTypeZBWT:
+		return "order" // 逐笔委托
+	default:
+		return "type" + strconv.Itoa(dataType)
+	}
+}
+
+// ParseDataTypeName 解析文件名中的数据类型名称
+func ParseDataTypeName(name string) (int, bool) {
+	switch name {
+	case "transaction":
+		return dtraderhq.DataTypeTransaction, true
+	case "detail":
+		return dtraderhq.DataTypeBigOrder, true
+	case "order":
+		return dtraderhq.DataTypeZBWT, true
+	}
+	if strings.HasPrefix(name, "type") {
+		if n, err := strconv.Atoi(name[len("type"):]); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// FileInfo 由文件名解析出的信息
+type FileInfo struct {
+	Path      string
+	StockCode string
+	DataType  int
+	Date      time.Time // 交易所时区零点
+	Format    Format
+}
+
+// FileName 生成数据文件名
+func FileName(stockCode string, dataType int, date time.Time, format Format) string {
+	return fmt.Sprintf("%s_%s_%s%s", stockCode, DataTypeName(dataType),
+		date.In(dtraderhq.ShanghaiLocation).Format("20060102"), format.Ext())
+}
+
+// ParseFileName 解析数据文件名，不符合命名规则时返回 false
+func ParseFileName(path string) (FileInfo, bool) {
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+
+	var format Format
+	switch ext {
+	case FormatJSONL.Ext():
+		format = FormatJSONL
+	case FormatBinary.Ext():
+		format = FormatBinary
+	default:
+		return FileInfo{}, false
+	}
+
+	parts := strings.Split(strings.TrimSuffix(base, ext), "_")
+	if len(parts) != 3 {
+		return FileInfo{}, false
+	}
+
+	dataType, ok := ParseDataTypeName(parts[1])
+	if !ok {
+		return FileInfo{}, false
+	}
+	date, err := time.ParseInLocation("20060102", parts[2], dtraderhq.ShanghaiLocation)
+	if err != nil {
+		return FileInfo{}, false
+	}
+
+	return FileInfo{
+		Path:      path,
+		StockCode: parts[0],
+		DataType:  dataType,
+		Date:      date,
+		Format:    format,
+	}, true
+}
+
+// recorderKey 每个文件对应的股票、数据类型和日期
+type recorderKey struct {
+	stockCode string
+	dataType  int
+	date      string
+}
+
+// Recorder 按股票、数据类型和日期分文件落盘
+type Recorder struct {
+	dir    string
+	format Format
+
+	mu    sync.Mutex
+	sinks map[recorderKey]Sink
+}
+
+// NewRecorder 创建记录器，文件写入 dir 目录
+func NewRecorder(dir string, format Format) *Recorder {
+	return &Recorder{
+		dir:    dir,
+		format: format,
+		sinks:  make(map[recorderKey]Sink),
+	}
+}
+
+// Write 写入一帧数据，文件日期取自数据帧时间戳
+func (r *Recorder) Write(md *dtraderhq.MarketData) error {
+	ts := time.Unix(md.Timestamp, 0)
+	if md.Timestamp == 0 {
+		ts = time.Now()
+	}
+
+	sink, err := r.sink(md.StockCode, md.DataType, ts)
+	if err != nil {
+		return err
+	}
+	return sink.Write(md)
+}
+
+func (r *Recorder) sink(stockCode string, dataType int, ts time.Time) (Sink, error) {
+	key := recorderKey{
+		stockCode: stockCode,
+		dataType:  dataType,
+		date:      ts.In(dtraderhq.ShanghaiLocation).Format("20060102"),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if sink, ok := r.sinks[key]; ok {
+		return sink, nil
+	}
+
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return nil, fmt.Errorf("create data dir: %w", err)
+	}
+
+	path := filepath.Join(r.dir, FileName(stockCode, dataType, ts, r.format))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open data file: %w", err)
+	}
+
+	var sink Sink
+	switch r.format {
+	case FormatBinary:
+		sink, err = NewBinaryWriter(f)
+	default:
+		sink = NewJSONLWriter(f)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r.sinks[key] = sink
+	return sink, nil
+}
+
+// Flush 刷新所有文件缓冲
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var firstErr error
+	for _, sink := range r.sinks {
+		if err := sink.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close 刷新并关闭所有文件
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var firstErr error
+	for key, sink := range r.sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(r.sinks, key)
+	}
+	return firstErr
+}
+
+// MultiSink 将数据同时写入多个落盘目标
+type MultiSink []Sink
+
+// Write 依次写入各目标，返回第一个错误
+func (m MultiSink) Write(md *dtraderhq.MarketData) error {
+	var firstErr error
+	for _, s := range m {
+		if err := s.Write(md); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Flush 刷新各目标
+func (m MultiSink) Flush() error {
+	var firstErr error
+	for _, s := range m {
+		if err := s.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close 关闭各目标
+func (m MultiSink) Close() error {
+	var firstErr error
+	for _, s := range m {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,300 @@
+package dtraderhq
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SubscriptionDiff 当前订阅与目标订阅的差异
+type SubscriptionDiff struct {
+	Added   []SubscribeMessage // 新增的股票
+	Removed []string           // 取消的股票
+	Changed []SubscribeMessage // 数据类型变化的股票，DataTypes 为新的数据类型
+}
+
+// Empty 是否没有差异
+func (d SubscriptionDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String 返回便于日志输出的差异摘要
+func (d SubscriptionDiff) String() string {
+	if d.Empty() {
+		return "no change"
+	}
+
+	var parts []string
+	if len(d.Added) > 0 {
+		parts = append(parts, "+"+formatSubscriptions(d.Added))
+	}
+	if len(d.Removed) > 0 {
+		parts = append(parts, "-"+strings.Join(d.Removed, ","))
+	}
+	if len(d.Changed) > 0 {
+		parts = append(parts, "~"+formatSubscriptions(d.Changed))
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatSubscriptions(subs []SubscribeMessage) string {
+	items := make([]string, len(subs))
+	for i, sub := range subs {
+		items[i] = fmt.Sprintf("%s%v", sub.StockCode, sub.DataTypes)
+	}
+	return strings.Join(items, ",")
+}
+
+// DiffSubscriptions 比较当前订阅（GetSubscriptions 的结果）与目标订阅，数据类型按集合比较
+func DiffSubscriptions(current map[string][]int, desired []SubscribeMessage) SubscriptionDiff {
+	var diff SubscriptionDiff
+
+	wanted := make(map[string]bool, len(desired))
+	for _, sub := range desired {
+		wanted[sub.StockCode] = true
+		types, ok := current[sub.StockCode]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, sub)
+		case !sameTypes(types, sub.DataTypes):
+			diff.Changed = append(diff.Changed, sub)
+		}
+	}
+
+	for stockCode := range current {
+		if !wanted[stockCode] {
+			diff.Removed = append(diff.Removed, stockCode)
+		}
+	}
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+// sameTypes 两组数据类型是否相同（忽略顺序和重复）
+func sameTypes(a, b []int) bool {
+	set := make(map[int]bool, len(a))
+	for _, t := range a {
+		set[t] = true
+	}
+	seen := make(map[int]bool, len(b))
+	for _, t := range b {
+		if !set[t] {
+			return false
+		}
+		seen[t] = true
+	}
+	return len(seen) == len(set)
+}
+
+// containsTypes 数据类型 a 是否包含 b 中的全部类型
+func containsTypes(a, b []int) bool {
+	set := make(map[int]bool, len(a))
+	for _, t := range a {
+		set[t] = true
+	}
+	for _, t := range b {
+		if !set[t] {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplySubscriptionDiff 在当前连接上应用订阅差异，不断开连接
+//
+// 只对需要的股票发送请求：先分批取消 Removed 以及减少了数据类型的 Changed，
+// 再分批订阅 Added 和 Changed；只增加数据类型的股票直接按新的类型订阅，不会中断已有数据。
+// 每批的数量按 WithBatchSize 设置（默认 MaxBatchSize）。
+// 任一批失败时按应用前的订阅尽量回滚已完成的操作，返回的错误同时包含回滚失败的原因。
+func (c *Client) ApplySubscriptionDiff(diff SubscriptionDiff) error {
+	if diff.Empty() {
+		return nil
+	}
+	if !c.IsAuthenticated() {
+		return ErrNotAuthenticated
+	}
+
+	previous := c.GetSubscriptions()
+
+	unsubscribe := append([]string(nil), diff.Removed...)
+	for _, sub := range diff.Changed {
+		if !containsTypes(sub.DataTypes, previous[sub.StockCode]) {
+			unsubscribe = append(unsubscribe, sub.StockCode)
+		}
+	}
+	subscribe := append(append([]SubscribeMessage(nil), diff.Added...), diff.Changed...)
+
+	size := c.subscribeBatchSize()
+	var unsubscribed []string
+	var subscribed []SubscribeMessage
+	for start := 0; start < len(unsubscribe); start += size {
+		end := min(start+size, len(unsubscribe))
+		// 发送失败时本地记录也已删除，同样需要恢复
+		unsubscribed = append(unsubscribed, unsubscribe[start:end]...)
+		if err := c.BatchUnsubscribe(unsubscribe[start:end]); err != nil {
+			return c.rollbackSubscriptions(fmt.Errorf("batch unsubscribe: %w", err), previous, unsubscribed, subscribed)
+		}
+	}
+
+	for start := 0; start < len(subscribe); start += size {
+		end := min(start+size, len(subscribe))
+		subscribed = append(subscribed, subscribe[start:end]...)
+		if err := c.BatchSubscribe(subscribe[start:end]); err != nil {
+			return c.rollbackSubscriptions(fmt.Errorf("batch subscribe: %w", err), previous, unsubscribed, subscribed)
+		}
+	}
+	return nil
+}
+
+// rollbackSubscriptions 撤销已执行的订阅变更：订阅请求会与股票已有的类型合并，
+// 因此先取消新增的股票和增加过数据类型的股票，再按原数据类型重新订阅涉及的股票
+func (c *Client) rollbackSubscriptions(cause error, previous map[string][]int, unsubscribed []string, subscribed []SubscribeMessage) error {
+	var widened []string
+	for _, sub := range subscribed {
+		if types, ok := previous[sub.StockCode]; !ok || !containsTypes(types, sub.DataTypes) {
+			widened = append(widened, sub.StockCode)
+		}
+	}
+
+	var errs []error
+	size := c.subscribeBatchSize()
+	for start := 0; start < len(widened); start += size {
+		end := min(start+size, len(widened))
+		if err := c.BatchUnsubscribe(widened[start:end]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var restore []SubscribeMessage
+	restored := make(map[string]bool)
+	codes := append([]string(nil), unsubscribed...)
+	for _, sub := range subscribed {
+		codes = append(codes, sub.StockCode)
+	}
+	for _, stockCode := range codes {
+		types, ok := previous[stockCode]
+		if !ok || restored[stockCode] {
+			continue
+		}
+		restored[stockCode] = true
+		restore = append(restore, SubscribeMessage{StockCode: stockCode, DataTypes: types})
+	}
+	if len(restore) > 0 {
+		if err := c.SubscribeAll(restore); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w (rollback failed: %v)", cause, errors.Join(errs...))
+	}
+	return fmt.Errorf("%w (rolled back)", cause)
+}
+
+// SubscriptionChange 单只股票的数据类型变化
+type SubscriptionChange struct {
+	StockCode string
+	From      []DataType
+	To        []DataType
+}
+
+// ReconcileReport Reconcile 的执行结果
+type ReconcileReport struct {
+	Added    []SubscribeMessage
+	Removed  []string
+	Modified []SubscriptionChange
+}
+
+// Empty 是否没有任何变更
+func (r *ReconcileReport) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Modified) == 0
+}
+
+// Reconcile 将订阅调整为 desired（股票代码 -> 数据类型），只对有变化的股票发送请求，
+// 未变化的股票不会中断数据。数据类型为空的股票视为不订阅。
+//
+// 返回的报告描述需要执行的变更；出错时已尽量回滚，报告仍给出计划的变更。
+func (c *Client) Reconcile(desired map[string][]DataType) (*ReconcileReport, error) {
+	codes := make([]string, 0, len(desired))
+	for stockCode, types := range desired {
+		if len(types) > 0 {
+			codes = append(codes, stockCode)
+		}
+	}
+	sort.Strings(codes)
+
+	list := make([]SubscribeMessage, len(codes))
+	for i, stockCode := range codes {
+		list[i] = SubscribeMessage{StockCode: stockCode, DataTypes: append([]int(nil), desired[stockCode]...)}
+	}
+
+	current := c.GetSubscriptions()
+	diff := DiffSubscriptions(current, list)
+
+	report := &ReconcileReport{Added: diff.Added, Removed: diff.Removed}
+	for _, sub := range diff.Changed {
+		report.Modified = append(report.Modified, SubscriptionChange{
+			StockCode: sub.StockCode,
+			From:      current[sub.StockCode],
+			To:        sub.DataTypes,
+		})
+	}
+
+	return report, c.ApplySubscriptionDiff(diff)
+}
+
+// AddTypes 为股票增加数据类型，与已有订阅合并后按合并结果订阅，已有类型的数据不会中断。
+// 股票尚未订阅时等同于 Subscribe。
+func (c *Client) AddTypes(stockCode string, types ...DataType) error {
+	current, subscribed := c.subscribedTypes(stockCode)
+
+	merged := append([]int(nil), current...)
+	for _, t := range types {
+		if !containsTypes(merged, []int{t}) {
+			merged = append(merged, t)
+		}
+	}
+	return c.setTypes(stockCode, current, subscribed, merged)
+}
+
+// UnsubscribeTypes 取消股票的部分数据类型，保留其余类型。
+// 服务器只支持按股票取消订阅，因此会先取消该股票，再按剩余类型重新订阅；
+// 全部类型都被取消时等同于 Unsubscribe。
+func (c *Client) UnsubscribeTypes(stockCode string, types ...DataType) error {
+	current, subscribed := c.subscribedTypes(stockCode)
+	if !subscribed {
+		return fmt.Errorf("%w: %s", ErrNotSubscribed, stockCode)
+	}
+
+	var remaining []int
+	for _, t := range current {
+		if !containsTypes(types, []int{t}) {
+			remaining = append(remaining, t)
+		}
+	}
+	return c.setTypes(stockCode, current, subscribed, remaining)
+}
+
+// subscribedTypes 返回股票当前订阅的数据类型
+func (c *Client) subscribedTypes(stockCode string) ([]int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	types, ok := c.subscriptions[stockCode]
+	return append([]int(nil), types...), ok
+}
+
+// setTypes 将单只股票的数据类型调整为 types，失败时恢复原订阅，本地记录与服务器保持一致
+func (c *Client) setTypes(stockCode string, current []int, subscribed bool, types []int) error {
+	var diff SubscriptionDiff
+	switch {
+	case !subscribed && len(types) > 0:
+		diff.Added = []SubscribeMessage{{StockCode: stockCode, DataTypes: types}}
+	case subscribed && len(types) == 0:
+		diff.Removed = []string{stockCode}
+	case subscribed && !sameTypes(current, types):
+		diff.Changed = []SubscribeMessage{{StockCode: stockCode, DataTypes: types}}
+	}
+	return c.ApplySubscriptionDiff(diff)
+}
@@ -0,0 +1,391 @@
+package dtraderhq
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestReconcile(t *testing.T) {
+	srv := newTestServer(t)
+	srv.ackRequests(nil)
+	c := connectTestClient(t, srv, WithAckTimeout(time.Second))
+	if err := c.SubscribeAll([]SubscribeMessage{
+		{StockCode: "SZ000001", DataTypes: []int{4}},
+		{StockCode: "SZ000002", DataTypes: []int{4}},
+		{StockCode: "SZ000003", DataTypes: []int{4, 8}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	before := len(srv.messages(MessageTypeBatchSubscribe))
+
+	report, err := c.Reconcile(map[string][]DataType{
+		"SZ000001": {4},
+		"SZ000003": {4, 8, 14},
+		"SZ000004": {14},
+		"SZ000005": nil, // 不订阅
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &ReconcileReport{
+		Added:    []SubscribeMessage{{StockCode: "SZ000004", DataTypes: []int{14}}},
+		Removed:  []string{"SZ000002"},
+		Modified: []SubscriptionChange{{StockCode: "SZ000003", From: []int{4, 8}, To: []int{4, 8, 14}}},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Fatalf("report = %+v", report)
+	}
+
+	// 未变化的 SZ000001 不应出现在任何请求中
+	for _, msg := range srv.messages(MessageTypeBatchSubscribe)[before:] {
+		for _, code := range requestCodes(msg) {
+			if code == "SZ000001" {
+				t.Fatal("unchanged stock was resubscribed")
+			}
+		}
+	}
+	got := c.GetSubscriptions()
+	if len(got) != 3 || !sameTypes(got["SZ000003"], []int{4, 8, 14}) || got["SZ000002"] != nil {
+		t.Fatalf("subscriptions = %v", got)
+	}
+	srv.checkSubscriptions(t, c)
+
+	// 减少数据类型的股票先取消再订阅
+	if _, err := c.Reconcile(map[string][]DataType{"SZ000001": {4}, "SZ000003": {4}, "SZ000004": {14}}); err != nil {
+		t.Fatal(err)
+	}
+	srv.checkSubscriptions(t, c)
+
+	report, err = c.Reconcile(map[string][]DataType{"SZ000001": {4}, "SZ000003": {4}, "SZ000004": {14}})
+	if err != nil || !report.Empty() {
+		t.Fatalf("second Reconcile = %+v, %v", report, err)
+	}
+}
+
+func TestUnsubscribeTypes(t *testing.T) {
+	srv := newTestServer(t)
+	srv.ackRequests(nil)
+	c := connectTestClient(t, srv, WithAckTimeout(time.Second))
+	if err := c.Subscribe("SZ000001", []int{4, 8, 14}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UnsubscribeTypes("SZ000001", 14); err != nil {
+		t.Fatal(err)
+	}
+	// 订阅请求与已有类型合并，因此先取消该股票再按剩余类型订阅
+	if n := len(srv.messages(MessageTypeBatchUnsubscribe)); n != 1 {
+		t.Fatalf("sent %d batch_unsubscribe requests", n)
+	}
+	batches := srv.messages(MessageTypeBatchSubscribe)
+	last := batches[len(batches)-1]["data"].(map[string]any)["subscriptions"].([]any)[0].(map[string]any)
+	if !reflect.DeepEqual(last["data_types"], []any{4.0, 8.0}) {
+		t.Fatalf("resubscribed with %v", last["data_types"])
+	}
+	if got := c.GetSubscriptions()["SZ000001"]; !reflect.DeepEqual(got, []int{4, 8}) {
+		t.Fatalf("local types = %v", got)
+	}
+	srv.checkSubscriptions(t, c)
+
+	if err := c.AddTypes("SZ000001", 14, 4); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.GetSubscriptions()["SZ000001"]; !reflect.DeepEqual(got, []int{4, 8, 14}) {
+		t.Fatalf("local types after AddTypes = %v", got)
+	}
+	srv.checkSubscriptions(t, c)
+
+	// 取消全部类型等同于取消订阅
+	if err := c.UnsubscribeTypes("SZ000001", 4, 8, 14); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.messages(MessageTypeBatchUnsubscribe)) != 2 || len(c.GetSubscriptions()) != 0 {
+		t.Fatalf("subscriptions = %v", c.GetSubscriptions())
+	}
+	if err := c.UnsubscribeTypes("SZ000001", 4); !errors.Is(err, ErrNotSubscribed) {
+		t.Fatalf("UnsubscribeTypes on unsubscribed stock = %v", err)
+	}
+}
+
+// 回滚时取消新增和增加过类型的股票，再按原类型重新订阅
+func TestApplySubscriptionDiffRollback(t *testing.T) {
+	srv := newTestServer(t)
+	c := connectTestClient(t, srv, WithAckTimeout(time.Second))
+	srv.ackRequests(nil)
+	if err := c.SubscribeAll([]SubscribeMessage{
+		{StockCode: "SZ000001", DataTypes: []int{4}},
+		{StockCode: "SZ000002", DataTypes: []int{4}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	previous := c.GetSubscriptions()
+
+	srv.ackRequests(func(msg map[string]any) string {
+		for _, code := range requestCodes(msg) {
+			if code == "SZ000003" && msg["type"] == MessageTypeBatchSubscribe {
+				return "invalid stock code"
+			}
+		}
+		return ""
+	})
+	err := c.ApplySubscriptionDiff(SubscriptionDiff{
+		Added:   []SubscribeMessage{{StockCode: "SZ000003", DataTypes: []int{4}}},
+		Removed: []string{"SZ000002"},
+		Changed: []SubscribeMessage{{StockCode: "SZ000001", DataTypes: []int{4, 8}}},
+	})
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("ApplySubscriptionDiff = %v", err)
+	}
+
+	if got := c.GetSubscriptions(); !reflect.DeepEqual(got, previous) {
+		t.Fatalf("subscriptions after rollback = %v, want %v", got, previous)
+	}
+	srv.checkSubscriptions(t, c)
+	// 第一个请求取消 SZ000002，回滚时取消新增的 SZ000003 和增加了类型的 SZ000001
+	unsubs := srv.messages(MessageTypeBatchUnsubscribe)
+	if len(unsubs) != 2 || !reflect.DeepEqual(requestCodes(unsubs[1]), []string{"SZ000003", "SZ000001"}) {
+		t.Fatalf("batch_unsubscribe requests = %v", unsubs)
+	}
+}
+
+// 差异按 WithBatchSize 分批发送
+func TestApplySubscriptionDiffBatchSize(t *testing.T) {
+	srv := newTestServer(t)
+	srv.ackRequests(nil)
+	c := connectTestClient(t, srv, WithAckTimeout(time.Second), WithBatchSize(2))
+	err := c.ApplySubscriptionDiff(SubscriptionDiff{Added: []SubscribeMessage{
+		{StockCode: "SZ000001", DataTypes: []int{4}},
+		{StockCode: "SZ000002", DataTypes: []int{4}},
+		{StockCode: "SZ000003", DataTypes: []int{4}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.messages(MessageTypeBatchSubscribe)); n != 2 {
+		t.Fatalf("sent %d batches, want 2", n)
+	}
+	srv.checkSubscriptions(t, c)
+}
+
+// 请求失败或等待应答超时时恢复本地订阅记录
+func TestSubscriptionsRevertOnFailure(t *testing.T) {
+	initial := map[string][]int{"SZ000001": {4}, "SZ000002": {4}}
+	tests := []struct {
+		name  string
+		reply func(srv *testServer, conn *websocket.Conn, msg map[string]any) // nil 时不应答
+		call  func(c *Client) error
+		err   error
+		want  map[string][]int
+	}{
+		{
+			name:  "batch subscribe rejected",
+			reply: rejectRequest,
+			call: func(c *Client) error {
+				return c.BatchSubscribe([]SubscribeMessage{{StockCode: "SZ000001", DataTypes: []int{4, 8}}, {StockCode: "SZ000003", DataTypes: []int{4}}})
+			},
+			want: initial,
+		},
+		{
+			name: "subscribe timed out",
+			call: func(c *Client) error { return c.Subscribe("SZ000003", []int{4}) },
+			err:  ErrAckTimeout,
+			want: initial,
+		},
+		{
+			name:  "batch unsubscribe rejected",
+			reply: rejectRequest,
+			call:  func(c *Client) error { return c.BatchUnsubscribe([]string{"SZ000001", "SZ000009"}) },
+			want:  initial,
+		},
+		{
+			name:  "unsubscribe rejected",
+			reply: rejectRequest,
+			call:  func(c *Client) error { return c.Unsubscribe("SZ000002") },
+			want:  initial,
+		},
+		{
+			name:  "reset rejected",
+			reply: rejectRequest,
+			call: func(c *Client) error {
+				return c.ResetSubscriptions([]SubscribeMessage{{StockCode: "SZ000003", DataTypes: []int{14}}})
+			},
+			want: initial,
+		},
+		{
+			name: "partial failure",
+			reply: func(srv *testServer, conn *websocket.Conn, msg map[string]any) {
+				srv.write(conn, map[string]any{"type": msg["type"], "data": map[string]any{
+					"error_list": []any{map[string]any{"stock_code": "SZ000003", "error": "invalid stock code"}},
+				}})
+			},
+			call: func(c *Client) error {
+				return c.BatchSubscribe([]SubscribeMessage{{StockCode: "SZ000001", DataTypes: []int{4, 8}}, {StockCode: "SZ000003", DataTypes: []int{4}}})
+			},
+			want: map[string][]int{"SZ000001": {4, 8}, "SZ000002": {4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t)
+			srv.ackRequests(nil)
+			c := connectTestClient(t, srv, WithAckTimeout(200*time.Millisecond))
+			if err := c.SubscribeAll([]SubscribeMessage{
+				{StockCode: "SZ000001", DataTypes: []int{4}},
+				{StockCode: "SZ000002", DataTypes: []int{4}},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			reply := tt.reply
+			srv.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+				if msg["type"] == MessageTypePing || msg["type"] == MessageTypeAuth {
+					return false
+				}
+				if reply != nil {
+					reply(srv, conn, msg)
+				}
+				return true
+			})
+			err := tt.call(c)
+			if err == nil || tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("call = %v", err)
+			}
+			if got := c.GetSubscriptions(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("subscriptions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 未等待应答时，服务器返回的错误同样恢复本地记录
+func TestSubscriptionsRevertOnAsyncError(t *testing.T) {
+	srv := newTestServer(t)
+	c := connectTestClient(t, srv)
+	srv.ackRequests(func(map[string]any) string { return "invalid stock code" })
+	if err := c.Subscribe("SZ000001", []int{4}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-c.ErrorChannel():
+		var reqErr *RequestError
+		if !errors.As(err, &reqErr) {
+			t.Fatalf("error = %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("error not reported")
+	}
+	if got := c.GetSubscriptions(); len(got) != 0 {
+		t.Fatalf("subscriptions = %v", got)
+	}
+}
+
+// 请求之后又被修改的股票不因该请求失败而恢复
+func TestSubscriptionsRevertKeepsLaterChanges(t *testing.T) {
+	srv := newTestServer(t)
+	c := connectTestClient(t, srv)
+	c.mu.Lock()
+	undo := c.updateSubscriptionsLocked([]SubscribeMessage{{StockCode: "SZ000001", DataTypes: []int{4}}, {StockCode: "SZ000002", DataTypes: []int{4}}}, nil)
+	c.subscriptions["SZ000002"] = []int{8}
+	c.mu.Unlock()
+
+	c.revertSubscriptions(undo, nil)
+	if got := c.GetSubscriptions(); !reflect.DeepEqual(got, map[string][]int{"SZ000002": {8}}) {
+		t.Fatalf("subscriptions = %v", got)
+	}
+}
+
+// success 应答只交给其标明类型的请求
+func TestSuccessReplyMatchesOp(t *testing.T) {
+	srv := newTestServer(t)
+	c := connectTestClient(t, srv, WithAckTimeout(3*time.Second))
+	srv.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		return msg["type"] == MessageTypeBatchSubscribe
+	})
+
+	subscribed := make(chan error, 1)
+	go func() {
+		subscribed <- c.BatchSubscribe([]SubscribeMessage{{StockCode: "SZ000001", DataTypes: []int{4}}})
+	}()
+	waitFor(t, "batch_subscribe sent", func() bool { return len(srv.messages(MessageTypeBatchSubscribe)) == 1 })
+
+	for _, data := range []map[string]any{{"message": "ok"}, {"op": MessageTypeUnsubscribe}} {
+		srv.broadcast(map[string]any{"type": MessageTypeSuccess, "data": data})
+	}
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case err := <-subscribed:
+		t.Fatalf("BatchSubscribe resolved by another reply: %v", err)
+	default:
+	}
+
+	srv.broadcast(map[string]any{"type": MessageTypeSuccess, "data": map[string]any{"op": MessageTypeBatchSubscribe}})
+	select {
+	case err := <-subscribed:
+		if err != nil {
+			t.Fatalf("BatchSubscribe = %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("BatchSubscribe not resolved")
+	}
+}
+
+// 有多个未应答请求时，未指明请求类型和股票的错误不归属任何请求，也不改动本地订阅记录
+func TestUnattributedServerError(t *testing.T) {
+	srv := newTestServer(t)
+	c := connectTestClient(t, srv, WithAckTimeout(3*time.Second))
+	srv.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		return msg["type"] == MessageTypeSubscribe
+	})
+
+	results := make(map[string]chan error)
+	for _, code := range []string{"SZ000001", "SZ000002"} {
+		result := make(chan error, 1)
+		results[code] = result
+		go func(code string) { result <- c.Subscribe(code, []int{4}) }(code)
+		waitFor(t, "subscribe sent", func() bool { return len(srv.messages(MessageTypeSubscribe)) == len(results) })
+	}
+
+	srv.broadcast(map[string]any{"type": MessageTypeError, "error": "internal error"})
+	select {
+	case err := <-c.ErrorChannel():
+		var serverErr *ServerError
+		var reqErr *RequestError
+		if !errors.As(err, &serverErr) || errors.As(err, &reqErr) {
+			t.Fatalf("error = %v, want unattributed *ServerError", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("error not reported")
+	}
+	for code, result := range results {
+		select {
+		case err := <-result:
+			t.Fatalf("Subscribe(%s) resolved by an unattributed error: %v", code, err)
+		default:
+		}
+	}
+	if got := c.GetSubscriptions(); len(got) != 2 {
+		t.Fatalf("subscriptions = %v", got)
+	}
+
+	// 指明股票的错误交给包含该股票的请求
+	srv.broadcast(map[string]any{"type": MessageTypeError, "error": "invalid stock code", "data": map[string]any{"stock_code": "SZ000002"}})
+	var reqErr *RequestError
+	if err := <-results["SZ000002"]; !errors.As(err, &reqErr) || reqErr.StockCodes[0] != "SZ000002" {
+		t.Fatalf("Subscribe(SZ000002) = %v", err)
+	}
+	if _, ok := c.GetSubscriptions()["SZ000002"]; ok {
+		t.Fatal("failed subscription kept")
+	}
+}
+
+// rejectRequest 以错误消息拒绝请求
+func rejectRequest(srv *testServer, conn *websocket.Conn, msg map[string]any) {
+	srv.write(conn, map[string]any{"type": MessageTypeError, "error": "rejected", "data": map[string]any{"code": "INVALID_REQUEST", "op": msg["type"]}})
+}
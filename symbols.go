@@ -0,0 +1,89 @@
+package dtraderhq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadSymbolList 从文件加载股票列表，见 ParseSymbolList
+func LoadSymbolList(path string, defaultTypes []int) ([]SubscribeMessage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open symbol list: %w", err)
+	}
+	defer f.Close()
+	return ParseSymbolList(f, defaultTypes)
+}
+
+// ParseSymbolList 解析股票列表，每行一只股票，# 之后为注释
+//
+//	SZ002240          # 使用 defaultTypes
+//	SH603065 4,14     # 单独指定数据类型
+//
+// 同一股票出现多次时以最后一次为准，返回结果保持首次出现的顺序。
+func ParseSymbolList(r io.Reader, defaultTypes []int) ([]SubscribeMessage, error) {
+	var subscriptions []SubscribeMessage
+	index := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("line %d: too many fields", lineNo)
+		}
+
+		dataTypes := append([]int(nil), defaultTypes...)
+		if len(fields) == 2 {
+			types, err := ParseDataTypes(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			dataTypes = types
+		}
+		if len(dataTypes) == 0 {
+			return nil, fmt.Errorf("line %d: no data types for %s", lineNo, fields[0])
+		}
+
+		sub := SubscribeMessage{StockCode: fields[0], DataTypes: dataTypes}
+		if i, ok := index[sub.StockCode]; ok {
+			subscriptions[i] = sub
+			continue
+		}
+		index[sub.StockCode] = len(subscriptions)
+		subscriptions = append(subscriptions, sub)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read symbol list: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// ParseDataTypes 解析逗号分隔的数据类型列表，例如 "4,8,14"
+func ParseDataTypes(s string) ([]int, error) {
+	var types []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data type %q", part)
+		}
+		types = append(types, n)
+	}
+	return types, nil
+}
@@ -0,0 +1,253 @@
+package dtraderhq
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// 获取 token、等待重新认证应答和提前刷新的默认时长
+const (
+	tokenFetchTimeout   = 30 * time.Second
+	authReplyTimeout    = 10 * time.Second
+	defaultTokenRefresh = 5 * time.Minute
+)
+
+// TokenProvider 认证 token 的来源，客户端在认证、重连和 token 即将过期时调用
+type TokenProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenProviderFunc 将函数用作 TokenProvider
+type TokenProviderFunc func(ctx context.Context) (string, error)
+
+// Token 实现 TokenProvider
+func (f TokenProviderFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticToken 固定的 token
+type StaticToken string
+
+// Token 实现 TokenProvider
+func (t StaticToken) Token(context.Context) (string, error) {
+	if t == "" {
+		return "", fmt.Errorf("%w: static token is empty", ErrNoToken)
+	}
+	return string(t), nil
+}
+
+// EnvToken 从环境变量读取 token，值为变量名，每次调用时重新读取
+type EnvToken string
+
+// Token 实现 TokenProvider
+func (e EnvToken) Token(context.Context) (string, error) {
+	if token := strings.TrimSpace(os.Getenv(string(e))); token != "" {
+		return token, nil
+	}
+	return "", fmt.Errorf("%w: environment variable %s is empty", ErrNoToken, string(e))
+}
+
+// FileToken 从文件读取 token，值为文件路径，每次调用时重新读取，便于外部程序轮换
+type FileToken string
+
+// Token 实现 TokenProvider
+func (f FileToken) Token(context.Context) (string, error) {
+	b, err := os.ReadFile(string(f))
+	if err != nil {
+		return "", fmt.Errorf("read token file: %w", err)
+	}
+	if token := strings.TrimSpace(string(b)); token != "" {
+		return token, nil
+	}
+	return "", fmt.Errorf("%w: token file %s is empty", ErrNoToken, string(f))
+}
+
+// CommandToken 执行命令，以标准输出作为 token
+type CommandToken struct {
+	Name string
+	Args []string
+}
+
+// Token 实现 TokenProvider
+func (c CommandToken) Token(ctx context.Context) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Name, c.Args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("token command %s: %w: %s", c.Name, err, msg)
+		}
+		return "", fmt.Errorf("token command %s: %w", c.Name, err)
+	}
+	if token := strings.TrimSpace(string(out)); token != "" {
+		return token, nil
+	}
+	return "", fmt.Errorf("%w: token command %s printed nothing", ErrNoToken, c.Name)
+}
+
+// TokenExpiry 读取 JWT 的 exp 声明（不校验签名），不是 JWT 或没有 exp 时返回 false
+func TokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp *json.Number `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == nil {
+		return time.Time{}, false
+	}
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(exp), 0), true
+}
+
+// WithTokenProvider 设置 token 来源：Authenticate("") 和自动重连时从 p 获取 token，
+// token 即将过期时从 p 获取新 token 重新认证
+func WithTokenProvider(p TokenProvider) Option {
+	return func(c *Client) {
+		c.tokens = p
+	}
+}
+
+// WithTokenRefresh 设置 JWT 过期前多久重新获取 token 并重新认证，默认 5 分钟
+//
+// 无法刷新时（没有 TokenProvider 或获取失败）以 ErrTokenExpiring 报告，直到过期。
+func WithTokenRefresh(before time.Duration) Option {
+	return func(c *Client) {
+		c.tokenRefresh = before
+	}
+}
+
+// fetchToken 从 TokenProvider 获取 token
+func (c *Client) fetchToken() (string, error) {
+	if c.tokens == nil {
+		return "", fmt.Errorf("%w: no token provider", ErrNoToken)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tokenFetchTimeout)
+	defer cancel()
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("token provider: %w", err)
+	}
+	return token, nil
+}
+
+// tokenLoop 在 JWT 过期前重新获取 token 并在当前连接上重新认证，无法刷新时报告即将过期
+func (c *Client) tokenLoop(done chan struct{}, token string) {
+	exp, ok := TokenExpiry(token)
+	if !ok {
+		return
+	}
+	before := c.tokenRefresh
+	if before <= 0 {
+		before = defaultTokenRefresh
+	}
+
+	wait := time.Until(exp) - before
+	for {
+		timer := time.NewTimer(max(wait, 0))
+		select {
+		case <-timer.C:
+		case <-done:
+			timer.Stop()
+			return
+		}
+
+		remaining := time.Until(exp)
+		if remaining <= 0 {
+			c.tokenWarning(done, fmt.Errorf("%w at %s", ErrTokenExpired, exp.Format(time.RFC3339)))
+			return
+		}
+
+		err := c.refreshToken(done, token, exp)
+		if err == nil {
+			select {
+			case <-done:
+				// 连接已关闭并在后台重连，新连接认证后有自己的 tokenLoop
+				return
+			default:
+			}
+			token = c.currentToken()
+			if exp, ok = TokenExpiry(token); !ok {
+				return
+			}
+			c.logger().Info("token refreshed", slog.Time("expires_at", exp))
+			wait = time.Until(exp) - before
+			continue
+		}
+		c.tokenWarning(done, fmt.Errorf("%w in %s: %w", ErrTokenExpiring, remaining.Truncate(time.Second), err))
+		wait = min(30*time.Second, remaining)
+	}
+}
+
+// refreshToken 获取过期时间晚于 exp 的新 token 并在当前连接上重新认证，等待服务器应答；
+// 新 token 被拒绝或没有应答时当前连接的认证状态不再可信，关闭连接并在后台重连、重新认证
+func (c *Client) refreshToken(done chan struct{}, token string, exp time.Time) error {
+	next, err := c.fetchToken()
+	if err != nil {
+		return err
+	}
+	if nextExp, ok := TokenExpiry(next); next == token || ok && !nextExp.After(exp) {
+		return errors.New("token provider returned no newer token")
+	}
+
+	result, err := c.authenticate(next)
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(authReplyTimeout)
+	defer timer.Stop()
+	select {
+	case err = <-result:
+	case <-timer.C:
+		err = fmt.Errorf("%w: re-authentication after %s", ErrAckTimeout, authReplyTimeout)
+	case <-done:
+		return ErrConnectionClosed
+	}
+	if err == nil {
+		return nil
+	}
+
+	// 关闭连接并交给重连循环：每次重连有各自的超时，失败时按退避重试，
+	// 未设置 WithAutoReconnect 时同样重连，直到成功或调用 Close
+	c.mu.Lock()
+	if c.closeChan != done {
+		// 连接已断开或被替换
+		c.mu.Unlock()
+		return ErrConnectionClosed
+	}
+	reconnect := c.isConnected && !c.userClosed
+	c.closeLocked()
+	c.mu.Unlock()
+	c.logger().Warn("re-authentication failed, reconnecting", slog.Any("error", err))
+	if reconnect {
+		go c.autoReconnectLoop()
+	}
+	return nil
+}
+
+// tokenWarning 记录并报告 token 过期相关的错误
+func (c *Client) tokenWarning(done chan struct{}, err error) {
+	c.logger().Warn("token expiring", slog.Any("error", err))
+	select {
+	case c.errorChan <- err:
+	case <-done:
+	}
+}
@@ -0,0 +1,200 @@
+package dtraderhq
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testJWT 返回带 exp 声明的未签名 JWT
+func testJWT(exp time.Time, id int) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		enc.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d,"jti":"%d"}`, exp.Unix(), id))) + ".sig"
+}
+
+func TestTokenProviders(t *testing.T) {
+	ctx := context.Background()
+	if _, err := StaticToken("").Token(ctx); !errors.Is(err, ErrNoToken) {
+		t.Errorf("empty StaticToken = %v", err)
+	}
+
+	t.Setenv("DTRADERHQ_TEST_TOKEN", " env-token\n")
+	if got, err := EnvToken("DTRADERHQ_TEST_TOKEN").Token(ctx); err != nil || got != "env-token" {
+		t.Errorf("EnvToken = %q, %v", got, err)
+	}
+	if _, err := EnvToken("DTRADERHQ_TEST_UNSET").Token(ctx); !errors.Is(err, ErrNoToken) {
+		t.Errorf("unset EnvToken = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("file-token\n"), 0o600)
+	if got, err := FileToken(path).Token(ctx); err != nil || got != "file-token" {
+		t.Errorf("FileToken = %q, %v", got, err)
+	}
+	// 每次调用重新读取
+	os.WriteFile(path, []byte("  \n"), 0o600)
+	if _, err := FileToken(path).Token(ctx); !errors.Is(err, ErrNoToken) {
+		t.Errorf("empty FileToken = %v", err)
+	}
+
+	if got, err := (CommandToken{Name: "sh", Args: []string{"-c", "echo cmd-token"}}).Token(ctx); err != nil || got != "cmd-token" {
+		t.Errorf("CommandToken = %q, %v", got, err)
+	}
+	if _, err := (CommandToken{Name: "sh", Args: []string{"-c", "echo denied >&2; exit 1"}}).Token(ctx); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("failing CommandToken = %v", err)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	exp := time.Unix(1760000000, 0)
+	if got, ok := TokenExpiry(testJWT(exp, 1)); !ok || !got.Equal(exp) {
+		t.Fatalf("TokenExpiry = %v, %v", got, ok)
+	}
+	noExp := "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"x"}`)) + ".sig"
+	for _, token := range []string{"plain-token", "a.b", "a.!!.c", noExp} {
+		if _, ok := TokenExpiry(token); ok {
+			t.Errorf("TokenExpiry(%q) reported an expiry", token)
+		}
+	}
+}
+
+// tokenSequence 依次返回 tokens，用完后重复最后一个
+type tokenSequence struct {
+	mu     sync.Mutex
+	tokens []string
+	calls  int
+}
+
+func (s *tokenSequence) Token(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token := s.tokens[min(s.calls, len(s.tokens)-1)]
+	s.calls++
+	return token, nil
+}
+
+func lastAuthToken(srv *testServer) string {
+	auths := srv.messages(MessageTypeAuth)
+	token, _ := auths[len(auths)-1]["data"].(map[string]any)["token"].(string)
+	return token
+}
+
+func TestTokenRefresh(t *testing.T) {
+	now := time.Now()
+	first, second := testJWT(now.Add(2*time.Second), 1), testJWT(now.Add(time.Hour), 2)
+	srv := newTestServer(t)
+	c := NewClient(srv.url(), WithTokenProvider(&tokenSequence{tokens: []string{first, second}}), WithTokenRefresh(time.Hour))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Authenticate(""); err != nil {
+		t.Fatal(err)
+	}
+	done := c.Done()
+
+	waitFor(t, "refresh", func() bool { return len(srv.messages(MessageTypeAuth)) == 2 })
+	if got := lastAuthToken(srv); got != second {
+		t.Fatalf("refreshed with %q", got)
+	}
+	waitFor(t, "refresh completed", func() bool { return c.currentToken() == second && !authPending(c) })
+	select {
+	case <-done:
+		t.Fatal("reconnected after an accepted refresh")
+	default:
+	}
+}
+
+// 新 token 被拒绝时重连，以 TokenProvider 的下一个 token 认证并恢复订阅
+func TestTokenRefreshRejectedReconnects(t *testing.T) {
+	now := time.Now()
+	first, rejected, third := testJWT(now.Add(2*time.Second), 1), testJWT(now.Add(time.Hour), 2), testJWT(now.Add(time.Hour), 3)
+	srv := newTestServer(t)
+	srv.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		if msg["type"] != MessageTypeAuth || msg["data"].(map[string]any)["token"] != rejected {
+			return false
+		}
+		srv.write(conn, map[string]any{"type": MessageTypeAuth, "data": map[string]any{"success": false, "message": "token revoked"}})
+		return true
+	})
+
+	c := NewClient(srv.url(), WithTokenProvider(&tokenSequence{tokens: []string{first, rejected, third}}), WithTokenRefresh(time.Hour))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Authenticate(""); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := c.WaitAuthenticated(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Subscribe("SZ000001", []int{4}); err != nil {
+		t.Fatal(err)
+	}
+	done := c.Done()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("connection kept after the new token was rejected")
+	}
+	waitFor(t, "reconnect", func() bool { return c.IsAuthenticated() && lastAuthToken(srv) == third })
+	waitFor(t, "resubscribe", func() bool { return len(srv.messages(MessageTypeBatchSubscribe)) == 1 })
+	if n := len(srv.messages(MessageTypeAuth)); n != 3 {
+		t.Fatalf("auth requests = %d", n)
+	}
+}
+
+// authPending 是否有未收到应答的认证请求
+func authPending(c *Client) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.authReq != nil
+}
+
+// 重新认证失败后的重连没有成功时，未设置 WithAutoReconnect 也按退避继续重连
+func TestTokenRefreshReconnectRetries(t *testing.T) {
+	now := time.Now()
+	first, rejected, expired, fourth := testJWT(now.Add(2*time.Second), 1), testJWT(now.Add(time.Hour), 2), testJWT(now.Add(time.Hour), 3), testJWT(now.Add(time.Hour), 4)
+	srv := newTestServer(t)
+	srv.setHandler(func(conn *websocket.Conn, msg map[string]any) bool {
+		if token := msg["data"].(map[string]any)["token"]; msg["type"] != MessageTypeAuth || token != rejected && token != expired {
+			return false
+		}
+		srv.write(conn, map[string]any{"type": MessageTypeAuth, "data": map[string]any{"success": false, "message": "token revoked"}})
+		return true
+	})
+
+	c := NewClient(srv.url(), WithTokenProvider(&tokenSequence{tokens: []string{first, rejected, expired, fourth}}), WithTokenRefresh(time.Hour))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Authenticate(""); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !c.IsAuthenticated() || lastAuthToken(srv) != fourth {
+		if time.Now().After(deadline) {
+			t.Fatalf("not reconnected, last token %q", lastAuthToken(srv))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(srv.messages(MessageTypeAuth)); n != 4 {
+		t.Fatalf("auth requests = %d", n)
+	}
+}
@@ -0,0 +1,177 @@
+package dtraderhq
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// FrameDirection 帧的方向
+type FrameDirection int
+
+const (
+	// FrameInbound 从服务器收到的帧
+	FrameInbound FrameDirection = iota
+	// FrameOutbound 发送给服务器的帧
+	FrameOutbound
+)
+
+// String 返回 in 或 out
+func (d FrameDirection) String() string {
+	if d == FrameOutbound {
+		return "out"
+	}
+	return "in"
+}
+
+// Frame 一个原始 WebSocket 帧
+type Frame struct {
+	Direction   FrameDirection
+	At          time.Time
+	Endpoint    string
+	MessageType int    // websocket.TextMessage、PingMessage 或 PongMessage
+	Data        []byte // 客户端不会复用，可以保留
+}
+
+// WithFrameTap 将收发的每个原始帧（含 ping/pong 控制帧）交给 fn
+//
+// fn 在读写协程中同步调用，应尽快返回。发送的认证消息中 token 已脱敏。
+func WithFrameTap(fn func(Frame)) Option {
+	return func(c *Client) {
+		c.frameTap = fn
+	}
+}
+
+// tap 将帧交给 WithFrameTap 设置的回调
+func (c *Client) tap(dir FrameDirection, messageType int, data []byte) {
+	if c.frameTap == nil {
+		return
+	}
+	c.frameTap(Frame{
+		Direction:   dir,
+		At:          time.Now(),
+		Endpoint:    c.URL(),
+		MessageType: messageType,
+		Data:        data,
+	})
+}
+
+// 帧类型在 trace 文件中的名称
+var frameTypeNames = map[int]string{
+	websocket.TextMessage:   "text",
+	websocket.BinaryMessage: "binary",
+	websocket.PingMessage:   "ping",
+	websocket.PongMessage:   "pong",
+	websocket.CloseMessage:  "close",
+}
+
+// traceRecord trace 文件中的一行
+type traceRecord struct {
+	At       time.Time `json:"at"`
+	Dir      string    `json:"dir"`
+	Endpoint string    `json:"endpoint"`
+	Type     string    `json:"type"`
+	Data     string    `json:"data"`
+}
+
+// TraceWriter 将收发的帧按 JSON Lines 格式记录下来，用于排查协议问题或生成回放数据
+//
+//	trace := dtraderhq.NewTraceWriter(f)
+//	client := dtraderhq.NewClient(url, dtraderhq.WithFrameTap(trace.Tap))
+type TraceWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewTraceWriter 创建写入 w 的 trace 记录器
+func NewTraceWriter(w io.Writer) *TraceWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &TraceWriter{enc: enc}
+}
+
+// Tap 记录一个帧，可直接传给 WithFrameTap；写入失败后不再记录，错误由 Err 返回
+func (t *TraceWriter) Tap(f Frame) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return
+	}
+	t.err = t.enc.Encode(traceRecord{
+		At:       f.At,
+		Dir:      f.Direction.String(),
+		Endpoint: f.Endpoint,
+		Type:     frameTypeNames[f.MessageType],
+		Data:     string(f.Data),
+	})
+}
+
+// Err 返回第一次写入失败的错误
+func (t *TraceWriter) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// TraceReader 读取 TraceWriter 记录的帧
+type TraceReader struct {
+	dec  *json.Decoder
+	line int
+}
+
+// NewTraceReader 创建从 r 读取的 trace 读取器
+func NewTraceReader(r io.Reader) *TraceReader {
+	return &TraceReader{dec: json.NewDecoder(r)}
+}
+
+// Next 返回下一个帧，读完时返回 io.EOF
+func (t *TraceReader) Next() (Frame, error) {
+	var rec traceRecord
+	if err := t.dec.Decode(&rec); err != nil {
+		if err == io.EOF {
+			return Frame{}, io.EOF
+		}
+		return Frame{}, fmt.Errorf("trace record %d: %w", t.line+1, err)
+	}
+	t.line++
+
+	f := Frame{At: rec.At, Endpoint: rec.Endpoint, Data: []byte(rec.Data)}
+	switch rec.Dir {
+	case "in":
+		f.Direction = FrameInbound
+	case "out":
+		f.Direction = FrameOutbound
+	default:
+		return Frame{}, fmt.Errorf("trace record %d: invalid direction %q", t.line, rec.Dir)
+	}
+	for messageType, name := range frameTypeNames {
+		if name == rec.Type {
+			f.MessageType = messageType
+		}
+	}
+	if f.MessageType == 0 {
+		return Frame{}, fmt.Errorf("trace record %d: invalid frame type %q", t.line, rec.Type)
+	}
+	return f, nil
+}
+
+// ReadTrace 读取 r 中记录的全部帧
+func ReadTrace(r io.Reader) ([]Frame, error) {
+	tr := NewTraceReader(r)
+	var frames []Frame
+	for {
+		f, err := tr.Next()
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, err
+		}
+		frames = append(frames, f)
+	}
+}
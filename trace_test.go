@@ -0,0 +1,115 @@
+package dtraderhq
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestTraceRoundTrip(t *testing.T) {
+	at := time.Date(2026, 10, 16, 9, 30, 0, 123456789, ShanghaiLocation)
+	frames := []Frame{
+		{Direction: FrameOutbound, At: at, Endpoint: "ws://a/ws", MessageType: websocket.TextMessage, Data: []byte(`{"type":"auth","data":{"token":"[REDACTED]"}}`)},
+		{Direction: FrameInbound, At: at.Add(time.Millisecond), Endpoint: "ws://a/ws", MessageType: websocket.TextMessage, Data: []byte("{\"message\":\"认证成功 <ok> & \\n\"}")},
+		{Direction: FrameOutbound, At: at.Add(2 * time.Millisecond), Endpoint: "ws://a/ws", MessageType: websocket.PingMessage},
+		{Direction: FrameInbound, At: at.Add(3 * time.Millisecond), Endpoint: "ws://a/ws", MessageType: websocket.PongMessage, Data: []byte("x")},
+	}
+
+	var buf bytes.Buffer
+	tw := NewTraceWriter(&buf)
+	for _, f := range frames {
+		tw.Tap(f)
+	}
+	if err := tw.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<ok> &") {
+		t.Fatalf("HTML escaped: %s", buf.String())
+	}
+
+	got, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(frames) {
+		t.Fatalf("read %d frames", len(got))
+	}
+	for i, f := range frames {
+		g := got[i]
+		if g.Direction != f.Direction || !g.At.Equal(f.At) || g.Endpoint != f.Endpoint || g.MessageType != f.MessageType || !bytes.Equal(g.Data, f.Data) {
+			t.Errorf("frame %d = %+v, want %+v", i, g, f)
+		}
+	}
+}
+
+func TestTraceReaderErrors(t *testing.T) {
+	tests := []struct {
+		input, err string
+	}{
+		{`{"dir":"in","type":"text"}` + "\n" + `{"dir":"up","type":"text"}`, `trace record 2: invalid direction "up"`},
+		{`{"dir":"in","type":"frame"}`, `trace record 1: invalid frame type "frame"`},
+		{`{"dir":"in","type":"text"}` + "\n{", "trace record 2:"},
+	}
+	for _, tt := range tests {
+		frames, err := ReadTrace(strings.NewReader(tt.input))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("ReadTrace(%q) = %v, want %q", tt.input, err, tt.err)
+		}
+		if strings.HasPrefix(tt.input, `{"dir":"in","type":"text"}`+"\n") && len(frames) != 1 {
+			t.Errorf("ReadTrace(%q) returned %d frames before the error", tt.input, len(frames))
+		}
+	}
+}
+
+// 客户端收发的帧经 WithFrameTap 记录，认证消息中的 token 已脱敏
+func TestFrameTapTrace(t *testing.T) {
+	srv := newTestServer(t)
+	var buf syncBuffer
+	tw := NewTraceWriter(&buf)
+	c := NewClient(srv.url(), WithFrameTap(tw.Tap), WithKeepalive(KeepaliveBoth))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Authenticate("secret-token"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := c.WaitAuthenticated(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "pong frames", func() bool { return strings.Count(buf.String(), `"type":"pong"`) == 1 })
+
+	if strings.Contains(buf.String(), "secret-token") {
+		t.Fatalf("token leaked: %s", buf.String())
+	}
+	frames, err := ReadTrace(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sawAuth, sawReply, sawPing bool
+	for _, f := range frames {
+		if f.Endpoint != srv.url() {
+			t.Errorf("endpoint = %s", f.Endpoint)
+		}
+		switch {
+		case f.Direction == FrameOutbound && strings.Contains(string(f.Data), `"type":"auth"`):
+			sawAuth = strings.Contains(string(f.Data), "[REDACTED]")
+		case f.Direction == FrameInbound && strings.Contains(string(f.Data), "认证成功"):
+			sawReply = true
+		case f.Direction == FrameOutbound && f.MessageType == websocket.PingMessage:
+			sawPing = true
+		}
+	}
+	if !sawAuth || !sawReply || !sawPing {
+		t.Fatalf("auth = %v, reply = %v, ping = %v in %s", sawAuth, sawReply, sawPing, buf.String())
+	}
+}
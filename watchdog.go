@@ -0,0 +1,164 @@
+package dtraderhq
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SilentSymbol 数据中断的股票
+type SilentSymbol struct {
+	StockCode string
+	Since     time.Time     // 最近一次收到数据（或开始监视）的时间
+	Silence   time.Duration // 已中断的时长
+}
+
+// SilenceWatchdog 监视已订阅股票的数据中断
+//
+// 通过 WithSilenceWatchdog 接入客户端后，Run 定期检查客户端的订阅，
+// 在交易时段内超过 Threshold 没有数据的股票报告一次，收到数据后恢复监视。
+// 非交易时段（含午休）不检查，进入交易时段时重新计时。
+type SilenceWatchdog struct {
+	Threshold time.Duration // 允许的最长无数据时间，默认 60s
+	Interval  time.Duration // 检查间隔，默认 Threshold 的 1/4
+	Calendar  *Calendar     // 为空时不区分交易时段，始终检查
+
+	// OnSilent 报告数据中断的股票，为空时以错误形式发送到客户端的 ErrorChannel
+	OnSilent func(SilentSymbol)
+
+	mu         sync.Mutex
+	last       map[string]time.Time
+	alerted    map[string]bool
+	wasTrading map[Market]bool
+	dropped    atomic.Uint64
+}
+
+// NewSilenceWatchdog 创建数据中断监视器
+func NewSilenceWatchdog(threshold time.Duration, cal *Calendar) *SilenceWatchdog {
+	return &SilenceWatchdog{Threshold: threshold, Calendar: cal}
+}
+
+func (w *SilenceWatchdog) threshold() time.Duration {
+	if w.Threshold > 0 {
+		return w.Threshold
+	}
+	return 60 * time.Second
+}
+
+// Observe 记录股票收到数据的时间，客户端收到数据帧时自动调用
+func (w *SilenceWatchdog) Observe(md *MarketData) {
+	at := md.ReceivedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.last == nil {
+		w.last = make(map[string]time.Time)
+		w.alerted = make(map[string]bool)
+	}
+	w.last[md.StockCode] = at
+	delete(w.alerted, md.StockCode)
+}
+
+// Check 返回 subscribed 中在 now 时刻新出现数据中断的股票，每次中断只返回一次
+func (w *SilenceWatchdog) Check(subscribed []string, now time.Time) []SilentSymbol {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.last == nil {
+		w.last = make(map[string]time.Time)
+		w.alerted = make(map[string]bool)
+	}
+	if w.wasTrading == nil {
+		w.wasTrading = make(map[Market]bool)
+	}
+
+	trading := make(map[Market]bool)
+	for _, m := range AllMarkets {
+		trading[m] = w.Calendar == nil || w.Calendar.PhaseAt(m, now).IsTrading()
+	}
+
+	var silent []SilentSymbol
+	wanted := make(map[string]bool, len(subscribed))
+	for _, stockCode := range subscribed {
+		wanted[stockCode] = true
+		m := MarketOf(stockCode)
+		if !trading[m] {
+			continue
+		}
+
+		last, ok := w.last[stockCode]
+		if !ok || !w.wasTrading[m] {
+			// 首次监视或刚进入交易时段，从现在开始计时
+			w.last[stockCode] = now
+			delete(w.alerted, stockCode)
+			continue
+		}
+		if w.alerted[stockCode] {
+			continue
+		}
+		if silence := now.Sub(last); silence > w.threshold() {
+			w.alerted[stockCode] = true
+			silent = append(silent, SilentSymbol{StockCode: stockCode, Since: last, Silence: silence})
+		}
+	}
+
+	for stockCode := range w.last {
+		if !wanted[stockCode] {
+			delete(w.last, stockCode)
+			delete(w.alerted, stockCode)
+		}
+	}
+	w.wasTrading = trading
+
+	sort.Slice(silent, func(i, j int) bool { return silent[i].StockCode < silent[j].StockCode })
+	return silent
+}
+
+// Dropped 返回因客户端错误通道已满而丢弃的中断报告数量
+func (w *SilenceWatchdog) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Run 按 Interval 检查客户端当前订阅的股票，直到 ctx 结束
+func (w *SilenceWatchdog) Run(ctx context.Context, client *Client) {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = w.threshold() / 4
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			subs := client.GetSubscriptions()
+			codes := make([]string, 0, len(subs))
+			for stockCode := range subs {
+				codes = append(codes, stockCode)
+			}
+
+			for _, s := range w.Check(codes, now) {
+				if w.OnSilent != nil {
+					w.OnSilent(s)
+					continue
+				}
+				select {
+				case client.errorChan <- fmt.Errorf("no data for %s in %s", s.StockCode, s.Silence.Truncate(time.Second)):
+				default:
+					client.logger().Warn("silence alert dropped",
+						slog.String("stock_code", s.StockCode),
+						slog.Duration("silence", s.Silence),
+						slog.Uint64("dropped_total", w.dropped.Add(1)))
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
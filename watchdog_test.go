@@ -0,0 +1,79 @@
+package dtraderhq
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSilenceWatchdogCheck(t *testing.T) {
+	w := NewSilenceWatchdog(time.Minute, nil)
+	start := time.Date(2026, 10, 16, 10, 0, 0, 0, ShanghaiLocation)
+	subscribed := []string{"SZ000001", "SH600000"}
+
+	// 首次检查只开始计时
+	if got := w.Check(subscribed, start); len(got) != 0 {
+		t.Fatalf("first check = %v", got)
+	}
+	w.Observe(&MarketData{StockCode: "SZ000001", ReceivedAt: start.Add(30 * time.Second)})
+
+	got := w.Check(subscribed, start.Add(80*time.Second))
+	want := []SilentSymbol{{StockCode: "SH600000", Since: start, Silence: 80 * time.Second}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("check = %+v", got)
+	}
+	// 每次中断只报告一次，收到数据后重新监视
+	if got := w.Check(subscribed, start.Add(2*time.Minute)); len(got) != 1 || got[0].StockCode != "SZ000001" {
+		t.Fatalf("check = %+v", got)
+	}
+	w.Observe(&MarketData{StockCode: "SH600000", ReceivedAt: start.Add(2 * time.Minute)})
+	if got := w.Check(subscribed, start.Add(4*time.Minute)); len(got) != 1 || got[0].StockCode != "SH600000" {
+		t.Fatalf("check = %+v", got)
+	}
+}
+
+// 午休期间不检查，进入交易时段后重新计时
+func TestSilenceWatchdogTradingHours(t *testing.T) {
+	w := NewSilenceWatchdog(time.Minute, NewCalendar())
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, ShanghaiLocation)
+	subscribed := []string{"SZ000001"}
+
+	w.Check(subscribed, day.Add(11*time.Hour+29*time.Minute))
+	if got := w.Check(subscribed, day.Add(12*time.Hour)); len(got) != 0 {
+		t.Fatalf("lunch break check = %v", got)
+	}
+	if got := w.Check(subscribed, day.Add(13*time.Hour+30*time.Second)); len(got) != 0 {
+		t.Fatalf("check after lunch = %v", got)
+	}
+	if got := w.Check(subscribed, day.Add(13*time.Hour+2*time.Minute)); len(got) != 1 {
+		t.Fatalf("check = %v", got)
+	}
+}
+
+// 错误通道已满时丢弃的报告计入 Dropped
+func TestSilenceWatchdogRunCountsDropped(t *testing.T) {
+	c := NewClient("ws://127.0.0.1:1", WithErrorBuffer(1))
+	c.subscriptions["SZ000001"] = []int{4}
+	c.errorChan <- context.Canceled
+
+	w := &SilenceWatchdog{Threshold: 20 * time.Millisecond, Interval: 5 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx, c)
+	waitFor(t, "dropped alert", func() bool { return w.Dropped() == 1 })
+
+	<-c.errorChan
+	w.Observe(&MarketData{StockCode: "SZ000001"})
+	select {
+	case err := <-c.ErrorChannel():
+		if err == nil {
+			t.Fatal("nil alert")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("alert not reported")
+	}
+	if w.Dropped() != 1 {
+		t.Fatalf("Dropped() = %d", w.Dropped())
+	}
+}
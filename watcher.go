@@ -0,0 +1,90 @@
+package dtraderhq
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// SymbolWatcher 监视股票列表文件，文件变化时在不断开连接的情况下更新订阅
+type SymbolWatcher struct {
+	Path         string        // 股票列表文件，格式见 ParseSymbolList
+	DefaultTypes []int         // 未指定数据类型的股票使用的默认类型
+	Interval     time.Duration // 检查文件修改时间的间隔，默认 2s
+
+	// Load 读取目标订阅，为空时使用 LoadSymbolList(Path, DefaultTypes)
+	Load func() ([]SubscribeMessage, error)
+
+	// OnChange 每次应用差异后调用，err 非空表示读取或应用失败（已回滚），下次检查时会重试
+	OnChange func(diff SubscriptionDiff, err error)
+}
+
+// Run 立即同步一次订阅，之后按 Interval 轮询文件修改时间，直到 ctx 结束
+func (w *SymbolWatcher) Run(ctx context.Context, client *Client) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	var applied time.Time
+	check := func() {
+		info, err := os.Stat(w.Path)
+		if err != nil {
+			w.notify(SubscriptionDiff{}, fmt.Errorf("stat symbol list: %w", err))
+			return
+		}
+		if !applied.IsZero() && info.ModTime().Equal(applied) {
+			return
+		}
+
+		diff, err := w.Sync(client)
+		if !diff.Empty() || err != nil {
+			w.notify(diff, err)
+		}
+		if err == nil {
+			applied = info.ModTime()
+		}
+	}
+
+	check()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			check()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Sync 读取目标订阅并应用与客户端当前订阅的差异
+func (w *SymbolWatcher) Sync(client *Client) (SubscriptionDiff, error) {
+	var (
+		desired []SubscribeMessage
+		err     error
+	)
+	if w.Load != nil {
+		desired, err = w.Load()
+	} else {
+		desired, err = LoadSymbolList(w.Path, w.DefaultTypes)
+	}
+	if err != nil {
+		return SubscriptionDiff{}, err
+	}
+	if len(desired) == 0 {
+		// 文件可能正在被改写，不据此取消全部订阅
+		return SubscriptionDiff{}, fmt.Errorf("%w, ignored", ErrEmptySymbolList)
+	}
+
+	diff := DiffSubscriptions(client.GetSubscriptions(), desired)
+	return diff, client.ApplySubscriptionDiff(diff)
+}
+
+func (w *SymbolWatcher) notify(diff SubscriptionDiff, err error) {
+	if w.OnChange != nil {
+		w.OnChange(diff, err)
+	}
+}
@@ -0,0 +1,173 @@
+package dtraderhq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseSymbolList(t *testing.T) {
+	subs, err := ParseSymbolList(strings.NewReader(`
+# 注释
+SZ002240
+SH603065 4,14   # 单独指定
+SZ002240 8      # 以最后一次为准
+`), []int{4, 8, 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SubscribeMessage{
+		{StockCode: "SZ002240", DataTypes: []int{8}},
+		{StockCode: "SH603065", DataTypes: []int{4, 14}},
+	}
+	if !reflect.DeepEqual(subs, want) {
+		t.Fatalf("subs = %+v", subs)
+	}
+
+	for _, bad := range []string{"SZ002240 4 8", "SZ002240 x", "SZ002240"} {
+		if _, err := ParseSymbolList(strings.NewReader(bad), nil); err == nil {
+			t.Errorf("ParseSymbolList(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestDiffSubscriptions(t *testing.T) {
+	current := map[string][]int{
+		"SZ000001": {4, 8},
+		"SZ000002": {4},
+		"SZ000003": {14},
+	}
+	diff := DiffSubscriptions(current, []SubscribeMessage{
+		{StockCode: "SZ000001", DataTypes: []int{8, 4, 4}}, // 顺序和重复不影响
+		{StockCode: "SZ000002", DataTypes: []int{4, 14}},
+		{StockCode: "SZ000004", DataTypes: []int{4}},
+	})
+
+	want := SubscriptionDiff{
+		Added:   []SubscribeMessage{{StockCode: "SZ000004", DataTypes: []int{4}}},
+		Removed: []string{"SZ000003"},
+		Changed: []SubscribeMessage{{StockCode: "SZ000002", DataTypes: []int{4, 14}}},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Fatalf("diff = %+v", diff)
+	}
+	if got := diff.String(); got != "+SZ000004[4] -SZ000003 ~SZ000002[4 14]" {
+		t.Fatalf("String() = %q", got)
+	}
+	if !DiffSubscriptions(current, []SubscribeMessage{
+		{StockCode: "SZ000001", DataTypes: []int{4, 8}},
+		{StockCode: "SZ000002", DataTypes: []int{4}},
+		{StockCode: "SZ000003", DataTypes: []int{14}},
+	}).Empty() {
+		t.Fatal("identical subscriptions produced a diff")
+	}
+}
+
+func TestSymbolWatcherRun(t *testing.T) {
+	srv := newTestServer(t)
+	srv.ackRequests(nil)
+	c := connectTestClient(t, srv, WithAckTimeout(time.Second))
+	if err := c.SubscribeAll([]SubscribeMessage{
+		{StockCode: "SZ000001", DataTypes: []int{4}},
+		{StockCode: "SZ000002", DataTypes: []int{4}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "symbols.txt")
+	if err := os.WriteFile(path, []byte("SZ000001\nSZ000003\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var diffs []SubscriptionDiff
+	w := &SymbolWatcher{
+		Path:         path,
+		DefaultTypes: []int{4},
+		Interval:     10 * time.Millisecond,
+		OnChange: func(diff SubscriptionDiff, err error) {
+			if err != nil {
+				t.Errorf("OnChange error: %v", err)
+			}
+			mu.Lock()
+			diffs = append(diffs, diff)
+			mu.Unlock()
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx, c) }()
+
+	waitFor(t, "initial sync", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(diffs) == 1
+	})
+	if got := c.GetSubscriptions(); len(got) != 2 || got["SZ000003"] == nil || got["SZ000002"] != nil {
+		t.Fatalf("subscriptions = %v", got)
+	}
+
+	// 修改文件后应用新的差异，修改时间变化即可触发
+	if err := os.WriteFile(path, []byte("SZ000001 4,8\nSZ000003\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	os.Chtimes(path, later, later)
+	waitFor(t, "second sync", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(diffs) == 2
+	})
+	if got := c.GetSubscriptions()["SZ000001"]; !sameTypes(got, []int{4, 8}) {
+		t.Fatalf("SZ000001 types = %v", got)
+	}
+	if n := len(srv.messages(MessageTypeUnsubscribe)) + len(srv.messages(MessageTypeReset)); n != 0 {
+		t.Fatalf("watcher sent %d unsubscribe/reset requests", n)
+	}
+	srv.checkSubscriptions(t, c)
+
+	// 减少数据类型后服务器端不再保留去掉的类型
+	if err := os.WriteFile(path, []byte("SZ000001 4\nSZ000003\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Second)
+	os.Chtimes(path, later, later)
+	waitFor(t, "third sync", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(diffs) == 3
+	})
+	srv.checkSubscriptions(t, c)
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run = %v", err)
+	}
+}
+
+// 文件为空时不据此取消全部订阅
+func TestSymbolWatcherIgnoresEmptyList(t *testing.T) {
+	srv := newTestServer(t)
+	c := connectTestClient(t, srv)
+	if err := c.Subscribe("SZ000001", []int{4}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "symbols.txt")
+	if err := os.WriteFile(path, []byte("# 正在编辑\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w := &SymbolWatcher{Path: path, DefaultTypes: []int{4}}
+	if _, err := w.Sync(c); !errors.Is(err, ErrEmptySymbolList) {
+		t.Fatalf("Sync = %v", err)
+	}
+	if len(c.GetSubscriptions()) != 1 {
+		t.Fatal("subscriptions removed for an empty list")
+	}
+}